@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN stream_profile TEXT;
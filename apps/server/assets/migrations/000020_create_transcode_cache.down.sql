@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_transcode_cache_entries_last_accessed_at;
+DROP INDEX IF EXISTS idx_transcode_cache_entries_track_profile;
+
+DROP TABLE IF EXISTS transcode_cache_entries;
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS transcode_cache_entries (
+  key TEXT PRIMARY KEY,
+  track_id INTEGER NOT NULL,
+  profile TEXT NOT NULL,
+  size INTEGER NOT NULL,
+  nr_segments INTEGER NOT NULL,
+  created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+  last_accessed_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_transcode_cache_entries_track_profile ON transcode_cache_entries(track_id, profile);
+CREATE INDEX IF NOT EXISTS idx_transcode_cache_entries_last_accessed_at ON transcode_cache_entries(last_accessed_at);
@@ -0,0 +1,8 @@
+ALTER TABLE users DROP COLUMN normalization;
+
+ALTER TABLE tidal_albums DROP COLUMN loudness_true_peak;
+ALTER TABLE tidal_albums DROP COLUMN loudness_integrated;
+
+ALTER TABLE tidal_tracks DROP COLUMN loudness_analyzed_at;
+ALTER TABLE tidal_tracks DROP COLUMN loudness_true_peak;
+ALTER TABLE tidal_tracks DROP COLUMN loudness_integrated;
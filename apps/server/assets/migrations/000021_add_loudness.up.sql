@@ -0,0 +1,8 @@
+ALTER TABLE tidal_tracks ADD COLUMN loudness_integrated REAL;
+ALTER TABLE tidal_tracks ADD COLUMN loudness_true_peak REAL;
+ALTER TABLE tidal_tracks ADD COLUMN loudness_analyzed_at INTEGER;
+
+ALTER TABLE tidal_albums ADD COLUMN loudness_integrated REAL;
+ALTER TABLE tidal_albums ADD COLUMN loudness_true_peak REAL;
+
+ALTER TABLE users ADD COLUMN normalization TEXT;
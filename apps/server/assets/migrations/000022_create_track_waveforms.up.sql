@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tidal_track_waveforms (
+  track_id INTEGER PRIMARY KEY,
+  duration REAL NOT NULL,
+  resolution INTEGER NOT NULL,
+  peaks BLOB NOT NULL,
+  rms BLOB NOT NULL,
+  created_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
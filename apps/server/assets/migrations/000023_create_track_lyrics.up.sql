@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS tidal_track_lyrics (
+  track_id INTEGER PRIMARY KEY,
+  source TEXT NOT NULL,
+  provider TEXT,
+  text TEXT,
+  subtitles TEXT,
+  is_right_to_left INTEGER NOT NULL DEFAULT 0,
+  uploaded_by TEXT,
+  fetched_at INTEGER NOT NULL DEFAULT (unixepoch()),
+  FOREIGN KEY (uploaded_by) REFERENCES users(id) ON DELETE SET NULL
+);
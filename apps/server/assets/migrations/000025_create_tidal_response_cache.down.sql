@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tidal_response_cache_fetched_at;
+DROP TABLE IF EXISTS tidal_response_cache;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tidal_response_cache (
+  key TEXT PRIMARY KEY,
+  resource TEXT NOT NULL,
+  body BLOB NOT NULL,
+  fetched_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
+
+CREATE INDEX IF NOT EXISTS idx_tidal_response_cache_fetched_at ON tidal_response_cache(fetched_at);
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN country_code TEXT;
+ALTER TABLE users ADD COLUMN locale TEXT;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tidal_playlist_imports_synced_at;
+DROP TABLE IF EXISTS tidal_playlist_imports;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS tidal_playlist_imports (
+  playlist_id INTEGER PRIMARY KEY,
+  user_id TEXT NOT NULL,
+  tidal_uuid TEXT NOT NULL,
+  sync INTEGER NOT NULL DEFAULT 0,
+  synced_at INTEGER NOT NULL DEFAULT (unixepoch()),
+  FOREIGN KEY (playlist_id) REFERENCES playlists(id) ON DELETE CASCADE,
+  FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_tidal_playlist_imports_synced_at ON tidal_playlist_imports(synced_at) WHERE sync = 1;
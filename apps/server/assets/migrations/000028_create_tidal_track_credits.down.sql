@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_tidal_track_credits_contributor_id;
+DROP TABLE IF EXISTS tidal_track_credits;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS tidal_track_credits (
+  track_id INTEGER NOT NULL,
+  type TEXT NOT NULL,
+  name TEXT NOT NULL,
+  contributor_id INTEGER,
+  position INTEGER NOT NULL,
+  PRIMARY KEY (track_id, type, name),
+  FOREIGN KEY (track_id) REFERENCES tidal_tracks(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_tidal_track_credits_contributor_id ON tidal_track_credits(contributor_id);
@@ -0,0 +1 @@
+ALTER TABLE playlist_tracks DROP COLUMN position;
@@ -0,0 +1,10 @@
+ALTER TABLE playlist_tracks ADD COLUMN position INTEGER NOT NULL DEFAULT 0;
+
+UPDATE playlist_tracks
+SET position = (
+  SELECT COUNT(1)
+  FROM playlist_tracks AS earlier
+  WHERE earlier.playlist_id = playlist_tracks.playlist_id
+  AND (earlier.created_at < playlist_tracks.created_at
+    OR (earlier.created_at = playlist_tracks.created_at AND earlier.rowid < playlist_tracks.rowid))
+);
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/altierawr/oto/internal/tidal"
+)
+
+func (app *application) getTranscodeCacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.cache.Stats()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"cache": stats}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getTranscoderPoolHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"pool": app.transcoders.State()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getTidalStatusHandler(w http.ResponseWriter, r *http.Request) {
+	status := envelope{
+		"tokens":  app.tidal.Client().TokenStatus(),
+		"circuit": app.tidal.Client().CircuitStatus(),
+	}
+
+	login, found := app.tidal.DeviceLogin()
+	if found {
+		status["login"] = login
+	}
+
+	if cache := app.tidal.Client().Cache(); cache != nil {
+		counts, err := cache.Counts()
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		status["cachedResponses"] = counts
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"tidal": status}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) refreshTidalTokensHandler(w http.ResponseWriter, r *http.Request) {
+	client := app.tidal.Client()
+
+	err := client.RefreshTokens()
+	if err != nil {
+		app.tidalTokenRefreshFailedResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tokens": client.TokenStatus()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// startTidalLoginHandler starts tidal's device login. The admin completes it by visiting the verification url
+// and entering the user code, while the server polls tidal and stores the tokens once it's done.
+func (app *application) startTidalLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		ClientId string `json:"clientId"`
+		Secret   string `json:"secret"`
+	}
+
+	// the body is optional, without it the client of the current tokens is used
+	if r.ContentLength != 0 {
+		err := app.readJSON(w, r, &input)
+		if err != nil {
+			app.handleReadJSONError(w, r, err)
+			return
+		}
+	}
+
+	login, err := app.tidal.StartDeviceLogin(input.ClientId, input.Secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, tidal.ErrNoClientId):
+			app.failedValidationResponse(w, r, map[string]string{
+				"clientId": "must be provided when no tidal client is configured",
+			})
+		default:
+			app.tidalLoginFailedResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"login": login}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) getTidalLoginHandler(w http.ResponseWriter, r *http.Request) {
+	login, found := app.tidal.DeviceLogin()
+	if !found {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"login": login}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -11,9 +11,9 @@ func (app *application) viewAlbumHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	album, err := app.tidal.GetAlbum(id)
+	album, err := app.tidal.GetAlbum(id, app.requestRegion(r))
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.tidalErrorResponse(w, r, err)
 		return
 	}
 
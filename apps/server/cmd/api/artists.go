@@ -11,9 +11,9 @@ func (app *application) viewArtistHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	page, err := app.tidal.GetArtistPage(id)
+	page, err := app.tidal.GetArtistPage(id, app.requestRegion(r))
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.tidalErrorResponse(w, r, err)
 		return
 	}
 
@@ -36,9 +36,9 @@ func (app *application) viewArtistTopTracksHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
-	tracks, err := app.tidal.GetArtistTopTracks(id, page)
+	tracks, err := app.tidal.GetArtistTopTracks(id, page, app.requestRegion(r))
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.tidalErrorResponse(w, r, err)
 		return
 	}
 
@@ -61,9 +61,9 @@ func (app *application) viewArtistAlbumsHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	albums, err := app.tidal.GetArtistAlbums(id, page)
+	albums, err := app.tidal.GetArtistAlbums(id, page, app.requestRegion(r))
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.tidalErrorResponse(w, r, err)
 		return
 	}
 
@@ -86,9 +86,9 @@ func (app *application) viewArtistSinglesAndEpsHandler(w http.ResponseWriter, r
 		return
 	}
 
-	albums, err := app.tidal.GetArtistSinglesAndEps(id, page)
+	albums, err := app.tidal.GetArtistSinglesAndEps(id, page, app.requestRegion(r))
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.tidalErrorResponse(w, r, err)
 		return
 	}
 
@@ -111,9 +111,9 @@ func (app *application) viewArtistCompilationsHandler(w http.ResponseWriter, r *
 		return
 	}
 
-	compilations, err := app.tidal.GetArtistCompilations(id, page)
+	compilations, err := app.tidal.GetArtistCompilations(id, page, app.requestRegion(r))
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.tidalErrorResponse(w, r, err)
 		return
 	}
 
@@ -136,9 +136,9 @@ func (app *application) viewArtistAppearsOnHandler(w http.ResponseWriter, r *htt
 		return
 	}
 
-	albums, err := app.tidal.GetArtistAppearsOn(id, page)
+	albums, err := app.tidal.GetArtistAppearsOn(id, page, app.requestRegion(r))
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.tidalErrorResponse(w, r, err)
 		return
 	}
 
@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/ffmpeg"
+	"github.com/altierawr/oto/internal/profiles"
+	"github.com/altierawr/oto/internal/sessions"
+	"github.com/altierawr/oto/internal/tidal"
+	"github.com/altierawr/oto/internal/transcoder"
+	"github.com/google/uuid"
+)
+
+// growingFilePollInterval is how often a file that is still being transcoded is checked for new data
+const growingFilePollInterval = 250 * time.Millisecond
+
+// audioTranscode is a track being transcoded into a single file for /v1/tracks/:id/audio. Every request
+// for the same track and profile shares it until the transcode exits and the file is moved to the transcode cache.
+type audioTranscode struct {
+	key string
+	dir string
+	// the directory with only the output file, which is stored in the transcode cache
+	outputDir string
+	path      string
+	profile   *profiles.Profile
+	process   transcoder.Process
+	// closed once the transcode has exited
+	done chan struct{}
+	// the error the transcode exited with, only set once done is closed
+	err error
+}
+
+type audioTranscodes struct {
+	mu         sync.Mutex
+	transcodes map[string]*audioTranscode
+}
+
+func newAudioTranscodes() *audioTranscodes {
+	return &audioTranscodes{
+		transcodes: map[string]*audioTranscode{},
+	}
+}
+
+// getTrackAudioHandler serves the track as a single audio file in the requested profile for clients that
+// can't play HLS. Finished files support byte ranges, files that are still being transcoded are served as
+// they grow.
+func (app *application) getTrackAudioHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	// whole files are sent at the pace of the client and growing ones at the pace of the transcode, both take
+	// longer than the write timeout of the server
+	err = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	profile, err := app.resolveStreamProfile(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, profiles.ErrUnknownProfile):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	app.loudness.Enqueue(id)
+
+	gain := app.normalizationGain(*userId, id)
+	cacheProfile := "file:" + transcodeCacheProfile(profile, nil, gain)
+
+	if entry, found := app.cache.Lookup(id, cacheProfile); found {
+		file, err := app.cache.Open(entry, profile.FileName())
+		if err == nil {
+			defer file.Close()
+			app.serveAudioFile(w, r, file, profile)
+			return
+		}
+
+		app.logger.Error("couldn't open cached audio file",
+			"error", err.Error(),
+			"trackId", id,
+			"profile", cacheProfile)
+	}
+
+	transcode, file, err := app.getOrStartAudioTranscode(r.Context(), *userId, id, profile, gain, cacheProfile)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, ffmpeg.ErrUserLimit):
+			app.transcodeLimitExceededResponse(w, r)
+		case errors.Is(err, ffmpeg.ErrPoolFull):
+			app.transcodersBusyResponse(w, r)
+		case errors.Is(err, tidal.ErrUnavailable):
+			app.tidalUnavailableResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+	defer file.Close()
+
+	select {
+	case <-transcode.done:
+		if transcode.err != nil {
+			app.serverErrorResponse(w, r, transcode.err)
+			return
+		}
+
+		app.serveAudioFile(w, r, file, profile)
+	default:
+		app.serveGrowingAudioFile(w, r, file, transcode)
+	}
+}
+
+// getOrStartAudioTranscode returns the running transcode of the track and profile, starting one if there
+// isn't any, together with an open handle to its file. The handle stays readable after the transcode has
+// finished and its directory has been removed.
+func (app *application) getOrStartAudioTranscode(
+	ctx context.Context,
+	userId uuid.UUID,
+	trackId int64,
+	profile *profiles.Profile,
+	gain float64,
+	cacheProfile string,
+) (*audioTranscode, *os.File, error) {
+	key := fmt.Sprintf("%d:%s", trackId, cacheProfile)
+
+	transcode, file, err := app.openAudioTranscode(key)
+	if transcode != nil || err != nil {
+		return transcode, file, err
+	}
+
+	slot, err := app.transcoders.Acquire(ctx, userId.String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	started := false
+	defer func() {
+		if !started {
+			slot.Release()
+		}
+	}()
+
+	// another request might have started the transcode while this one was waiting for a slot
+	transcode, file, err = app.openAudioTranscode(key)
+	if transcode != nil || err != nil {
+		return transcode, file, err
+	}
+
+	source, err := app.tidal.Client().GetSongSource(trackId, profile.SourceQuality, app.userRegion(userId))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dir, err := sessions.CreateAudioDir()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outputDir := filepath.Join(dir, "output")
+	transcode = &audioTranscode{
+		key:       key,
+		dir:       dir,
+		outputDir: outputDir,
+		path:      filepath.Join(outputDir, profile.FileName()),
+		profile:   profile,
+		done:      make(chan struct{}),
+	}
+
+	err = os.Mkdir(outputDir, os.ModePerm)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	// the file is created up front so that requests can open it before anything has been written,
+	// the transcoder truncates it in place
+	file, err = os.Create(transcode.path)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	transcode.process, err = app.transcoder.Start(transcoder.Job{
+		Output:    transcoder.OutputFile,
+		Input:     source.Input(),
+		OutputDir: outputDir,
+		Profile:   profile,
+		Gain:      gain,
+	})
+	if err != nil {
+		file.Close()
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	started = true
+
+	app.audio.mu.Lock()
+	app.audio.transcodes[key] = transcode
+	app.audio.mu.Unlock()
+
+	app.logger.Info("audio file transcode started",
+		"trackId", trackId,
+		"profile", cacheProfile,
+		"sourceQuality", source.Quality)
+
+	app.background(func() {
+		for range transcode.process.Progress() {
+		}
+
+		waitErr := transcode.process.Wait()
+		slot.Release()
+
+		if waitErr != nil {
+			output := ""
+			var transcodeErr *transcoder.Error
+			if errors.As(waitErr, &transcodeErr) {
+				output = transcodeErr.Output
+			}
+
+			app.logger.Error("transcode failed",
+				"trackId", trackId,
+				"profile", cacheProfile,
+				"error", waitErr.Error(),
+				"output", output)
+			transcode.err = waitErr
+		} else {
+			err := app.cache.Store(trackId, cacheProfile, transcode.outputDir, 0)
+			if err != nil {
+				app.logger.Error("couldn't store audio file in cache",
+					"error", err.Error(),
+					"trackId", trackId,
+					"profile", cacheProfile)
+			}
+		}
+
+		close(transcode.done)
+		app.removeAudioTranscode(transcode)
+	})
+
+	return transcode, file, nil
+}
+
+// openAudioTranscode returns the running transcode with the key and opens its file, the transcode is nil
+// if there isn't one
+func (app *application) openAudioTranscode(key string) (*audioTranscode, *os.File, error) {
+	app.audio.mu.Lock()
+	defer app.audio.mu.Unlock()
+
+	transcode, exists := app.audio.transcodes[key]
+	if !exists {
+		return nil, nil, nil
+	}
+
+	// the directory is only removed after the transcode has been taken out of the map, so the file
+	// exists while the lock is held
+	file, err := os.Open(transcode.path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return transcode, file, nil
+}
+
+func (app *application) removeAudioTranscode(transcode *audioTranscode) {
+	app.audio.mu.Lock()
+	if app.audio.transcodes[transcode.key] == transcode {
+		delete(app.audio.transcodes, transcode.key)
+	}
+	app.audio.mu.Unlock()
+
+	err := os.RemoveAll(transcode.dir)
+	if err != nil {
+		app.logger.Error("couldn't delete audio transcode directory",
+			"error", err.Error(),
+			"path", transcode.dir)
+	}
+}
+
+// stopAudioTranscodes cancels the transcodes of the audio files that are still being transcoded
+func (app *application) stopAudioTranscodes() {
+	app.audio.mu.Lock()
+	transcodes := []*audioTranscode{}
+	for _, transcode := range app.audio.transcodes {
+		transcodes = append(transcodes, transcode)
+	}
+	app.audio.mu.Unlock()
+
+	for _, transcode := range transcodes {
+		err := transcode.process.Cancel()
+		if err != nil {
+			app.logger.Error("couldn't stop audio transcode",
+				"error", err.Error(),
+				"key", transcode.key)
+		}
+	}
+}
+
+// serveAudioFile serves a complete audio file with Content-Length and byte range support
+func (app *application) serveAudioFile(w http.ResponseWriter, r *http.Request, file *os.File, profile *profiles.Profile) {
+	w.Header().Set("Content-Type", profile.FileContentType)
+	http.ServeContent(w, r, profile.FileName(), time.Time{}, file)
+}
+
+// serveGrowingAudioFile serves a file that is still being transcoded. Without a range the whole file is sent
+// as it grows. A range is answered with the part of it that has been written so far, once the start of
+// the range is available, with an unknown complete length in Content-Range.
+func (app *application) serveGrowingAudioFile(
+	w http.ResponseWriter,
+	r *http.Request,
+	file *os.File,
+	transcode *audioTranscode,
+) {
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Type", transcode.profile.FileContentType)
+
+	start, end, hasRange := parseSingleByteRange(r.Header.Get("Range"))
+	if !hasRange {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		app.tailGrowingFile(w, r, file, transcode)
+		return
+	}
+
+	size, finished := waitForFileSize(r.Context(), file, transcode, start+1)
+	if finished {
+		if transcode.err != nil {
+			// the file is incomplete, don't send it as if it was the whole track
+			panic(http.ErrAbortHandler)
+		}
+
+		app.serveAudioFile(w, r, file, transcode.profile)
+		return
+	}
+
+	if size <= start {
+		// the request was cancelled while waiting
+		return
+	}
+
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/*", start, end))
+	w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+	w.WriteHeader(http.StatusPartialContent)
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	_, err := io.Copy(w, io.NewSectionReader(file, start, end-start+1))
+	if err != nil {
+		app.logger.Debug("couldn't send audio file range",
+			"error", err.Error(),
+			"key", transcode.key)
+	}
+}
+
+// tailGrowingFile copies the file to w, waiting for more data until the transcode has finished
+func (app *application) tailGrowingFile(w http.ResponseWriter, r *http.Request, file *os.File, transcode *audioTranscode) {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	offset := int64(0)
+
+	for {
+		n, err := file.ReadAt(buf, offset)
+		if n > 0 {
+			_, writeErr := w.Write(buf[:n])
+			if writeErr != nil {
+				return
+			}
+
+			offset += int64(n)
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+
+			continue
+		}
+
+		if err != nil && !errors.Is(err, io.EOF) {
+			app.logger.Error("couldn't read growing audio file",
+				"error", err.Error(),
+				"key", transcode.key)
+			return
+		}
+
+		select {
+		case <-transcode.done:
+			// the transcoder might have written the rest of the file right before exiting
+			info, err := file.Stat()
+			if err != nil || info.Size() <= offset {
+				if transcode.err != nil {
+					// close the connection so the client sees a truncated response instead of a complete file
+					panic(http.ErrAbortHandler)
+				}
+				return
+			}
+		case <-r.Context().Done():
+			return
+		case <-time.After(growingFilePollInterval):
+		}
+	}
+}
+
+// waitForFileSize waits until the file is at least size bytes long or the transcode has finished. It
+// returns the current size and whether the transcode has finished.
+func waitForFileSize(ctx context.Context, file *os.File, transcode *audioTranscode, size int64) (int64, bool) {
+	for {
+		select {
+		case <-transcode.done:
+			return 0, true
+		default:
+		}
+
+		info, err := file.Stat()
+		if err == nil && info.Size() >= size {
+			return info.Size(), false
+		}
+
+		select {
+		case <-transcode.done:
+			return 0, true
+		case <-ctx.Done():
+			return 0, false
+		case <-time.After(growingFilePollInterval):
+		}
+	}
+}
+
+// parseSingleByteRange parses a Range header with a single "bytes=start-" or "bytes=start-end" range. The
+// end is -1 if it's open. Suffix ranges and multiple ranges aren't supported for growing files since their
+// length isn't known yet.
+func parseSingleByteRange(header string) (int64, int64, bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+
+	startStr, endStr, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found || startStr == "" {
+		return 0, 0, false
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+
+	if endStr == "" {
+		return start, -1, true
+	}
+
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
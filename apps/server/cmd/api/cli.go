@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/tidal"
+)
+
+// runCommand runs the subcommand given on the command line instead of the server
+func runCommand(db *database.DB, logger *slog.Logger, args []string) error {
+	switch args[0] {
+	case "tidal-login":
+		return runTidalLogin(db, logger, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, expected tidal-login", args[0])
+	}
+}
+
+// runTidalLogin logs in to tidal with the device login and stores the tokens, so that the server can be started
+// without any tidal env variables. A server that's already running picks the stored tokens up the next time it
+// refreshes its own.
+func runTidalLogin(db *database.DB, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("tidal-login", flag.ContinueOnError)
+	clientId := flags.String("client-id", os.Getenv("TIDAL_CLIENT_ID"), "client id of tidal's application, defaults to TIDAL_CLIENT_ID or the stored client")
+	secret := flags.String("secret", os.Getenv("TIDAL_SECRET"), "secret of tidal's application, defaults to TIDAL_SECRET or the stored client")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	client, err := tidal.NewClient(tidal.Config{
+		AuthBaseURL: os.Getenv("TIDAL_AUTH_URL"),
+		Store:       tidal.NewDBTokenStore(db, "default"),
+		Logger:      logger,
+	})
+	if err != nil {
+		return err
+	}
+
+	login, err := client.StartDeviceLogin(*clientId, *secret)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Visit %s and enter the code %s to log in to tidal.\n", login.VerificationURL, login.UserCode)
+	if login.VerificationURLComplete != "" {
+		fmt.Printf("Or visit %s to skip entering the code.\n", login.VerificationURLComplete)
+	}
+	fmt.Printf("Waiting until %s...\n", login.ExpiresAt.Format("15:04:05"))
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	err = client.CompleteDeviceLogin(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Logged in to tidal, the tokens have been stored.")
+
+	return nil
+}
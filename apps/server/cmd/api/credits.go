@@ -0,0 +1,73 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/altierawr/oto/internal/database"
+)
+
+func (app *application) getTrackCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	credits, err := app.tidal.GetTrackCredits(id, app.requestRegion(r))
+	if err != nil {
+		app.tidalErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"credits": credits}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// getAlbumCreditsHandler returns the tracks of the album, each with its credits
+func (app *application) getAlbumCreditsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	tracks, err := app.tidal.GetAlbumCredits(id, app.requestRegion(r))
+	if err != nil {
+		app.tidalErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"tracks": tracks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// viewContributorHandler returns the stored tracks that the contributor is credited on. Only the tracks whose
+// credits have been fetched before are known, there's no way to list all the work of a contributor on tidal.
+func (app *application) viewContributorHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	contributor, tracks, err := app.db.GetTidalContributorTracks(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"contributor": contributor, "tracks": tracks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
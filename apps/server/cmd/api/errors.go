@@ -3,7 +3,14 @@ package main
 import (
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/tidal"
+	"github.com/altierawr/oto/internal/waveform"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -74,6 +81,30 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 	app.errorResponse(w, r, http.StatusTooManyRequests, "rate limit exceeded")
 }
 
+func (app *application) transcodeLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "5")
+
+	message := "too many streams are being transcoded for your account, end a stream or try again later"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+// waveformFailedResponse responds to a track whose waveform couldn't be extracted, with when it will be
+// tried again
+func (app *application) waveformFailedResponse(w http.ResponseWriter, r *http.Request, failed *waveform.FailedError) {
+	retryAfter := int(math.Ceil(time.Until(failed.RetryAt).Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(retryAfter, 1)))
+
+	message := "the waveform of the track couldn't be extracted, please try again later"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+}
+
+func (app *application) transcodersBusyResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "5")
+
+	message := "the server is busy transcoding other streams, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	app.errorResponse(w, r, http.StatusForbidden, "invalid authentication credentials")
 }
@@ -99,3 +130,54 @@ func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Requ
 	message := "your user account doesn't have the necessary permissions to access this resource"
 	app.errorResponse(w, r, http.StatusForbidden, message)
 }
+
+func (app *application) tidalLyricsExistResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the track already has lyrics from tidal"
+	app.errorResponse(w, r, http.StatusConflict, message)
+}
+
+func (app *application) tidalTokenRefreshFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+
+	message := fmt.Sprintf("couldn't refresh the tidal tokens: %s", err.Error())
+	app.errorResponse(w, r, http.StatusBadGateway, message)
+}
+
+// tidalErrorResponse responds to a failed tidal call, telling apart what tidal doesn't have from tidal being
+// unavailable
+func (app *application) tidalErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, database.ErrRecordNotFound):
+		app.notFoundResponse(w, r)
+	case errors.Is(err, tidal.ErrUnavailable):
+		app.tidalUnavailableResponse(w, r, err)
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) tidalUnavailableResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+
+	retryAfter := int(math.Ceil(tidal.RetryAfter(err).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 5
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+
+	message := "tidal can't be reached right now, please try again later"
+
+	var apiErr *tidal.APIError
+	if errors.As(err, &apiErr) && apiErr.Kind == tidal.ErrorAuthExpired {
+		message = "the server isn't logged in to tidal, an admin has to log in again"
+	}
+
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
+func (app *application) tidalLoginFailedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logError(r, err)
+
+	message := fmt.Sprintf("couldn't start the tidal login: %s", err.Error())
+	app.errorResponse(w, r, http.StatusBadGateway, message)
+}
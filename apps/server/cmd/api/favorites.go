@@ -5,7 +5,6 @@ import (
 	"net/http"
 
 	"github.com/altierawr/oto/internal/database"
-	"github.com/altierawr/oto/internal/tidal"
 )
 
 func (app *application) toggleFavoriteArtistHandler(w http.ResponseWriter, r *http.Request) {
@@ -34,9 +33,9 @@ func (app *application) toggleFavoriteArtistHandler(w http.ResponseWriter, r *ht
 	if isFavorited {
 		err = app.db.RemoveFavoriteArtist(*userId, input.ID)
 	} else {
-		artist, err := tidal.GetArtistBasicInfo(input.ID)
+		artist, err := app.tidal.Client().GetArtistBasicInfo(input.ID, app.requestRegion(r))
 		if err != nil {
-			app.serverErrorResponse(w, r, err)
+			app.tidalErrorResponse(w, r, err)
 			return
 		}
 		if artist == nil || int64(artist.ID) != input.ID {
@@ -84,9 +83,9 @@ func (app *application) toggleFavoriteAlbumHandler(w http.ResponseWriter, r *htt
 	if isFavorited {
 		err = app.db.RemoveFavoriteAlbum(*userId, input.ID)
 	} else {
-		album, err := app.tidal.GetAlbum(input.ID)
+		album, err := app.tidal.GetAlbum(input.ID, app.requestRegion(r))
 		if err != nil {
-			app.serverErrorResponse(w, r, err)
+			app.tidalErrorResponse(w, r, err)
 			return
 		}
 		if album == nil || int64(album.ID) != input.ID {
@@ -148,9 +147,9 @@ func (app *application) toggleFavoriteTrackHandler(w http.ResponseWriter, r *htt
 		}
 
 		if err != nil || track == nil {
-			track, err = app.tidal.GetSong(input.ID)
+			track, err = app.tidal.GetSong(input.ID, app.requestRegion(r))
 			if err != nil {
-				app.serverErrorResponse(w, r, err)
+				app.tidalErrorResponse(w, r, err)
 				return
 			}
 		}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/altierawr/oto/internal/tidal"
 	"github.com/altierawr/oto/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -28,6 +29,18 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+// readPlaylistUUIDParam reads the uuid of a tidal playlist from the url
+func (app *application) readPlaylistUUIDParam(r *http.Request) (string, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	playlistUUID := params.ByName("uuid")
+	if !validator.Matches(playlistUUID, tidal.PlaylistUUIDRX) {
+		return "", errors.New("invalid uuid parameter")
+	}
+
+	return playlistUUID, nil
+}
+
 func (app *application) readIntQueryOrZero(r *http.Request, name string) (int, error) {
 	queryStr := r.URL.Query().Get("page")
 	result := 0
@@ -178,6 +191,21 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+// requestBaseURL returns the scheme and host that urls handed to the client are built with. It's the configured
+// public url, or the scheme and host that the request was made to.
+func (app *application) requestBaseURL(r *http.Request) string {
+	if app.config.publicURL != "" {
+		return app.config.publicURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, r.Host)
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/lyrics"
+	"github.com/altierawr/oto/internal/tidal"
+)
+
+// maxLyricsSize is the largest .lrc file that can be uploaded
+const maxLyricsSize = 256 * 1024
+
+func (app *application) getTrackLyricsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	trackLyrics, err := app.tidal.GetLyrics(id, app.requestRegion(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, tidal.ErrNoLyrics):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, tidal.ErrUnavailable):
+			app.tidalUnavailableResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"lyrics": trackLyrics}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// uploadTrackLyricsHandler stores the lyrics in the request body for a track that Tidal has no lyrics for. The
+// body is an .lrc file, or plain text for unsynced lyrics. Lyrics uploaded by another user can only be replaced
+// by an admin.
+func (app *application) uploadTrackLyricsHandler(w http.ResponseWriter, r *http.Request) {
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxLyricsSize)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			app.badRequestResponse(w, r, errors.New("lyrics must not be larger than 256 KB"))
+			return
+		}
+
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	content := strings.TrimSpace(string(body))
+	if content == "" || !utf8.ValidString(content) {
+		app.badRequestResponse(w, r, errors.New("lyrics must be non-empty UTF-8 text"))
+		return
+	}
+
+	_, err = app.tidal.GetLyrics(id, app.requestRegion(r))
+	if err != nil && !errors.Is(err, tidal.ErrNoLyrics) {
+		app.tidalErrorResponse(w, r, err)
+		return
+	}
+
+	stored, err := app.db.GetTidalTrackLyrics(id)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if stored != nil {
+		switch {
+		case stored.Source == database.LyricsSourceTidal:
+			app.tidalLyricsExistResponse(w, r)
+			return
+		case stored.Source == database.LyricsSourceUser && !app.canChangeUserLyrics(r, stored):
+			app.notPermittedResponse(w, r)
+			return
+		}
+	}
+
+	// files without time tags are stored as unsynced lyrics
+	var text, subtitles *string
+	if _, err := lyrics.ParseLRC(content); err == nil {
+		subtitles = &content
+	} else {
+		text = &content
+	}
+
+	err = app.db.UpsertUserTrackLyrics(id, *userId, text, subtitles)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			app.tidalLyricsExistResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	stored, err = app.db.GetTidalTrackLyrics(id)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	trackLyrics, err := tidal.StoredLyrics(stored)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"lyrics": trackLyrics}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteTrackLyricsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	stored, err := app.db.GetTidalTrackLyrics(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	if stored.Source != database.LyricsSourceUser {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	if !app.canChangeUserLyrics(r, stored) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	err = app.db.DeleteUserTrackLyrics(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, nil, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// canChangeUserLyrics reports whether the user of the request uploaded the lyrics or is an admin
+func (app *application) canChangeUserLyrics(r *http.Request, stored *database.TrackLyrics) bool {
+	if app.contextGetUserRole(r) == UserRoleAdmin {
+		return true
+	}
+
+	userId := app.contextGetUserId(r)
+	return userId != nil && stored.UploadedBy != nil && *stored.UploadedBy == *userId
+}
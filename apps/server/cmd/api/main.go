@@ -3,16 +3,25 @@ package main
 import (
 	"fmt"
 	"log/slog"
+	"net/url"
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/altierawr/oto/internal/auth"
+	"github.com/altierawr/oto/internal/cache"
 	"github.com/altierawr/oto/internal/data"
 	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/ffmpeg"
+	"github.com/altierawr/oto/internal/loudness"
 	"github.com/altierawr/oto/internal/recommendations"
 	"github.com/altierawr/oto/internal/sessions"
 	"github.com/altierawr/oto/internal/tidal"
+	"github.com/altierawr/oto/internal/transcoder"
+	"github.com/altierawr/oto/internal/waveform"
 	"github.com/joho/godotenv"
 	"github.com/lmittmann/tint"
 	"github.com/twoscott/gobble-fm/api"
@@ -26,6 +35,10 @@ type config struct {
 		refreshToken string
 		clientId     string
 		secret       string
+		apiBaseURL   string
+		authBaseURL  string
+		countryCode  string
+		locale       string
 	}
 	lastFm struct {
 		apiKey string
@@ -39,18 +52,38 @@ type config struct {
 		burst   int
 		enabled bool
 	}
+	transcodeCache struct {
+		dir     string
+		maxSize int64
+	}
+	transcoders struct {
+		name         string
+		maxWorkers   int
+		maxPerUser   int
+		queueTimeout time.Duration
+	}
+	streamIdleTimeout time.Duration
+	// publicURL is the scheme and host that clients reach the server at, empty to use the ones of the request
+	publicURL string
 }
 
 type application struct {
-	config   config
-	logger   *slog.Logger
-	auth     auth.AuthService
-	wg       sync.WaitGroup
-	db       *database.DB
-	lastFm   *api.Client
-	recs     *recommendations.Service
-	sessions *sessions.Service
-	tidal    *tidal.Service
+	config      config
+	audio       *audioTranscodes
+	logger      *slog.Logger
+	auth        auth.AuthService
+	cache       *cache.Service
+	transcoder  transcoder.Transcoder
+	transcoders *ffmpeg.Pool
+	wg          sync.WaitGroup
+	db          *database.DB
+	lastFm      *api.Client
+	loudness    *loudness.Service
+	radio       *radioStations
+	recs        *recommendations.Service
+	sessions    *sessions.Service
+	tidal       *tidal.Service
+	waveforms   *waveform.Service
 }
 
 func main() {
@@ -76,35 +109,57 @@ func main() {
 		os.Exit(1)
 	}
 
-	var cfg config
+	if len(os.Args) > 1 {
+		err = runCommand(db, logger, os.Args[1:])
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
 
-	found := false
-	cfg.tidal.accessToken, found = os.LookupEnv("TIDAL_ACCESS_TOKEN")
-	if !found {
-		logger.Error("missing env variable TIDAL_ACCESS_TOKEN")
-		os.Exit(1)
+		return
 	}
 
-	cfg.tidal.refreshToken, found = os.LookupEnv("TIDAL_REFRESH_TOKEN")
-	if !found {
-		logger.Error("missing env variable TIDAL_ACCESS_TOKEN")
-		os.Exit(1)
-	}
+	var cfg config
 
-	cfg.tidal.clientId, found = os.LookupEnv("TIDAL_CLIENT_ID")
-	if !found {
-		logger.Error("missing env variable TIDAL_ACCESS_TOKEN")
+	// the tidal tokens are optional since they're stored once an admin has logged in to tidal
+	cfg.tidal.accessToken = os.Getenv("TIDAL_ACCESS_TOKEN")
+	cfg.tidal.refreshToken = os.Getenv("TIDAL_REFRESH_TOKEN")
+	cfg.tidal.clientId = os.Getenv("TIDAL_CLIENT_ID")
+	cfg.tidal.secret = os.Getenv("TIDAL_SECRET")
+
+	// a local stand-in for tidal can be used by pointing these at it
+	cfg.tidal.apiBaseURL = os.Getenv("TIDAL_API_URL")
+	cfg.tidal.authBaseURL = os.Getenv("TIDAL_AUTH_URL")
+
+	cfg.tidal.countryCode, cfg.tidal.locale, err = getTidalRegionConfig()
+	if err != nil {
+		logger.Error(err.Error())
 		os.Exit(1)
 	}
 
-	cfg.tidal.secret, found = os.LookupEnv("TIDAL_SECRET")
-	if !found {
-		logger.Error("missing env variable TIDAL_ACCESS_TOKEN")
+	tidalClient, err := tidal.NewClient(tidal.Config{
+		APIBaseURL:   cfg.tidal.apiBaseURL,
+		AuthBaseURL:  cfg.tidal.authBaseURL,
+		CountryCode:  cfg.tidal.countryCode,
+		Locale:       cfg.tidal.locale,
+		ClientId:     cfg.tidal.clientId,
+		Secret:       cfg.tidal.secret,
+		AccessToken:  cfg.tidal.accessToken,
+		RefreshToken: cfg.tidal.refreshToken,
+		Store:        tidal.NewDBTokenStore(db, "default"),
+		Cache:        tidal.NewCache(db, logger, nil),
+		Logger:       logger,
+	})
+	if err != nil {
+		logger.Error(err.Error())
 		os.Exit(1)
 	}
 
-	tidal.SetTokens(cfg.tidal.accessToken, cfg.tidal.refreshToken, cfg.tidal.clientId, cfg.tidal.secret)
+	if !tidalClient.LoggedIn() {
+		logger.Warn("not logged in to tidal, log in with the tidal-login command or POST /v1/admin/tidal/login")
+	}
 
+	found := false
 	cfg.secrets.accessToken, found = os.LookupEnv("ACCESS_TOKEN_SECRET")
 	if !found {
 		logger.Error("missing env variable TIDAL_ACCESS_TOKEN")
@@ -136,6 +191,56 @@ func main() {
 		os.Exit(1)
 	}
 
+	cfg.publicURL, err = getPublicURL()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	cfg.transcodeCache.dir, cfg.transcodeCache.maxSize, err = getTranscodeCacheConfig()
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	cfg.transcoders.name = os.Getenv("TRANSCODER")
+
+	cfg.transcoders.maxWorkers, err = getIntEnv("TRANSCODE_MAX_WORKERS", runtime.NumCPU(), 1)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	cfg.transcoders.maxPerUser, err = getIntEnv("TRANSCODE_MAX_PER_USER", 2, 0)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	queueTimeoutSeconds, err := getIntEnv("TRANSCODE_QUEUE_TIMEOUT_SECONDS", 15, 0)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	cfg.transcoders.queueTimeout = time.Duration(queueTimeoutSeconds) * time.Second
+
+	idleTimeoutMinutes, err := getIntEnv("STREAM_IDLE_TIMEOUT_MINUTES", 10, 0)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	cfg.streamIdleTimeout = time.Duration(idleTimeoutMinutes) * time.Minute
+
+	streamTranscoder, err := transcoder.New(cfg.transcoders.name)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	if cfg.transcoders.name == "fake" {
+		logger.Warn("using the fake transcoder, streams won't contain any audio")
+	}
+
 	app := &application{
 		logger: logger,
 		config: cfg,
@@ -143,9 +248,13 @@ func main() {
 		auth: auth.AuthService{
 			DB: db,
 		},
+		audio:       newAudioTranscodes(),
+		radio:       newRadioStations(),
+		transcoder:  streamTranscoder,
+		transcoders: ffmpeg.NewPool(cfg.transcoders.maxWorkers, cfg.transcoders.maxPerUser, cfg.transcoders.queueTimeout),
 	}
 
-	app.tidal = tidal.New(app.db, app.logger)
+	app.tidal = tidal.New(app.db, app.logger, tidalClient)
 	app.background(app.tidal.RunBackground)
 
 	cfg.lastFm.apiKey, found = os.LookupEnv("LASTFM_API_KEY")
@@ -158,9 +267,30 @@ func main() {
 		app.background(app.recs.Run)
 	}
 
-	app.sessions = sessions.New(app.db, app.logger)
+	app.sessions = sessions.New(app.db, app.logger, cfg.streamIdleTimeout)
+	app.sessions.CleanupOrphans()
 	app.background(app.sessions.RunBackground)
 
+	app.cache, err = cache.New(app.db, app.logger, cfg.transcodeCache.dir, cfg.transcodeCache.maxSize)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+	app.background(app.cache.RunBackground)
+
+	app.loudness = loudness.New(app.db, app.logger, app.transcoders, tidalClient)
+	app.background(app.loudness.Run)
+
+	// the loudness of a track is measured once it's played so that later streams can be normalized
+	app.sessions.Streams().Subscribe(func(event sessions.StreamEvent) {
+		if event.Type == sessions.StreamStarted {
+			app.loudness.Enqueue(event.TrackId)
+		}
+	})
+
+	app.waveforms = waveform.New(app.db, app.logger, app.transcoders, tidalClient)
+	app.background(app.waveforms.Run)
+
 	createdAdmin, err := createAdminUser(app)
 	if err != nil {
 		logger.Error(err.Error())
@@ -198,6 +328,89 @@ func getPort() (int, error) {
 	return port, nil
 }
 
+// getPublicURL reads the url that the server is reached at, which the urls of streams are built from. Headers
+// of reverse proxies aren't trusted for it since any client can send them.
+func getPublicURL() (string, error) {
+	raw := os.Getenv("PUBLIC_URL")
+	if raw == "" {
+		return "", nil
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", fmt.Errorf("invalid PUBLIC_URL value %q: expected a url such as https://oto.example.com", raw)
+	}
+
+	return strings.TrimSuffix(raw, "/"), nil
+}
+
+// getIntEnv reads an integer env variable that has to be at least min, returning defaultValue if it's not set
+func getIntEnv(name string, defaultValue int, min int) (int, error) {
+	raw, found := os.LookupEnv(name)
+	if !found {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q: expected integer", name, raw)
+	}
+
+	if value < min {
+		return 0, fmt.Errorf("invalid %s value %q: must be at least %d", name, raw, min)
+	}
+
+	return value, nil
+}
+
+func getTranscodeCacheConfig() (string, int64, error) {
+	const defaultMaxSizeMB = 5120
+
+	dir, found := os.LookupEnv("TRANSCODE_CACHE_DIR")
+	if !found {
+		defaultDir, err := cache.DefaultDir()
+		if err != nil {
+			return "", 0, err
+		}
+
+		dir = defaultDir
+	}
+
+	maxSizeMB := int64(defaultMaxSizeMB)
+
+	rawMaxSize, found := os.LookupEnv("TRANSCODE_CACHE_MAX_MB")
+	if found {
+		parsed, err := strconv.ParseInt(rawMaxSize, 10, 64)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid TRANSCODE_CACHE_MAX_MB value %q: expected integer", rawMaxSize)
+		}
+
+		if parsed < 0 {
+			return "", 0, fmt.Errorf("invalid TRANSCODE_CACHE_MAX_MB value %q: must not be negative", rawMaxSize)
+		}
+
+		maxSizeMB = parsed
+	}
+
+	return dir, maxSizeMB * 1024 * 1024, nil
+}
+
+// getTidalRegionConfig reads the country and locale that tidal is called with for users who haven't picked
+// their own, empty for tidal's defaults
+func getTidalRegionConfig() (string, string, error) {
+	countryCode := os.Getenv("TIDAL_COUNTRY_CODE")
+	if countryCode != "" && !tidal.CountryCodeRX.MatchString(countryCode) {
+		return "", "", fmt.Errorf("invalid TIDAL_COUNTRY_CODE value %q: expected a two letter country code such as US", countryCode)
+	}
+
+	locale := os.Getenv("TIDAL_LOCALE")
+	if locale != "" && !tidal.LocaleRX.MatchString(locale) {
+		return "", "", fmt.Errorf("invalid TIDAL_LOCALE value %q: expected a locale such as en_US", locale)
+	}
+
+	return countryCode, locale, nil
+}
+
 func createAdminUser(app *application) (bool, error) {
 	admins, err := app.db.GetAdminUsers()
 	if err != nil {
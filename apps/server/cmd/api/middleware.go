@@ -11,6 +11,7 @@ import (
 	"github.com/altierawr/oto/internal/data"
 	"github.com/altierawr/oto/internal/validator"
 	"github.com/google/uuid"
+	"github.com/julienschmidt/httprouter"
 	"github.com/tomasen/realip"
 	"golang.org/x/time/rate"
 )
@@ -162,6 +163,41 @@ func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.Han
 	})
 }
 
+// requireStreamAccess allows authenticated users and requests carrying a signed stream query, which is
+// how HLS players that can't send cookies fetch the files listed in playlist.m3u8
+func (app *application) requireStreamAccess(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !query.Has("sig") {
+			app.requireAuthenticatedUser(next).ServeHTTP(w, r)
+			return
+		}
+
+		params := httprouter.ParamsFromContext(r.Context())
+
+		access, err := auth.ValidateStreamQuery(query, params.ByName("id"))
+		if err != nil {
+			switch {
+			case errors.Is(err, auth.ErrTokenExpired):
+				app.authenticationTokenExpiredResponse(w, r)
+			default:
+				app.invalidAuthenticationTokenResponse(w, r)
+			}
+
+			return
+		}
+
+		r = app.contextSetUserId(r, &access.UserId)
+		r = app.contextSetSessionId(r, &access.SessionId)
+
+		if app.contextGetUserRole(r) == UserRoleAnonymous {
+			r = app.contextSetUserRole(r, UserRoleUser)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (app *application) requireAdminUser(next http.HandlerFunc) http.HandlerFunc {
 	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		role := app.contextGetUserRole(r)
@@ -225,9 +225,9 @@ func (app *application) addTrackToPlaylistHandler(w http.ResponseWriter, r *http
 	}
 
 	if err != nil || track == nil {
-		track, err = app.tidal.GetSong(input.TrackID)
+		track, err = app.tidal.GetSong(input.TrackID, app.requestRegion(r))
 		if err != nil {
-			app.serverErrorResponse(w, r, err)
+			app.tidalErrorResponse(w, r, err)
 			return
 		}
 	}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"math"
+
+	"github.com/altierawr/oto/internal/data"
+	"github.com/altierawr/oto/internal/profiles"
+	"github.com/altierawr/oto/internal/sessions"
+	"github.com/altierawr/oto/internal/transcoder"
+	"github.com/google/uuid"
+)
+
+// prefetchSegmentsBeforeEnd is how many segments before the end of a stream the next track is prefetched.
+// Segments are one second long.
+const prefetchSegmentsBeforeEnd = 20
+
+// maybePrefetchNextTrack starts preparing the next track in the session queue once the client requests one
+// of the last segments of the stream. While the stream is still being transcoded, its last segments are
+// worked out from the length of the track.
+func (app *application) maybePrefetchNextTrack(userId uuid.UUID, sessionId uuid.UUID, streamId string, segmentNr int) {
+	shouldPrefetch := false
+	var trackId int64
+	var queueIndex int
+	var profile *profiles.Profile
+	var variants []*profiles.Profile
+
+	app.sessions.Streams().Update(sessionId, streamId, func(stream *sessions.Stream) {
+		total := expectedSegments(stream)
+		if stream.PrefetchedNext || total == -1 || total-segmentNr > prefetchSegmentsBeforeEnd {
+			return
+		}
+
+		stream.PrefetchedNext = true
+		shouldPrefetch = true
+		trackId = stream.TrackId
+		queueIndex = stream.QueueIndex
+		profile = stream.Profile
+		variants = stream.Variants
+	})
+
+	if !shouldPrefetch {
+		return
+	}
+
+	app.background(func() {
+		app.prefetchNextTrack(userId, sessionId, trackId, queueIndex, profile, variants)
+	})
+}
+
+// expectedSegments returns the number of segments of the stream. Until the transcode has finished, it's
+// estimated from the length of the track, and -1 if that isn't known.
+func expectedSegments(stream *sessions.Stream) int {
+	if stream.NrSegments != -1 {
+		return stream.NrSegments
+	}
+
+	if stream.Duration <= 0 {
+		return -1
+	}
+
+	return int(math.Ceil((stream.Duration - stream.SeekOffset) / transcoder.SegmentDuration))
+}
+
+func (app *application) prefetchNextTrack(
+	userId uuid.UUID,
+	sessionId uuid.UUID,
+	currentTrackId int64,
+	currentQueueIndex int,
+	profile *profiles.Profile,
+	variants []*profiles.Profile,
+) {
+	session, err := app.db.GetSession(userId, sessionId)
+	if err != nil {
+		app.logger.Error("couldn't get session for prefetching",
+			"error", err.Error(),
+			"sessionId", sessionId)
+		return
+	}
+
+	queueIndex := nextQueueIndex(session.Tracks, currentTrackId, currentQueueIndex)
+	if queueIndex == -1 {
+		return
+	}
+	nextTrackId := int64(session.Tracks[queueIndex].ID)
+
+	profileKey := transcodeCacheProfile(profile, variants, app.normalizationGain(userId, nextTrackId))
+	streams := app.sessions.Streams()
+
+	prefetch, exists := streams.Prefetch(sessionId)
+	if exists && prefetch.TrackId == nextTrackId && prefetch.QueueIndex == queueIndex && prefetch.ProfileKey == profileKey {
+		return
+	}
+
+	placeholder := &sessions.Prefetch{
+		TrackId:    nextTrackId,
+		QueueIndex: queueIndex,
+		ProfileKey: profileKey,
+	}
+
+	replaced, exists := streams.SetPrefetch(sessionId, placeholder)
+	if exists {
+		app.endPrefetchedStream(sessionId, replaced)
+	}
+
+	app.logger.Info("prefetching next track",
+		"sessionId", sessionId,
+		"trackId", nextTrackId,
+		"queueIndex", queueIndex,
+		"profile", profileKey)
+
+	streamId, _, err := app.createStream(context.Background(), userId, &sessionId, nextTrackId, queueIndex, 0, profile, variants, true)
+	if err != nil {
+		app.logger.Error("couldn't prefetch next track",
+			"error", err.Error(),
+			"sessionId", sessionId,
+			"trackId", nextTrackId)
+
+		streams.RemovePrefetch(sessionId, placeholder)
+		return
+	}
+
+	if !streams.CompletePrefetch(sessionId, placeholder, streamId) {
+		app.logger.Info("prefetch was cancelled while starting",
+			"sessionId", sessionId,
+			"streamId", streamId)
+
+		err = streams.End(sessionId, streamId)
+		if err != nil {
+			app.logger.Error("couldn't end prefetched stream",
+				"error", err.Error(),
+				"sessionId", sessionId,
+				"streamId", streamId)
+		}
+	}
+}
+
+// nextQueueIndex returns the index of the entry after the one that the stream plays, or -1 if there is none.
+// Streams that don't know their queue index only prefetch when the track is in the queue once, since any
+// other entry might be the one that is playing.
+func nextQueueIndex(tracks []data.SessionTrack, trackId int64, queueIndex int) int {
+	if queueIndex < 0 {
+		for i, track := range tracks {
+			if int64(track.ID) != trackId {
+				continue
+			}
+
+			if queueIndex >= 0 {
+				return -1
+			}
+			queueIndex = i
+		}
+	}
+
+	if queueIndex < 0 || queueIndex >= len(tracks) || int64(tracks[queueIndex].ID) != trackId {
+		return -1
+	}
+
+	if queueIndex+1 >= len(tracks) {
+		return -1
+	}
+
+	return queueIndex + 1
+}
+
+// claimPrefetchedStream hands over the prefetched stream of the session if it was prepared for the track at
+// the queue index and the profile
+func (app *application) claimPrefetchedStream(sessionId *uuid.UUID, trackId int64, queueIndex int, profileKey string) (string, bool) {
+	streamId, claimed := app.sessions.Streams().ClaimPrefetch(*sessionId, trackId, queueIndex, profileKey)
+	if !claimed {
+		return "", false
+	}
+
+	app.logger.Info("using prefetched stream",
+		"sessionId", sessionId,
+		"streamId", streamId,
+		"trackId", trackId)
+
+	return streamId, true
+}
+
+// cancelPrefetch ends the prefetched stream of the session, if there is one
+func (app *application) cancelPrefetch(sessionId uuid.UUID) {
+	prefetch, exists := app.sessions.Streams().TakePrefetch(sessionId)
+	if exists {
+		app.endPrefetchedStream(sessionId, prefetch)
+	}
+}
+
+// endPrefetchedStream ends the stream of a prefetch that was removed from its session
+func (app *application) endPrefetchedStream(sessionId uuid.UUID, prefetch sessions.Prefetch) {
+	// the stream is still being started, prefetchNextTrack ends it once it notices the cancellation
+	if prefetch.StreamId == "" {
+		return
+	}
+
+	app.logger.Info("cancelling prefetched stream",
+		"sessionId", sessionId,
+		"streamId", prefetch.StreamId,
+		"trackId", prefetch.TrackId)
+
+	err := app.sessions.Streams().End(sessionId, prefetch.StreamId)
+	if err != nil {
+		app.logger.Error("couldn't end prefetched stream",
+			"error", err.Error(),
+			"sessionId", sessionId,
+			"streamId", prefetch.StreamId)
+	}
+}
+
+// invalidatePrefetch cancels the prefetched stream of the session after its queue has changed and allows
+// the streams of the session to prefetch the new next track
+func (app *application) invalidatePrefetch(sessionId uuid.UUID) {
+	app.cancelPrefetch(sessionId)
+
+	app.sessions.Streams().UpdateSession(sessionId, func(stream *sessions.Stream) {
+		stream.PrefetchedNext = false
+	})
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/altierawr/oto/internal/sessions"
+)
+
+func TestExpectedSegments(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   sessions.Stream
+		expected int
+	}{
+		{"finished", sessions.Stream{NrSegments: 180, Duration: 200}, 180},
+		{"transcoding", sessions.Stream{NrSegments: -1, Duration: 200}, 200},
+		{"transcoding after a seek", sessions.Stream{NrSegments: -1, Duration: 200, SeekOffset: 50.5}, 150},
+		{"unknown duration", sessions.Stream{NrSegments: -1}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := expectedSegments(&tt.stream); got != tt.expected {
+				t.Errorf("got %d segments, want %d", got, tt.expected)
+			}
+		})
+	}
+}
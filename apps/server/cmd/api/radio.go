@@ -0,0 +1,441 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/tidal"
+	"github.com/altierawr/oto/internal/types"
+	"github.com/google/uuid"
+)
+
+const (
+	radioStationSize = 100
+	radioPageSize    = 25
+	// radioLastfmFindLimit is how many last.fm recommendations that aren't stored yet are searched for on tidal
+	radioLastfmFindLimit = 5
+	// radioArtistSeedTracks is how many of an artist's top tracks the recommendations of an artist radio are for
+	radioArtistSeedTracks = 3
+	radioArtistPenalty    = 0.5
+
+	radioLastfmWeight    = 1.0
+	radioMixWeight       = 0.8
+	radioTopTracksWeight = 0.5
+
+	// radioStationTTL is how long a built station is kept, so that its pages come from the same list
+	radioStationTTL = 30 * time.Minute
+)
+
+type radioStationPage struct {
+	Items             []types.TidalSong `json:"items"`
+	MaybeHasMorePages bool              `json:"maybeHasMorePages"`
+}
+
+// radioStationKey identifies a station. It includes the region of the user since what's available differs between
+// countries, so a station is built again once the user has changed their region.
+type radioStationKey struct {
+	kind   string
+	seedId int64
+	userId uuid.UUID
+	region tidal.Region
+}
+
+type cachedRadioStation struct {
+	tracks    []types.TidalSong
+	expiresAt time.Time
+}
+
+// radioStations keeps the stations that have been built for a while, building one takes several calls to
+// tidal and its tracks can change between builds
+type radioStations struct {
+	mu       sync.Mutex
+	stations map[radioStationKey]*cachedRadioStation
+}
+
+func newRadioStations() *radioStations {
+	return &radioStations{
+		stations: map[radioStationKey]*cachedRadioStation{},
+	}
+}
+
+func (rs *radioStations) get(key radioStationKey) ([]types.TidalSong, bool) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	station, found := rs.stations[key]
+	if !found || time.Now().After(station.expiresAt) {
+		return nil, false
+	}
+
+	return station.tracks, true
+}
+
+func (rs *radioStations) put(key radioStationKey, tracks []types.TidalSong) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	now := time.Now()
+	for k, station := range rs.stations {
+		if now.After(station.expiresAt) {
+			delete(rs.stations, k)
+		}
+	}
+
+	rs.stations[key] = &cachedRadioStation{
+		tracks:    tracks,
+		expiresAt: now.Add(radioStationTTL),
+	}
+}
+
+type radioCandidate struct {
+	track types.TidalSong
+	score float64
+}
+
+// radioStation collects the tracks of a station from its sources. A track that more than one source picked
+// scores higher.
+type radioStation struct {
+	app    *application
+	region tidal.Region
+
+	candidates []*radioCandidate
+	// byId and byName find the candidate of a track, by name so that the same song on a different release
+	// isn't picked twice
+	byId        map[int]*radioCandidate
+	byName      map[string]*radioCandidate
+	excluded    map[int64]struct{}
+	lastfmFinds int
+	// err is the first error of a source, sources that fail are left out of the station
+	err error
+}
+
+func (app *application) newRadioStation(region tidal.Region) *radioStation {
+	return &radioStation{
+		app:      app,
+		region:   region,
+		byId:     map[int]*radioCandidate{},
+		byName:   map[string]*radioCandidate{},
+		excluded: map[int64]struct{}{},
+	}
+}
+
+func radioTrackName(track types.TidalSong) string {
+	return getTrackPrimaryArtistName(track) + "\x00" + strings.ToLower(strings.TrimSpace(track.Title))
+}
+
+// exclude keeps the track and other releases of it out of the station
+func (s *radioStation) exclude(track types.TidalSong) {
+	s.excluded[int64(track.ID)] = struct{}{}
+	s.byName[radioTrackName(track)] = nil
+}
+
+func (s *radioStation) add(track types.TidalSong, score float64) {
+	if _, excluded := s.excluded[int64(track.ID)]; excluded {
+		return
+	}
+
+	candidate, found := s.byId[track.ID]
+	if !found {
+		candidate, found = s.byName[radioTrackName(track)]
+		if found && candidate == nil {
+			return
+		}
+	}
+
+	if !found {
+		candidate = &radioCandidate{track: track}
+		s.candidates = append(s.candidates, candidate)
+		s.byName[radioTrackName(track)] = candidate
+	}
+
+	s.byId[track.ID] = candidate
+	candidate.score += score
+}
+
+// addRanked adds tracks that are in order of relevance, scoring the first ones the highest
+func (s *radioStation) addRanked(tracks []types.TidalSong, weight float64) {
+	for i, track := range tracks {
+		s.add(track, weight*(1-float64(i)/float64(len(tracks))))
+	}
+}
+
+func (s *radioStation) fail(source string, err error) {
+	s.app.logger.Warn("couldn't get tracks for radio station",
+		"error", err.Error(),
+		"source", source)
+
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// addLastfmRecommendations adds the tracks that last.fm finds similar to the seed, fetching the similar tracks
+// if they haven't been yet
+func (s *radioStation) addLastfmRecommendations(ctx context.Context, seed types.TidalSong) {
+	recommendations, err := s.app.db.GetLastfmRecommendationsForTidalTrack(int64(seed.ID))
+	if err != nil {
+		s.fail("lastfm", err)
+		return
+	}
+
+	if len(recommendations) == 0 && s.app.recs != nil {
+		err = s.app.recs.SyncIfMissing(ctx, int64(seed.ID))
+		if err != nil {
+			s.fail("lastfm", err)
+			return
+		}
+
+		recommendations, err = s.app.db.GetLastfmRecommendationsForTidalTrack(int64(seed.ID))
+		if err != nil {
+			s.fail("lastfm", err)
+			return
+		}
+	}
+
+	sort.SliceStable(recommendations, func(i int, j int) bool {
+		return recommendations[i].Match > recommendations[j].Match
+	})
+
+	for _, recommendation := range recommendations {
+		if recommendation.LastfmTrack.ArtistName == "" || recommendation.LastfmTrack.Title == "" {
+			continue
+		}
+
+		track, err := s.app.db.GetTidalTrackByArtistAndTitle(recommendation.LastfmTrack.ArtistName, recommendation.LastfmTrack.Title)
+		if err != nil {
+			if !errors.Is(err, database.ErrRecordNotFound) {
+				s.fail("lastfm", err)
+				return
+			}
+
+			// the tracks that aren't stored yet are searched for, which takes a call to tidal each
+			if s.lastfmFinds >= radioLastfmFindLimit {
+				continue
+			}
+			s.lastfmFinds++
+
+			track, err = s.app.findTidalTrackForRecommendation(
+				recommendation.LastfmTrack.ArtistName,
+				recommendation.LastfmTrack.Title,
+				s.excluded,
+				s.region,
+			)
+			if err != nil || track == nil {
+				continue
+			}
+		}
+
+		s.add(*track, radioLastfmWeight*recommendation.Match)
+	}
+}
+
+// addTrackRadio adds the tracks of tidal's radio mix of the track
+func (s *radioStation) addTrackRadio(trackId int) {
+	tracks, err := s.app.tidal.GetTrackRadio(int64(trackId), s.region)
+	if err != nil {
+		s.fail("tidal radio", err)
+		return
+	}
+
+	s.addRanked(tracks, radioMixWeight)
+}
+
+func (s *radioStation) addArtistTopTracks(artistId int) {
+	result, err := s.app.tidal.GetArtistTopTracks(int64(artistId), 0, s.region)
+	if err != nil {
+		s.fail("artist top tracks", err)
+		return
+	}
+
+	s.addRanked(result.Items, radioTopTracksWeight)
+}
+
+// tracks returns the best scoring tracks after the ones that the station starts with, spread out so that an
+// artist's tracks are played apart from each other. The same artist is only played twice in a row when only
+// their tracks are left.
+func (s *radioStation) tracks(start []types.TidalSong, limit int) []types.TidalSong {
+	remaining := make([]*radioCandidate, len(s.candidates))
+	copy(remaining, s.candidates)
+
+	sort.SliceStable(remaining, func(i int, j int) bool {
+		return remaining[i].score > remaining[j].score
+	})
+
+	tracks := []types.TidalSong{}
+	artistCounts := map[string]int{}
+	previousArtist := ""
+
+	for _, track := range start {
+		previousArtist = getTrackPrimaryArtistName(track)
+		artistCounts[previousArtist]++
+		tracks = append(tracks, track)
+	}
+
+	for len(tracks) < limit && len(remaining) > 0 {
+		best := -1
+		bestScore := 0.0
+		bestRepeats := true
+
+		for i, candidate := range remaining {
+			artist := getTrackPrimaryArtistName(candidate.track)
+			repeats := artist != "" && artist == previousArtist
+			score := candidate.score / (1 + radioArtistPenalty*float64(artistCounts[artist]))
+
+			if best == -1 || (bestRepeats && !repeats) || (repeats == bestRepeats && score > bestScore) {
+				best = i
+				bestScore = score
+				bestRepeats = repeats
+			}
+		}
+
+		picked := remaining[best]
+		remaining = append(remaining[:best], remaining[best+1:]...)
+
+		artist := getTrackPrimaryArtistName(picked.track)
+		artistCounts[artist]++
+		previousArtist = artist
+
+		tracks = append(tracks, picked.track)
+	}
+
+	return tracks
+}
+
+func (app *application) writeRadioStationPage(w http.ResponseWriter, r *http.Request, tracks []types.TidalSong, page int) {
+	start := min(page*radioPageSize, len(tracks))
+	end := min(start+radioPageSize, len(tracks))
+
+	err := app.writeJSON(w, http.StatusOK, radioStationPage{
+		Items:             tracks[start:end],
+		MaybeHasMorePages: end < len(tracks),
+	}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// trackRadioHandler returns a page of the station of the track, which starts with the track itself. The tracks
+// are stored so that they can be added to a session as they are. The station is built on the first request
+// and its later pages are served from it.
+func (app *application) trackRadioHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	page, err := app.readIntQueryOrZero(r, "page")
+	if err != nil || page < 0 {
+		app.badRequestResponse(w, r, errors.New("invalid page parameter"))
+		return
+	}
+
+	region := app.requestRegion(r)
+
+	key := radioStationKey{kind: "track", seedId: id, userId: *userId, region: region}
+	if tracks, found := app.radio.get(key); found {
+		app.writeRadioStationPage(w, r, tracks, page)
+		return
+	}
+
+	seed, err := app.db.GetTidalTrack(id)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if err != nil {
+		seed, err = app.tidal.GetSong(id, region)
+		if err != nil {
+			app.tidalErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	station := app.newRadioStation(region)
+	station.exclude(*seed)
+	station.addLastfmRecommendations(r.Context(), *seed)
+	station.addTrackRadio(seed.ID)
+	if len(seed.Artists) > 0 {
+		station.addArtistTopTracks(seed.Artists[0].ID)
+	}
+
+	if len(station.candidates) == 0 && station.err != nil {
+		app.tidalErrorResponse(w, r, station.err)
+		return
+	}
+
+	tracks := station.tracks([]types.TidalSong{*seed}, radioStationSize)
+	app.radio.put(key, tracks)
+
+	app.writeRadioStationPage(w, r, tracks, page)
+}
+
+// artistRadioHandler returns a page of the station of the artist, which is built from the artist's top tracks
+// and the tracks similar to them. Like track stations, it's built once and paged from then on.
+func (app *application) artistRadioHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	page, err := app.readIntQueryOrZero(r, "page")
+	if err != nil || page < 0 {
+		app.badRequestResponse(w, r, errors.New("invalid page parameter"))
+		return
+	}
+
+	region := app.requestRegion(r)
+
+	key := radioStationKey{kind: "artist", seedId: id, userId: *userId, region: region}
+	if tracks, found := app.radio.get(key); found {
+		app.writeRadioStationPage(w, r, tracks, page)
+		return
+	}
+
+	topTracks, err := app.tidal.GetArtistTopTracks(id, 0, region)
+	if err != nil {
+		app.tidalErrorResponse(w, r, err)
+		return
+	}
+
+	station := app.newRadioStation(region)
+	station.addRanked(topTracks.Items, radioTopTracksWeight)
+
+	for _, seed := range topTracks.Items[:min(radioArtistSeedTracks, len(topTracks.Items))] {
+		station.addLastfmRecommendations(r.Context(), seed)
+	}
+
+	if len(topTracks.Items) > 0 {
+		station.addTrackRadio(topTracks.Items[0].ID)
+	}
+
+	if len(station.candidates) == 0 && station.err != nil {
+		app.tidalErrorResponse(w, r, station.err)
+		return
+	}
+
+	tracks := station.tracks(nil, radioStationSize)
+	app.radio.put(key, tracks)
+
+	app.writeRadioStationPage(w, r, tracks, page)
+}
@@ -16,6 +16,7 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
 
 	router.HandlerFunc(http.MethodGet, "/v1/me", app.getCurrentUserHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/me", app.requireAuthenticatedUser(app.updateCurrentUserHandler))
 
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/users/logout", app.logOutUserHandler)
@@ -48,12 +49,29 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/artists/:id/appears-on", app.requireAuthenticatedUser(app.viewArtistAppearsOnHandler))
 
 	router.HandlerFunc(http.MethodGet, "/v1/albums/:id", app.requireAuthenticatedUser(app.viewAlbumHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/albums/:id/credits", app.requireAuthenticatedUser(app.getAlbumCreditsHandler))
 
 	router.HandlerFunc(http.MethodGet, "/v1/tracks/:id/stream", app.requireAuthenticatedUser(app.getSongStreamHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/tracks/:id/audio", app.requireAuthenticatedUser(app.getTrackAudioHandler))
+	router.HandlerFunc(http.MethodHead, "/v1/tracks/:id/audio", app.requireAuthenticatedUser(app.getTrackAudioHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/tracks/:id/streamurl", app.requireAuthenticatedUser(app.getSongStreamUrlHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/tracks/:id/playlists", app.requireAuthenticatedUser(app.getTrackPlaylistsHandler))
-
-	router.HandlerFunc(http.MethodGet, "/v1/streams/:id/segments/:segment", app.requireAuthenticatedUser(app.serveHLSHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/tracks/:id/waveform", app.requireAuthenticatedUser(app.getTrackWaveformHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/tracks/:id/lyrics", app.requireAuthenticatedUser(app.getTrackLyricsHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/tracks/:id/lyrics", app.requireAuthenticatedUser(app.uploadTrackLyricsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/tracks/:id/lyrics", app.requireAuthenticatedUser(app.deleteTrackLyricsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/tracks/:id/credits", app.requireAuthenticatedUser(app.getTrackCreditsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/contributors/:id", app.requireAuthenticatedUser(app.viewContributorHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/stream-profiles", app.requireAuthenticatedUser(app.listStreamProfilesHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/streams", app.requireAuthenticatedUser(app.listStreamsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/streams/:id/segments/:segment", app.requireStreamAccess(app.serveHLSHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/streams/:id/playlist.m3u8", app.requireStreamAccess(app.servePlaylistHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/streams/:id/master.m3u8", app.requireStreamAccess(app.serveMasterPlaylistHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/streams/:id/variants/:variant/:file", app.requireStreamAccess(app.serveVariantHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/streams/:id/status", app.requireAuthenticatedUser(app.getStreamStatusHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/streams/:id/seek", app.requireAuthenticatedUser(app.seekHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/streams/:id/end", app.requireAuthenticatedUser(app.endStreamHandler))
 
@@ -66,6 +84,12 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodDelete, "/v1/playlists/:id/tracks/:trackId", app.requireAuthenticatedUser(app.removeTrackFromPlaylistHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/playlists/:id/tracks/:trackId", app.requireAuthenticatedUser(app.isTrackInPlaylistHandler))
 
+	router.HandlerFunc(http.MethodGet, "/v1/tidal-playlists/:uuid", app.requireAuthenticatedUser(app.viewTidalPlaylistHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/tidal-playlists/:uuid/import", app.requireAuthenticatedUser(app.importTidalPlaylistHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/radio/track/:id", app.requireAuthenticatedUser(app.trackRadioHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/radio/artist/:id", app.requireAuthenticatedUser(app.artistRadioHandler))
+
 	router.HandlerFunc(http.MethodPost, "/v1/favorites/artists", app.requireAuthenticatedUser(app.toggleFavoriteArtistHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/favorites/artists", app.requireAuthenticatedUser(app.getFavoriteArtistsHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/favorites/artists/:id", app.requireAuthenticatedUser(app.isFavoriteArtistHandler))
@@ -76,6 +100,13 @@ func (app *application) routes() http.Handler {
 	router.HandlerFunc(http.MethodGet, "/v1/favorites/tracks", app.requireAuthenticatedUser(app.getFavoriteTracksHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/favorites/tracks/:id", app.requireAuthenticatedUser(app.isFavoriteTrackHandler))
 
+	router.HandlerFunc(http.MethodGet, "/v1/admin/transcode-cache", app.requireAdminUser(app.getTranscodeCacheStatsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/transcoders", app.requireAdminUser(app.getTranscoderPoolHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/tidal", app.requireAdminUser(app.getTidalStatusHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/tidal/refresh", app.requireAdminUser(app.refreshTidalTokensHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/tidal/login", app.requireAdminUser(app.startTidalLoginHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/tidal/login", app.requireAdminUser(app.getTidalLoginHandler))
+
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
 
 	return app.enableCORS(app.rateLimit(app.authenticate(app.parseSession(router))))
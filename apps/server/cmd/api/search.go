@@ -12,9 +12,9 @@ func (app *application) searchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := app.tidal.Search(query)
+	result, err := app.tidal.Search(query, app.requestRegion(r))
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.tidalErrorResponse(w, r, err)
 		return
 	}
 
@@ -51,10 +51,24 @@ func (app *application) serve() error {
 			app.sessions.Stop()
 		}
 
+		app.stopAudioTranscodes()
+
 		if app.tidal != nil {
 			app.tidal.Stop()
 		}
 
+		if app.cache != nil {
+			app.cache.Stop()
+		}
+
+		if app.loudness != nil {
+			app.loudness.Stop()
+		}
+
+		if app.waveforms != nil {
+			app.waveforms.Stop()
+		}
+
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
@@ -10,6 +10,7 @@ import (
 
 	"github.com/altierawr/oto/internal/data"
 	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/tidal"
 	"github.com/altierawr/oto/internal/types"
 	"github.com/hbollon/go-edlib"
 )
@@ -129,9 +130,9 @@ func (app *application) setSessionTracksHandler(w http.ResponseWriter, r *http.R
 		if err != nil {
 			app.logger.Info("tidal track not found in db; fetching from tidal",
 				"id", id)
-			track, err = app.tidal.GetSong(id)
+			track, err = app.tidal.GetSong(id, app.requestRegion(r))
 			if err != nil {
-				app.serverErrorResponse(w, r, err)
+				app.tidalErrorResponse(w, r, err)
 				return
 			}
 		}
@@ -151,6 +152,9 @@ func (app *application) setSessionTracksHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	// the prefetched stream may no longer be the next track in the queue
+	app.invalidatePrefetch(*sessionId)
+
 	err = app.writeJSON(w, http.StatusOK, nil, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -202,9 +206,9 @@ func (app *application) addSessionTrackHandler(w http.ResponseWriter, r *http.Re
 	if err != nil {
 		app.logger.Info("tidal track not found in db; fetching from tidal",
 			"id", input.TrackId)
-		track, err = app.tidal.GetSong(*input.TrackId)
+		track, err = app.tidal.GetSong(*input.TrackId, app.requestRegion(r))
 		if err != nil {
-			app.serverErrorResponse(w, r, err)
+			app.tidalErrorResponse(w, r, err)
 			return
 		}
 	}
@@ -226,6 +230,9 @@ func (app *application) addSessionTrackHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// the prefetched stream may no longer be the next track in the queue
+	app.invalidatePrefetch(*sessionId)
+
 	err = app.writeJSON(w, http.StatusOK, nil, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -267,6 +274,9 @@ func (app *application) removeSessionTrackHandler(w http.ResponseWriter, r *http
 		return
 	}
 
+	// the prefetched stream may no longer be the next track in the queue
+	app.invalidatePrefetch(*sessionId)
+
 	err = app.writeJSON(w, http.StatusOK, nil, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -313,6 +323,8 @@ func (app *application) getSessionAutoplayTrackHandler(w http.ResponseWriter, r
 		sessionTrackIds[int64(sessionTrack.ID)] = struct{}{}
 	}
 
+	region := app.userRegion(*userId)
+
 	recommendationScores := map[int]*autoplayRecommendationScore{}
 	missingRecommendationTracks := []data.SessionTrack{}
 	albumOccurrences := make(map[int]int, len(session.Tracks))
@@ -345,7 +357,7 @@ func (app *application) getSessionAutoplayTrackHandler(w http.ResponseWriter, r
 		)
 	}
 
-	bestResult, err := app.getBestAutoplayTrack(recommendationScores, sessionTrackIds, session.Tracks)
+	bestResult, err := app.getBestAutoplayTrack(recommendationScores, sessionTrackIds, session.Tracks, region)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -387,7 +399,7 @@ func (app *application) getSessionAutoplayTrackHandler(w http.ResponseWriter, r
 				albumOccurrences[track.Album.ID],
 			)
 
-			bestResult, err = app.getBestAutoplayTrack(recommendationScores, sessionTrackIds, session.Tracks)
+			bestResult, err = app.getBestAutoplayTrack(recommendationScores, sessionTrackIds, session.Tracks, region)
 			if err != nil {
 				app.serverErrorResponse(w, r, err)
 				return
@@ -418,7 +430,7 @@ func (app *application) getSessionAutoplayTrackHandler(w http.ResponseWriter, r
 		}
 
 		sessionTrackIds[int64(bestResult.ID)] = struct{}{}
-		bestResult, err = app.getBestAutoplayTrack(recommendationScores, sessionTrackIds, session.Tracks)
+		bestResult, err = app.getBestAutoplayTrack(recommendationScores, sessionTrackIds, session.Tracks, region)
 		if err != nil {
 			app.serverErrorResponse(w, r, err)
 			return
@@ -552,6 +564,7 @@ func (app *application) getBestAutoplayTrack(
 	recommendationScores map[int]*autoplayRecommendationScore,
 	sessionTrackIDs map[int64]struct{},
 	sessionTracks []data.SessionTrack,
+	region tidal.Region,
 ) (*types.TidalSong, error) {
 	if len(recommendationScores) == 0 {
 		return nil, nil
@@ -598,7 +611,7 @@ func (app *application) getBestAutoplayTrack(
 			}
 
 			findAttempts++
-			track, err := app.findTidalTrackForRecommendation(score.ArtistName, score.Title, sessionTrackIDs)
+			track, err := app.findTidalTrackForRecommendation(score.ArtistName, score.Title, sessionTrackIDs, region)
 			if err != nil {
 				return nil, err
 			}
@@ -628,8 +641,9 @@ func (app *application) findTidalTrackForRecommendation(
 	artistName string,
 	title string,
 	sessionTrackIDs map[int64]struct{},
+	region tidal.Region,
 ) (*types.TidalSong, error) {
-	results, err := app.tidal.Search(fmt.Sprintf("%s - %s", artistName, title))
+	results, err := app.tidal.Search(fmt.Sprintf("%s - %s", artistName, title), region)
 	if err != nil {
 		app.logger.Error("error searching tidal for lastfm hit",
 			"error", err.Error(),
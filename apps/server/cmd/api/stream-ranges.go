@@ -0,0 +1,582 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/altierawr/oto/internal/ffmpeg"
+	"github.com/altierawr/oto/internal/profiles"
+	"github.com/altierawr/oto/internal/sessions"
+	"github.com/altierawr/oto/internal/transcoder"
+	"github.com/google/uuid"
+)
+
+// seekAheadSegments is how far ahead of a running transcode a seek can land before a separate transcode is
+// started for it. Transcodes are much faster than realtime, so they catch up with a short distance quickly.
+const seekAheadSegments = 5
+
+// seekWithinStream resolves a seek to a segment of the stream without ending it. Segments that have been
+// written are returned right away. Seeking ahead of the transcode starts a new range of the stream at the
+// segment while the earlier transcode keeps running, until it reaches the new range.
+func (app *application) seekWithinStream(
+	userId uuid.UUID,
+	sessionId *uuid.UUID,
+	streamId string,
+	position float64,
+) (int, error) {
+	stream, err := app.sessions.Streams().Get(userId, *sessionId, streamId)
+	if err != nil {
+		return 0, err
+	}
+
+	segment, startTime := streamSegmentAt(sessions.GetStreamPath(sessionId, streamId), &stream, position)
+
+	var reachable bool
+	err = app.sessions.Streams().Update(*sessionId, streamId, func(stream *sessions.Stream) {
+		reachable = isSegmentReachable(stream, segment)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if reachable {
+		return segment, nil
+	}
+
+	ready, err := app.startStreamRange(userId, sessionId, streamId, segment, startTime)
+	if err != nil {
+		return 0, err
+	}
+
+	err = <-ready
+	if err != nil {
+		return 0, err
+	}
+
+	return segment, nil
+}
+
+// segmentTime is the time a segment starts at, in seconds from the seek offset of the stream
+type segmentTime struct {
+	nr    int
+	start float64
+}
+
+// streamSegmentAt returns the segment of the stream that the position in the track is in and the time it
+// starts at, in seconds from the seek offset of the stream. The segments that have been listed in the
+// playlists of the ranges are timed by their durations, later ones are assumed to have the target duration.
+func streamSegmentAt(streamPath string, stream *sessions.Stream, position float64) (int, float64) {
+	target := max(position-stream.SeekOffset, 0)
+	times := streamSegmentTimes(streamPath, stream)
+
+	// the known start time closest before the target, and the first segment known to start after it
+	anchor := segmentTime{}
+	next := -1
+	for _, t := range times {
+		if t.start > target {
+			next = t.nr
+			break
+		}
+
+		anchor = t
+	}
+
+	segment := anchor.nr + int(math.Floor((target-anchor.start)/transcoder.SegmentDuration))
+	if next != -1 && segment >= next {
+		segment = next - 1
+	}
+	if stream.NrSegments >= 0 && segment >= stream.NrSegments {
+		segment = stream.NrSegments - 1
+	}
+	segment = max(segment, 0)
+
+	for _, t := range times {
+		if t.nr == segment {
+			return segment, t.start
+		}
+	}
+
+	return segment, anchor.start + float64((segment-anchor.nr)*transcoder.SegmentDuration)
+}
+
+// streamSegmentTimes returns the start times of the segments of the stream that are known, ordered by segment.
+// Every range starts at a known time, and each segment it has listed in its playlist ends where the next one
+// starts.
+func streamSegmentTimes(streamPath string, stream *sessions.Stream) []segmentTime {
+	profile := stream.Profile
+	rendition := ""
+	if len(stream.Variants) > 0 {
+		profile = stream.Variants[0]
+		rendition = profile.Name
+	}
+
+	times := []segmentTime{}
+	for _, streamRange := range stream.Ranges {
+		times = append(times, segmentTime{nr: streamRange.Start, start: streamRange.StartTime})
+
+		playlist, err := readRangePlaylist(filepath.Join(streamPath, streamRange.Dir, rendition, "index.m3u8"), profile)
+		if err != nil {
+			continue
+		}
+
+		start := streamRange.StartTime
+		nr := streamRange.Start
+		for _, segment := range playlist.segments {
+			if segment.nr != nr || (streamRange.End != -1 && segment.nr >= streamRange.End) {
+				break
+			}
+
+			duration, ok := segment.duration()
+			if !ok {
+				break
+			}
+
+			start += duration
+			nr++
+
+			// the next range knows when its first segment starts
+			if streamRange.End == -1 || nr < streamRange.End {
+				times = append(times, segmentTime{nr: nr, start: start})
+			}
+		}
+	}
+
+	return times
+}
+
+// isSegmentReachable reports whether the segment has been written or will be shortly by the transcode of its
+// range. It has to be called from StreamManager.Update.
+func isSegmentReachable(stream *sessions.Stream, segment int) bool {
+	streamRange := stream.Range(segment)
+	if streamRange == nil {
+		return false
+	}
+
+	written := streamRange.Start + streamRange.SegmentsWritten
+	if segment < written {
+		return true
+	}
+
+	return streamRange.IsLoading && !streamRange.Stopped && segment < written+seekAheadSegments
+}
+
+// startStreamRange starts transcoding the stream from the segment, which starts at startTime, into a new range.
+// It fails with the error of the pool if no transcoder is free, the ranges that are running aren't stopped for
+// it. The returned channel works like the one of createStream.
+func (app *application) startStreamRange(
+	userId uuid.UUID,
+	sessionId *uuid.UUID,
+	streamId string,
+	segment int,
+	startTime float64,
+) (<-chan error, error) {
+	state, err := app.sessions.Streams().Get(userId, *sessionId, streamId)
+	if err != nil {
+		return nil, err
+	}
+
+	// the running transcode keeps playing when no transcoder is free, the client can seek again later
+	slot, err := app.transcoders.TryAcquire(userId.String())
+	if err != nil {
+		return nil, err
+	}
+
+	started := false
+	defer func() {
+		if !started {
+			slot.Release()
+		}
+	}()
+
+	source, err := app.tidal.Client().GetSongSource(state.TrackId, state.Profile.SourceQuality, app.userRegion(userId))
+	if err != nil {
+		return nil, err
+	}
+
+	streamPath := sessions.GetStreamPath(sessionId, streamId)
+	rangeDir, err := os.MkdirTemp(streamPath, fmt.Sprintf("range-%d-*", segment))
+	if err != nil {
+		return nil, err
+	}
+
+	process, err := app.transcoder.Start(transcoder.Job{
+		Output:      transcoder.OutputHLS,
+		Input:       source.Input(),
+		OutputDir:   rangeDir,
+		SeekOffset:  state.SeekOffset + startTime,
+		Profile:     state.Profile,
+		Variants:    state.Variants,
+		Gain:        state.Gain,
+		StartNumber: segment,
+		StartTime:   startTime,
+	})
+	if err != nil {
+		os.RemoveAll(rangeDir)
+		return nil, err
+	}
+	started = true
+
+	newRange := &sessions.StreamRange{
+		Start:     segment,
+		StartTime: startTime,
+		Dir:       filepath.Base(rangeDir),
+		IsLoading: true,
+		Transcode: process,
+		Done:      make(chan struct{}),
+	}
+
+	// another seek might have covered the segment while the transcode was starting
+	covered := false
+	var replaced *sessions.StreamRange
+	err = app.sessions.Streams().Update(*sessionId, streamId, func(stream *sessions.Stream) {
+		if isSegmentReachable(stream, segment) {
+			covered = true
+			return
+		}
+
+		replaced = stream.SplitRange(newRange)
+	})
+	if err != nil || covered {
+		newRange.Stopped = true
+		process.Cancel()
+		for range process.Progress() {
+		}
+		process.Wait()
+		slot.Release()
+		os.RemoveAll(rangeDir)
+
+		ready := make(chan error, 1)
+		ready <- err
+
+		return ready, nil
+	}
+
+	if replaced != nil && replaced.Dir != "" {
+		os.RemoveAll(filepath.Join(streamPath, replaced.Dir))
+	}
+
+	app.logger.Info("transcode of stream range started",
+		"sessionId", sessionId,
+		"streamId", streamId,
+		"start", segment,
+		"startTime", startTime,
+		"end", newRange.End)
+
+	return app.watchStreamRange(sessionId, streamId, newRange, slot), nil
+}
+
+// watchStreamRange follows the transcode of the range until it exits and releases the slot afterwards. The
+// transcode is stopped once it reaches the next range. The returned channel receives nil once the first
+// segment of the range is ready, or an error if the transcode didn't produce any segments.
+func (app *application) watchStreamRange(
+	sessionId *uuid.UUID,
+	streamId string,
+	streamRange *sessions.StreamRange,
+	slot *ffmpeg.Slot,
+) <-chan error {
+	streams := app.sessions.Streams()
+	process := streamRange.Transcode
+
+	responseChan := make(chan error, 1)
+	var responseOnce sync.Once
+
+	go func() {
+		defer close(streamRange.Done)
+
+		segmentCount := 0
+		for progress := range process.Progress() {
+			segmentCount = progress.SegmentsWritten
+
+			streams.Update(*sessionId, streamId, func(s *sessions.Stream) {
+				streamRange.SegmentsWritten = progress.SegmentsWritten
+				s.EncodedTime = progress.OutTime
+				s.Speed = progress.Speed
+				s.Refresh()
+
+				// the rest of the range is transcoded by the next one already
+				if streamRange.ReachedEnd() && !streamRange.Stopped {
+					streamRange.Stopped = true
+					process.Cancel()
+				}
+			})
+
+			if segmentCount > 0 {
+				responseOnce.Do(func() {
+					app.logger.Info("first segment is ready, notifying client",
+						"sessionId", sessionId,
+						"streamId", streamId,
+						"start", streamRange.Start)
+					responseChan <- nil
+				})
+			}
+		}
+
+		waitErr := process.Wait()
+		slot.Release()
+
+		var stream sessions.Stream
+		stopped := false
+		err := streams.Update(*sessionId, streamId, func(s *sessions.Stream) {
+			stopped = streamRange.Stopped
+			streamRange.IsLoading = false
+			if waitErr != nil && !stopped {
+				s.Error = waitErr.Error()
+			}
+			if waitErr == nil && streamRange.End == -1 && segmentCount > 0 {
+				s.NrSegments = streamRange.Start + segmentCount
+			}
+			s.Refresh()
+			stream = s.Snapshot()
+		})
+		exists := err == nil
+
+		switch {
+		case stopped:
+			app.logger.Info("stopped transcode of stream range",
+				"sessionId", sessionId,
+				"streamId", streamId,
+				"start", streamRange.Start,
+				"segments", segmentCount)
+		case waitErr != nil:
+			output := ""
+			var transcodeErr *transcoder.Error
+			if errors.As(waitErr, &transcodeErr) {
+				output = transcodeErr.Output
+			}
+
+			app.logger.Error("transcode failed",
+				"sessionId", sessionId,
+				"streamId", streamId,
+				"trackId", stream.TrackId,
+				"start", streamRange.Start,
+				"error", waitErr.Error(),
+				"output", output)
+		default:
+			app.logger.Info("finished downloading track",
+				"sessionId", sessionId,
+				"streamId", streamId,
+				"trackId", fmt.Sprintf("%d", stream.TrackId),
+				"start", streamRange.Start,
+				"segments", segmentCount,
+			)
+		}
+
+		// only a stream that was transcoded in one go from the start is complete in the stream directory
+		isWholeTrack := exists && streamRange.Dir == "" && streamRange.End == -1 && stream.SeekOffset == 0
+		if waitErr == nil && isWholeTrack && segmentCount > 0 {
+			cacheProfile := transcodeCacheProfile(stream.Profile, stream.Variants, stream.Gain)
+			err := app.cache.Store(stream.TrackId, cacheProfile, sessions.GetStreamPath(sessionId, streamId), segmentCount)
+			if err != nil {
+				app.logger.Error("couldn't store transcode in cache",
+					"sessionId", sessionId,
+					"streamId", streamId,
+					"trackId", stream.TrackId,
+					"error", err.Error())
+			}
+		}
+
+		responseOnce.Do(func() {
+			if segmentCount > 0 {
+				responseChan <- nil
+				return
+			}
+
+			app.logger.Info("transcode finished without any segments, sending error",
+				"sessionId", sessionId,
+				"streamId", streamId,
+			)
+
+			if waitErr != nil {
+				responseChan <- fmt.Errorf("transcode didn't create any segments: %s", waitErr.Error())
+			} else {
+				responseChan <- errors.New("transcode didn't create any segments")
+			}
+		})
+	}()
+
+	return responseChan
+}
+
+// streamFilePath returns the path of a segment or init segment in the directory of the range that wrote it,
+// and whether it has been completely written. rendition is the rendition directory of adaptive streams.
+func streamFilePath(streamPath string, stream *sessions.Stream, rendition string, profile *profiles.Profile, name string) (string, bool) {
+	if segmentNr, isSegment := profile.ParseSegmentName(name); isSegment {
+		streamRange := stream.Range(segmentNr)
+		if streamRange == nil {
+			return "", false
+		}
+
+		return filepath.Join(streamPath, streamRange.Dir, rendition, name), stream.HasSegment(segmentNr)
+	}
+
+	// init segments are named after the range they belong to, and are written before its first segment
+	for _, streamRange := range stream.Ranges {
+		if streamRange.SegmentsWritten == 0 {
+			continue
+		}
+
+		path := filepath.Join(streamPath, streamRange.Dir, rendition, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+type playlistSegment struct {
+	nr    int
+	lines []string
+}
+
+// duration returns the duration of the segment from its EXTINF tag
+func (s playlistSegment) duration() (float64, bool) {
+	for _, line := range s.lines {
+		value, found := strings.CutPrefix(line, "#EXTINF:")
+		if !found {
+			continue
+		}
+
+		value, _, _ = strings.Cut(value, ",")
+		duration, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, false
+		}
+
+		return duration, true
+	}
+
+	return 0, false
+}
+
+type rangePlaylist struct {
+	header   []string
+	mapLine  string
+	segments []playlistSegment
+}
+
+// joinStreamPlaylist joins the media playlists of the ranges of the stream into one. It lists the segments from
+// the start of the stream up to the first one that hasn't been written, so the playlist only grows while the
+// gaps left by seeks are filled. The end tag is left to the caller.
+func joinStreamPlaylist(streamPath string, stream *sessions.Stream, rendition string, profile *profiles.Profile) ([]string, error) {
+	lines := []string{}
+	next := 0
+
+	for i, streamRange := range stream.Ranges {
+		if streamRange.Start != next {
+			break
+		}
+
+		playlist, err := readRangePlaylist(filepath.Join(streamPath, streamRange.Dir, rendition, "index.m3u8"), profile)
+		if err != nil {
+			// the first range provides the header, later ones might not have started writing yet
+			if i == 0 || !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+
+			break
+		}
+
+		if i == 0 {
+			lines = append(lines, playlist.header...)
+		}
+
+		mapWritten := false
+		for _, segment := range playlist.segments {
+			if segment.nr < next {
+				continue
+			}
+
+			// segments are only listed once they can be served
+			written := segment.nr < streamRange.Start+streamRange.SegmentsWritten
+			if segment.nr > next || !written || (streamRange.End != -1 && segment.nr >= streamRange.End) {
+				break
+			}
+
+			// every range is a separate transcode with its own timestamps and init segment
+			if !mapWritten {
+				if i > 0 {
+					lines = append(lines, "#EXT-X-DISCONTINUITY")
+				}
+				if playlist.mapLine != "" {
+					lines = append(lines, playlist.mapLine)
+				}
+				mapWritten = true
+			}
+
+			lines = append(lines, segment.lines...)
+			next++
+		}
+
+		if streamRange.End == -1 || next < streamRange.End {
+			break
+		}
+	}
+
+	return lines, nil
+}
+
+func readRangePlaylist(path string, profile *profiles.Profile) (*rangePlaylist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	playlist := &rangePlaylist{}
+	segmentLines := []string{}
+	inHeader := true
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "" || line == "#EXT-X-ENDLIST":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			playlist.mapLine = line
+		case strings.HasPrefix(line, "#EXTINF:"):
+			inHeader = false
+			segmentLines = append(segmentLines, line)
+		case strings.HasPrefix(line, "#"):
+			if inHeader {
+				playlist.header = append(playlist.header, line)
+			} else {
+				segmentLines = append(segmentLines, line)
+			}
+		default:
+			nr, isSegment := profile.ParseSegmentName(line)
+			if isSegment {
+				playlist.segments = append(playlist.segments, playlistSegment{
+					nr:    nr,
+					lines: append(segmentLines, line),
+				})
+			}
+
+			segmentLines = []string{}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return playlist, nil
+}
+
+// seekResponse sends the segment a seek resolved to within the stream
+func (app *application) seekResponse(w http.ResponseWriter, r *http.Request, streamId string, segment int) {
+	err := app.writeJSON(w, http.StatusOK, envelope{"segment": segment, "streamId": streamId}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
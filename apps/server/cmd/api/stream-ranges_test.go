@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/altierawr/oto/internal/ffmpeg"
+	"github.com/altierawr/oto/internal/profiles"
+	"github.com/altierawr/oto/internal/sessions"
+)
+
+func TestSeekWithinStream(t *testing.T) {
+	t.Run("written segment", func(t *testing.T) {
+		app := newTestApplication(t, 10, time.Millisecond, 1)
+
+		userId, sessionId, streamId := startTestStream(t, app)
+		waitForStream(t, app, userId, sessionId, streamId)
+
+		segment, err := app.seekWithinStream(userId, &sessionId, streamId, 3.5)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if segment != 3 {
+			t.Errorf("got segment %d, want 3", segment)
+		}
+
+		stream, _ := app.sessions.Streams().Get(userId, sessionId, streamId)
+		if len(stream.Ranges) != 1 {
+			t.Errorf("got %d ranges, want the seek to be served by the first one", len(stream.Ranges))
+		}
+	})
+
+	t.Run("ahead of the transcode", func(t *testing.T) {
+		app := newTestApplication(t, 30, 20*time.Millisecond, 2)
+
+		userId, sessionId, streamId := startTestStream(t, app)
+
+		segment, err := app.seekWithinStream(userId, &sessionId, streamId, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if segment != 20 {
+			t.Errorf("got segment %d, want 20", segment)
+		}
+
+		stream := waitForStream(t, app, userId, sessionId, streamId)
+		if len(stream.Ranges) != 2 {
+			t.Fatalf("got %d ranges, want 2", len(stream.Ranges))
+		}
+
+		first, second := stream.Ranges[0], stream.Ranges[1]
+		if first.End != 20 || !first.Stopped {
+			t.Errorf("got first range ending at %d, stopped %t, want it stopped at the new range", first.End, first.Stopped)
+		}
+		if second.Start != 20 || second.StartTime != 20 || second.End != -1 {
+			t.Errorf("got second range from %d at %gs to %d, want it to start at 20 and run to the end",
+				second.Start, second.StartTime, second.End)
+		}
+
+		if stream.NrSegments != 30 || stream.SegmentsWritten != 30 {
+			t.Errorf("got %d of %d segments written, want all 30", stream.SegmentsWritten, stream.NrSegments)
+		}
+	})
+
+	t.Run("no free transcoder", func(t *testing.T) {
+		app := newTestApplication(t, 30, 20*time.Millisecond, 1)
+
+		userId, sessionId, streamId := startTestStream(t, app)
+
+		_, err := app.seekWithinStream(userId, &sessionId, streamId, 20)
+		if !errors.Is(err, ffmpeg.ErrPoolFull) {
+			t.Fatalf("got error %v, want %v", err, ffmpeg.ErrPoolFull)
+		}
+
+		// the running transcode keeps going so that playback isn't interrupted
+		stream, _ := app.sessions.Streams().Get(userId, sessionId, streamId)
+		if len(stream.Ranges) != 1 || stream.Ranges[0].Stopped || !stream.Ranges[0].IsLoading {
+			t.Error("got the running range stopped by the failed seek")
+		}
+
+		stream = waitForStream(t, app, userId, sessionId, streamId)
+		if stream.NrSegments != 30 {
+			t.Errorf("got %d segments, want 30", stream.NrSegments)
+		}
+	})
+}
+
+func TestJoinStreamPlaylist(t *testing.T) {
+	app := newTestApplication(t, 30, 20*time.Millisecond, 2)
+
+	userId, sessionId, streamId := startTestStream(t, app)
+
+	_, err := app.seekWithinStream(userId, &sessionId, streamId, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the gap before the new range isn't listed until the first range has filled it
+	stream, _ := app.sessions.Streams().Get(userId, sessionId, streamId)
+	lines, err := joinStreamPlaylist(sessions.GetStreamPath(&sessionId, streamId), &stream, "", stream.Profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if slices.Contains(lines, stream.Profile.SegmentName(20)) {
+		t.Error("got the new range listed before the segments before it were written")
+	}
+
+	stream = waitForStream(t, app, userId, sessionId, streamId)
+	lines, err = joinStreamPlaylist(sessions.GetStreamPath(&sessionId, streamId), &stream, "", stream.Profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segments := []string{}
+	discontinuities := []int{}
+	for _, line := range lines {
+		switch {
+		case line == "#EXT-X-DISCONTINUITY":
+			discontinuities = append(discontinuities, len(segments))
+		case line != "" && !strings.HasPrefix(line, "#"):
+			segments = append(segments, line)
+		}
+	}
+
+	if len(segments) != 30 {
+		t.Fatalf("got %d segments, want 30", len(segments))
+	}
+
+	for i, segment := range segments {
+		if segment != stream.Profile.SegmentName(i) {
+			t.Fatalf("got segment %q at %d, want %q", segment, i, stream.Profile.SegmentName(i))
+		}
+	}
+
+	// the second range is a separate transcode, so players have to reset their decoder at its first segment
+	if !slices.Equal(discontinuities, []int{20}) {
+		t.Errorf("got discontinuities before segments %v, want one before segment 20", discontinuities)
+	}
+}
+
+func TestStreamSegmentAt(t *testing.T) {
+	profile := profiles.Default()
+	streamPath := t.TempDir()
+
+	// the first range has listed segments of uneven lengths, the second one hasn't written its playlist yet
+	playlist := "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:0\n" +
+		"#EXTINF:1.5,\n" + profile.SegmentName(0) + "\n" +
+		"#EXTINF:1.5,\n" + profile.SegmentName(1) + "\n" +
+		"#EXTINF:1.0,\n" + profile.SegmentName(2) + "\n"
+
+	err := os.WriteFile(filepath.Join(streamPath, "index.m3u8"), []byte(playlist), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := &sessions.Stream{
+		NrSegments: -1,
+		SeekOffset: 10,
+		Profile:    profile,
+		Ranges: []*sessions.StreamRange{
+			{Start: 0, End: 40, SegmentsWritten: 3},
+			{Start: 40, StartTime: 60, End: -1, Dir: "range-40"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		position  float64
+		segment   int
+		startTime float64
+	}{
+		{"before the seek offset", 5, 0, 0},
+		{"first segment", 11, 0, 0},
+		{"listed segment", 12.5, 1, 1.5},
+		{"end of listed segment", 13.9, 2, 3},
+		{"after the listed segments", 16.2, 5, 6},
+		{"start of the next range", 70, 40, 60},
+		{"in the next range", 72.5, 42, 62},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segment, startTime := streamSegmentAt(streamPath, stream, tt.position)
+			if segment != tt.segment || startTime != tt.startTime {
+				t.Errorf("got segment %d at %gs, want segment %d at %gs", segment, startTime, tt.segment, tt.startTime)
+			}
+		})
+	}
+}
@@ -2,25 +2,31 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
-	"os/exec"
-	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
 
+	"github.com/altierawr/oto/internal/auth"
 	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/ffmpeg"
+	"github.com/altierawr/oto/internal/profiles"
 	"github.com/altierawr/oto/internal/sessions"
 	"github.com/altierawr/oto/internal/tidal"
-	"github.com/fsnotify/fsnotify"
+	"github.com/altierawr/oto/internal/transcoder"
+	"github.com/google/uuid"
 	"github.com/julienschmidt/httprouter"
 )
 
+var mapURIPattern = regexp.MustCompile(`URI="([^"]*)"`)
+
 func (app *application) seekHandler(w http.ResponseWriter, r *http.Request) {
 	params := httprouter.ParamsFromContext(r.Context())
 
@@ -30,18 +36,15 @@ func (app *application) seekHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
 	streamId := params.ByName("id")
-	sessionKey := sessionId.String()
-	sessions.SessionStreamsMu.RLock()
-	stream, exists := sessions.SessionStreams[sessionKey][streamId]
-	var trackId int64
-	var seekOffset float64
-	if exists {
-		trackId = stream.TrackId
-		seekOffset = stream.SeekOffset
-	}
-	sessions.SessionStreamsMu.RUnlock()
-	if streamId == "" || !exists {
+	stream, err := app.sessions.Streams().Touch(*userId, *sessionId, streamId)
+	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
@@ -53,149 +56,319 @@ func (app *application) seekHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	position, err := strconv.ParseFloat(positionStr, 64)
+	if err != nil || position < 0 {
+		app.badRequestResponse(w, r, errors.New("position must be a non-negative number"))
+		return
+	}
+
+	// segments only exist from the seek offset of the stream on, so seeking before it needs a new stream
+	if position < stream.SeekOffset {
+		err = app.sessions.Streams().End(*sessionId, streamId)
+		if err != nil {
+			app.logger.Error(err.Error(),
+				"sessionId", sessionId,
+				"streamId", streamId)
+		}
+
+		app.startStream(w, r, stream.TrackId, stream.QueueIndex, strconv.FormatFloat(position, 'f', -1, 64), stream.Profile, stream.Variants)
+		return
+	}
+
+	segment, err := app.seekWithinStream(*userId, sessionId, streamId, position)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		switch {
+		case errors.Is(err, sessions.ErrStreamNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, ffmpeg.ErrUserLimit):
+			app.transcodeLimitExceededResponse(w, r)
+		case errors.Is(err, ffmpeg.ErrPoolFull):
+			app.transcodersBusyResponse(w, r)
+		case errors.Is(err, tidal.ErrUnavailable):
+			app.tidalUnavailableResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
 		return
 	}
 
-	streamPath := sessions.GetStreamPath(sessionId, streamId)
-	playlistPath := filepath.Join(streamPath, "index.m3u8")
+	app.seekResponse(w, r, streamId, segment)
+}
 
-	playlistFile, err := os.Open(playlistPath)
+func (app *application) endStreamHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	streamId := params.ByName("id")
+
+	sessionId := app.contextGetSessionId(r)
+	if sessionId == nil {
+		app.invalidSessionResponse(w, r)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	streams := app.sessions.Streams()
+
+	_, err := streams.Get(*userId, *sessionId, streamId)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = streams.End(*sessionId, streamId)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, nil, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) serveHLSHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	streamId := params.ByName("id")
+	if streamId == "" {
+		app.notFoundResponse(w, r)
 		return
 	}
-	defer playlistFile.Close()
 
-	segment := 0
-	duration := 0.0
-
-	offsetPosition := position - seekOffset
-
-	if offsetPosition >= 0 {
-		scanner := bufio.NewScanner(playlistFile)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-
-			parts, found := strings.CutPrefix(line, "#EXTINF:")
-			if found {
-				durationStr := strings.Split(parts, ",")[0]
-
-				segmentDuration, err := strconv.ParseFloat(durationStr, 64)
-				if err != nil {
-					app.logger.Error(err.Error(),
-						"sessionId", sessionId,
-						"streamId", streamId,
-						"durationStr", durationStr)
-					continue
-				}
-
-				duration += segmentDuration
-				segment++
-
-				if duration > offsetPosition {
-					segment--
-					break
-				}
-
-				if duration == offsetPosition {
-					break
-				}
-			}
-		}
+	segment := params.ByName("segment")
+	if segment == "" {
+		app.notFoundResponse(w, r)
+		return
+	}
 
+	sessionId := app.contextGetSessionId(r)
+	if sessionId == nil {
+		app.invalidSessionResponse(w, r)
+		return
 	}
 
-	// We should have the segment
-	if duration > 0 && duration >= offsetPosition {
-		segmentPath := filepath.Join(streamPath, fmt.Sprintf("segment-%d.mp4", segment))
-		_, err = os.Stat(segmentPath)
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
 
-		if err != nil {
-			err = app.writeJSON(w, http.StatusOK, envelope{"segment": segment}, nil)
+	state, err := app.sessions.Streams().Touch(*userId, *sessionId, streamId)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
 
-			if err != nil {
-				app.serverErrorResponse(w, r, err)
-			}
+	isInit := strings.HasPrefix(segment, "init") && state.Profile.HasInitSegment()
+	segmentNr, isSegment := state.Profile.ParseSegmentName(segment)
+	if !isInit && !isSegment {
+		app.notFoundResponse(w, r)
+		return
+	}
 
+	filePath, isWritten := streamFilePath(sessions.GetStreamPath(sessionId, streamId), &state, "", state.Profile, segment)
+	if !isWritten {
+		if state.IsLoading {
+			app.acceptedResponse(w, r)
 			return
 		}
+
+		app.notFoundResponse(w, r)
+		return
 	}
 
-	err = app.endStream(r, streamId)
-	if err != nil {
-		app.logger.Error(err.Error(),
-			"sessionId", sessionId,
-			"streamId", streamId)
+	w.Header().Set("Content-Type", state.Profile.ContentType)
+
+	if isSegment {
+		if !state.IsLoading && segmentNr == state.NrSegments-1 {
+			w.Header().Set("Access-Control-Expose-Headers", "X-Last-Segment")
+			w.Header().Set("X-Last-Segment", "true")
+		}
+
+		app.maybePrefetchNextTrack(*userId, *sessionId, streamId, segmentNr)
 	}
 
-	app.startStream(w, r, trackId, strconv.FormatFloat(position, 'f', -1, 64))
+	http.ServeFile(w, r, filePath)
 }
 
-func (app *application) endStream(r *http.Request, streamId string) error {
+func (app *application) servePlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	streamId := params.ByName("id")
+	if streamId == "" {
+		app.notFoundResponse(w, r)
+		return
+	}
+
 	sessionId := app.contextGetSessionId(r)
 	if sessionId == nil {
-		return errors.New("session not found")
+		app.invalidSessionResponse(w, r)
+		return
 	}
 
-	sessionKey := sessionId.String()
-	sessions.SessionStreamsMu.RLock()
-	stream, exists := sessions.SessionStreams[sessionKey][streamId]
-	sessions.SessionStreamsMu.RUnlock()
-	if streamId == "" || !exists {
-		return errors.New("not found")
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
 	}
 
-	err := stream.Ffmpeg.Process.Kill()
-	if err != nil && !errors.Is(err, os.ErrProcessDone) {
-		app.logger.Error(err.Error(),
-			"sessionId", sessionId,
-			"streamId", streamId)
-	}
+	state, err := app.sessions.Streams().Touch(*userId, *sessionId, streamId)
 
-	streamPath := sessions.GetStreamPath(sessionId, streamId)
+	// adaptive streams are played through master.m3u8
+	if err != nil || len(state.Variants) > 0 {
+		app.notFoundResponse(w, r)
+		return
+	}
 
-	err = os.RemoveAll(streamPath)
+	playlistLines, err := joinStreamPlaylist(sessions.GetStreamPath(sessionId, streamId), &state, "", state.Profile)
 	if err != nil {
-		app.logger.Error(err.Error(),
-			"sessionId", sessionId,
-			"streamId", streamId)
+		if errors.Is(err, os.ErrNotExist) && state.IsLoading {
+			app.acceptedResponse(w, r)
+			return
+		}
+
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
-	sessions.SessionStreamsMu.Lock()
-	if sessionStreams, found := sessions.SessionStreams[sessionKey]; found {
-		delete(sessionStreams, streamId)
-		if len(sessionStreams) == 0 {
-			delete(sessions.SessionStreams, sessionKey)
-		}
+	signedQuery := signedStreamQuery(*userId, *sessionId, streamId)
+	playlistLines = signMediaPlaylist(playlistLines, func(name string) string {
+		return fmt.Sprintf("%s/v1/streams/%s/segments/%s?%s", app.requestBaseURL(r), streamId, url.PathEscape(name), signedQuery)
+	})
+
+	var playlist strings.Builder
+	for _, line := range playlistLines {
+		playlist.WriteString(line)
+		playlist.WriteString("\n")
 	}
-	sessions.SessionStreamsMu.Unlock()
 
-	app.logger.Info("ended stream",
-		"sessionId", sessionId,
-		"streamId", streamId)
+	// players need the end tag to know that the stream is complete
+	if !state.IsLoading {
+		playlist.WriteString("#EXT-X-ENDLIST\n")
+	}
 
-	return nil
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(playlist.String()))
 }
 
-func (app *application) endStreamHandler(w http.ResponseWriter, r *http.Request) {
+// signedStreamQuery returns the query that authorizes requests for the files of the stream. HLS players fetch
+// playlists and segments without cookies, so every URL handed to them carries its own authorization.
+func signedStreamQuery(userId uuid.UUID, sessionId uuid.UUID, streamId string) string {
+	return auth.SignStreamQuery(auth.StreamAccess{
+		UserId:    userId,
+		SessionId: sessionId,
+		StreamId:  streamId,
+	}).Encode()
+}
+
+// signMediaPlaylist replaces the segment and init segment URIs of the media playlist with the URLs that
+// fileURL returns for their names
+func signMediaPlaylist(lines []string, fileURL func(name string) string) []string {
+	signed := make([]string, 0, len(lines))
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			line = mapURIPattern.ReplaceAllStringFunc(line, func(match string) string {
+				name := mapURIPattern.FindStringSubmatch(match)[1]
+				return fmt.Sprintf("URI=%q", fileURL(name))
+			})
+		case line != "" && !strings.HasPrefix(line, "#"):
+			line = fileURL(line)
+		}
+
+		signed = append(signed, line)
+	}
+
+	return signed
+}
+
+func (app *application) getStreamStatusHandler(w http.ResponseWriter, r *http.Request) {
 	params := httprouter.ParamsFromContext(r.Context())
-	streamId := params.ByName("id")
 
-	err := app.endStream(r, streamId)
+	sessionId := app.contextGetSessionId(r)
+	if sessionId == nil {
+		app.invalidSessionResponse(w, r)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	streamId := params.ByName("id")
+	stream, err := app.sessions.Streams().Get(*userId, *sessionId, streamId)
 	if err != nil {
 		app.notFoundResponse(w, r)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, nil, nil)
+	err = app.writeJSON(w, http.StatusOK, envelope{"stream": streamStatus(&stream)}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
 }
 
-func (app *application) serveHLSHandler(w http.ResponseWriter, r *http.Request) {
+func (app *application) listStreamsHandler(w http.ResponseWriter, r *http.Request) {
+	sessionId := app.contextGetSessionId(r)
+	if sessionId == nil {
+		app.invalidSessionResponse(w, r)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	statuses := []envelope{}
+	for _, stream := range app.sessions.Streams().List(*userId, *sessionId) {
+		statuses = append(statuses, streamStatus(&stream))
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"streams": statuses}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func streamStatus(stream *sessions.Stream) envelope {
+	ranges := []envelope{}
+	for _, streamRange := range stream.Ranges {
+		ranges = append(ranges, envelope{
+			"start":           streamRange.Start,
+			"end":             streamRange.End,
+			"segmentsWritten": streamRange.SegmentsWritten,
+			"isLoading":       streamRange.IsLoading,
+		})
+	}
+
+	return envelope{
+		"id":              stream.Id,
+		"trackId":         stream.TrackId,
+		"profile":         stream.Profile.Name,
+		"isLoading":       stream.IsLoading,
+		"segmentsWritten": stream.SegmentsWritten,
+		"nrSegments":      stream.NrSegments,
+		"seekOffset":      stream.SeekOffset,
+		"gain":            stream.Gain,
+		"sourceQuality":   stream.SourceQuality,
+		"encodedTime":     stream.EncodedTime.Seconds(),
+		"speed":           stream.Speed,
+		"error":           stream.Error,
+		"ranges":          ranges,
+	}
+}
+
+func (app *application) serveMasterPlaylistHandler(w http.ResponseWriter, r *http.Request) {
 	params := httprouter.ParamsFromContext(r.Context())
 
 	streamId := params.ByName("id")
@@ -204,8 +377,67 @@ func (app *application) serveHLSHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	segment := params.ByName("segment")
-	if segment == "" {
+	sessionId := app.contextGetSessionId(r)
+	if sessionId == nil {
+		app.invalidSessionResponse(w, r)
+		return
+	}
+
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	stream, err := app.sessions.Streams().Touch(*userId, *sessionId, streamId)
+	if err != nil || len(stream.Variants) == 0 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	playlistFile, err := os.Open(filepath.Join(sessions.GetStreamPath(sessionId, streamId), "master.m3u8"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && stream.IsLoading {
+			app.acceptedResponse(w, r)
+			return
+		}
+
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	defer playlistFile.Close()
+
+	// ffmpeg references the renditions relative to the stream directory, but they're served under variants/
+	signedQuery := signedStreamQuery(*userId, *sessionId, streamId)
+	var playlist strings.Builder
+	scanner := bufio.NewScanner(playlistFile)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			line = fmt.Sprintf("%s/v1/streams/%s/variants/%s?%s", app.requestBaseURL(r), streamId, line, signedQuery)
+		}
+
+		playlist.WriteString(line)
+		playlist.WriteString("\n")
+	}
+
+	if err := scanner.Err(); err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(playlist.String()))
+}
+
+func (app *application) serveVariantHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	streamId := params.ByName("id")
+	variantName := params.ByName("variant")
+	file := params.ByName("file")
+	if streamId == "" || variantName == "" || file == "" || filepath.Base(file) != file {
 		app.notFoundResponse(w, r)
 		return
 	}
@@ -216,21 +448,63 @@ func (app *application) serveHLSHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	segmentPath := filepath.Join(sessions.GetStreamPath(sessionId, streamId), segment)
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	state, err := app.sessions.Streams().Touch(*userId, *sessionId, streamId)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var variant *profiles.Profile
+	for _, v := range state.Variants {
+		if v.Name == variantName {
+			variant = v
+			break
+		}
+	}
+	if variant == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	streamPath := sessions.GetStreamPath(sessionId, streamId)
 
-	info, err := os.Stat(segmentPath)
-	sessionKey := sessionId.String()
-	sessions.SessionStreamsMu.RLock()
-	stream, streamExists := sessions.SessionStreams[sessionKey][streamId]
-	streamIsLoading := false
-	streamNrSegments := -1
-	if streamExists {
-		streamIsLoading = stream.IsLoading
-		streamNrSegments = stream.NrSegments
+	if file == "index.m3u8" {
+		playlistLines, err := joinStreamPlaylist(streamPath, &state, variant.Name, variant)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && state.IsLoading {
+				app.acceptedResponse(w, r)
+				return
+			}
+
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		signedQuery := signedStreamQuery(*userId, *sessionId, streamId)
+		playlistLines = signMediaPlaylist(playlistLines, func(name string) string {
+			return fmt.Sprintf("%s/v1/streams/%s/variants/%s/%s?%s",
+				app.requestBaseURL(r), streamId, url.PathEscape(variant.Name), url.PathEscape(name), signedQuery)
+		})
+
+		if !state.IsLoading {
+			playlistLines = append(playlistLines, "#EXT-X-ENDLIST")
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte(strings.Join(playlistLines, "\n") + "\n"))
+		return
 	}
-	sessions.SessionStreamsMu.RUnlock()
-	if err != nil || info.IsDir() {
-		if streamExists && streamIsLoading {
+
+	filePath, isWritten := streamFilePath(streamPath, &state, variant.Name, variant, file)
+	if !isWritten {
+		if state.IsLoading {
 			app.acceptedResponse(w, r)
 			return
 		}
@@ -239,35 +513,70 @@ func (app *application) serveHLSHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	w.Header().Set("Content-Type", "audio/mp4")
+	w.Header().Set("Content-Type", variant.ContentType)
 
-	if segment != "init.mp4" && streamExists && streamNrSegments != -1 {
-		re := regexp.MustCompile(`\d+`)
-		matches := re.FindString(segment)
+	segmentNr, isSegment := variant.ParseSegmentName(file)
+	if isSegment {
+		app.maybePrefetchNextTrack(*userId, *sessionId, streamId, segmentNr)
+	}
 
-		if matches != "" {
-			segmentNr, err := strconv.Atoi(matches)
-			if err != nil {
-				app.logger.Error(err.Error(),
-					"sessionId", sessionId,
-					"streamId", streamId,
-					"segment", segment)
-			} else if streamNrSegments == segmentNr+1 {
-				w.Header().Set("Access-Control-Expose-Headers", "X-Last-Segment")
-				w.Header().Set("X-Last-Segment", "true")
-			}
-		} else {
-			app.logger.Error("error parsing number from segment",
-				"sessionId", sessionId,
-				"streamId", streamId,
-				"segment", segment)
+	http.ServeFile(w, r, filePath)
+}
+
+func (app *application) listStreamProfilesHandler(w http.ResponseWriter, r *http.Request) {
+	data := envelope{
+		"profiles":           profiles.All(),
+		"default":            profiles.DefaultName,
+		"adaptiveRenditions": profiles.AdaptiveRenditions,
+	}
+
+	err := app.writeJSON(w, http.StatusOK, data, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// resolveStreamProfile picks the profile from the profile query parameter, then the user's default and
+// finally the server default
+func (app *application) resolveStreamProfile(r *http.Request) (*profiles.Profile, error) {
+	name := r.URL.Query().Get("profile")
+	if name != "" {
+		return profiles.Get(name)
+	}
+
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		return profiles.Default(), nil
+	}
+
+	user, err := app.db.GetUserById(*userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.StreamProfile != nil {
+		profile, err := profiles.Get(*user.StreamProfile)
+		if err == nil {
+			return profile, nil
 		}
+
+		app.logger.Warn("user has an unknown stream profile, using default",
+			"userId", userId,
+			"profile", *user.StreamProfile)
 	}
 
-	http.ServeFile(w, r, segmentPath)
+	return profiles.Default(), nil
 }
 
-func (app *application) startStream(w http.ResponseWriter, r *http.Request, trackId int64, ss string) {
+func (app *application) startStream(
+	w http.ResponseWriter,
+	r *http.Request,
+	trackId int64,
+	queueIndex int,
+	ss string,
+	profile *profiles.Profile,
+	variants []*profiles.Profile,
+) {
 	sessionId := app.contextGetSessionId(r)
 	if sessionId == nil {
 		app.invalidSessionResponse(w, r)
@@ -280,6 +589,16 @@ func (app *application) startStream(w http.ResponseWriter, r *http.Request, trac
 		return
 	}
 
+	seekOffset := 0.0
+	if ss != "" {
+		var err error
+		seekOffset, err = strconv.ParseFloat(ss, 64)
+		if err != nil || seekOffset < 0 {
+			app.badRequestResponse(w, r, errors.New("ss must be a non-negative number"))
+			return
+		}
+	}
+
 	// check that the session is valid
 	_, err := app.db.GetSession(*userId, *sessionId)
 	if err != nil {
@@ -293,194 +612,341 @@ func (app *application) startStream(w http.ResponseWriter, r *http.Request, trac
 		return
 	}
 
-	stream, err := tidal.GetSongStreamUrl(trackId)
+	if seekOffset == 0 {
+		profileKey := transcodeCacheProfile(profile, variants, app.normalizationGain(*userId, trackId))
+		streamId, found := app.claimPrefetchedStream(sessionId, trackId, queueIndex, profileKey)
+		if found {
+			app.streamCreatedResponse(w, r, *userId, *sessionId, streamId, seekOffset)
+			return
+		}
+	}
+
+	streamId, ready, err := app.createStream(r.Context(), *userId, sessionId, trackId, queueIndex, seekOffset, profile, variants, false)
 	if err != nil {
-		if errors.Is(err, tidal.ErrInvalidTidalResponseType) {
-			app.logger.Error("tidal returned data in an invalid format from stream endpoint",
-				"trackId", trackId)
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, ffmpeg.ErrUserLimit):
+			app.transcodeLimitExceededResponse(w, r)
+		case errors.Is(err, ffmpeg.ErrPoolFull):
+			app.transcodersBusyResponse(w, r)
+		case errors.Is(err, tidal.ErrUnavailable):
+			app.tidalUnavailableResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
 		}
 
-		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	sessionDir := sessions.GetSessionPath(sessionId)
-	err = os.MkdirAll(sessionDir, os.ModePerm)
+	err = <-ready
 	if err != nil {
+		// the stream can't be played, so it's ended instead of being left in the session
+		endErr := app.sessions.Streams().End(*sessionId, streamId)
+		if endErr != nil && !errors.Is(endErr, sessions.ErrStreamNotFound) {
+			app.logger.Error(endErr.Error(),
+				"sessionId", sessionId,
+				"streamId", streamId)
+		}
+
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	tempDir, err := os.MkdirTemp(sessionDir, "stream-*")
+	app.streamCreatedResponse(w, r, *userId, *sessionId, streamId, seekOffset)
+}
+
+// streamCreatedResponse sends the id of a new stream along with a signed playlist URL that standard HLS
+// players can load directly. Adaptive streams are played through their master playlist. The media timeline
+// of a seeked stream starts at its seek offset, and segment is the one that the requested position is in.
+// It's only past the first one when the seek was served from a cached full transcode.
+func (app *application) streamCreatedResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	userId uuid.UUID,
+	sessionId uuid.UUID,
+	streamId string,
+	position float64,
+) {
+	stream, err := app.sessions.Streams().Get(userId, sessionId, streamId)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.notFoundResponse(w, r)
 		return
 	}
 
-	parts := strings.Split(tempDir, "-")
-	streamId := parts[len(parts)-1]
+	segment := 0
+	if position > stream.SeekOffset {
+		segment, _ = streamSegmentAt(sessions.GetStreamPath(&sessionId, streamId), &stream, position)
+	}
 
-	args := []string{
-		"-i", *stream,
-		"-c:a", "flac",
-		"-f", "hls",
-		"-hls_time", "1",
-		"-hls_playlist_type", "event",
-		"-hls_segment_type", "fmp4",
-		"-hls_segment_filename", path.Join(tempDir, "segment%d.mp4"),
+	playlistName := "playlist.m3u8"
+	if len(stream.Variants) > 0 {
+		playlistName = "master.m3u8"
 	}
 
-	if ss != "" {
-		args = append([]string{"-ss", fmt.Sprintf("%ss", ss)}, args...)
+	data := envelope{
+		"streamId":   streamId,
+		"seekOffset": stream.SeekOffset,
+		"segment":    segment,
+		"playlistUrl": fmt.Sprintf("%s/v1/streams/%s/%s?%s",
+			app.requestBaseURL(r), streamId, playlistName, signedStreamQuery(userId, sessionId, streamId)),
 	}
 
-	args = append(args, filepath.Join(tempDir, "index.m3u8"))
+	err = app.writeJSON(w, http.StatusCreated, data, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
 
-	app.logger.Info("ffmpeg process started",
+// createStream starts transcoding the track into a new stream of the session and returns its id. The
+// returned channel receives nil once the first segments are ready to be served, or an error if the transcode
+// didn't produce any segments. Background streams don't wait in the transcoder queue and fail right
+// away if no transcoder is free.
+func (app *application) createStream(
+	ctx context.Context,
+	userId uuid.UUID,
+	sessionId *uuid.UUID,
+	trackId int64,
+	queueIndex int,
+	seekOffset float64,
+	profile *profiles.Profile,
+	variants []*profiles.Profile,
+	background bool,
+) (string, <-chan error, error) {
+	gain := app.normalizationGain(userId, trackId)
+	cacheProfile := transcodeCacheProfile(profile, variants, gain)
+
+	// only full transcodes are cached, seeks are served from them by starting at the segment of the seek
+	// position, which the stream created response points to
+	if entry, found := app.cache.Lookup(trackId, cacheProfile); found {
+		return app.createCachedStream(userId, sessionId, trackId, queueIndex, entry, profile, variants, gain)
+	}
+
+	var slot *ffmpeg.Slot
+	var err error
+	if background {
+		slot, err = app.transcoders.TryAcquire(userId.String())
+	} else {
+		slot, err = app.transcoders.Acquire(ctx, userId.String())
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	// the slot is handed over to the goroutine waiting for the transcode once it has started
+	started := false
+	defer func() {
+		if !started {
+			slot.Release()
+		}
+	}()
+
+	source, err := app.tidal.Client().GetSongSource(trackId, profile.SourceQuality, app.userRegion(userId))
+	if err != nil {
+		if errors.Is(err, tidal.ErrInvalidTidalResponseType) {
+			app.logger.Error("tidal returned data in an invalid format from stream endpoint",
+				"trackId", trackId)
+		}
+
+		return "", nil, err
+	}
+
+	duration := app.trackDuration(userId, trackId)
+
+	sessionDir, err := sessions.CreateSessionDir(sessionId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tempDir, err := os.MkdirTemp(sessionDir, "stream-*")
+	if err != nil {
+		return "", nil, err
+	}
+
+	parts := strings.Split(tempDir, "-")
+	streamId := parts[len(parts)-1]
+
+	process, err := app.transcoder.Start(transcoder.Job{
+		Output:     transcoder.OutputHLS,
+		Input:      source.Input(),
+		OutputDir:  tempDir,
+		SeekOffset: seekOffset,
+		Profile:    profile,
+		Variants:   variants,
+		Gain:       gain,
+	})
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", nil, err
+	}
+	started = true
+
+	app.logger.Info("transcode started",
 		"sessionId", sessionId,
 		"streamId", streamId,
-		"args", fmt.Sprintf("%v", args))
+		"profile", profile.Name,
+		"sourceQuality", source.Quality,
+		"seekOffset", seekOffset,
+		"gain", gain)
 
-	cmd := exec.Command("ffmpeg", args...)
+	baseRange := &sessions.StreamRange{
+		Start:     0,
+		End:       -1,
+		IsLoading: true,
+		Transcode: process,
+		Done:      make(chan struct{}),
+	}
 
 	s := sessions.Stream{
-		IsLoading:  true,
-		NrSegments: -1,
-		Ffmpeg:     cmd,
-		TrackId:    trackId,
-		SeekOffset: 0,
-	}
+		Id:            streamId,
+		UserId:        userId,
+		CreatedAt:     time.Now(),
+		IsLoading:     true,
+		NrSegments:    -1,
+		Ranges:        []*sessions.StreamRange{baseRange},
+		TrackId:       trackId,
+		QueueIndex:    queueIndex,
+		SeekOffset:    seekOffset,
+		Duration:      duration,
+		Profile:       profile,
+		Variants:      variants,
+		Gain:          gain,
+		SourceQuality: source.Quality,
+	}
+
+	app.sessions.Streams().Add(*sessionId, &s)
+
+	ready := app.watchStreamRange(sessionId, streamId, baseRange, slot)
+
+	return streamId, ready, nil
+}
 
-	if ss != "" {
-		ssFloat, err := strconv.ParseFloat(ss, 64)
+// trackDuration returns the length of the track in seconds, 0 if it can't be found out. The stored track is used
+// when there is one, so that starting a stream doesn't take another call to tidal.
+func (app *application) trackDuration(userId uuid.UUID, trackId int64) float64 {
+	track, err := app.db.GetTidalTrack(trackId)
+	if err != nil {
+		if !errors.Is(err, database.ErrRecordNotFound) {
+			app.logger.Error("couldn't get stored track for its duration",
+				"error", err.Error(),
+				"trackId", trackId)
+		}
+
+		track, err = app.tidal.GetSong(trackId, app.userRegion(userId))
 		if err != nil {
-			app.badRequestResponse(w, r, err)
+			app.logger.Error("couldn't get track for its duration",
+				"error", err.Error(),
+				"trackId", trackId)
+			return 0
 		}
+	}
+
+	return float64(track.Duration)
+}
 
-		s.SeekOffset = ssFloat
+// transcodeCacheProfile returns the name the transcode is stored under in the transcode cache. Normalized
+// transcodes are stored separately for every gain.
+func transcodeCacheProfile(profile *profiles.Profile, variants []*profiles.Profile, gain float64) string {
+	name := profile.Name
+	if len(variants) > 0 {
+		names := []string{}
+		for _, variant := range variants {
+			names = append(names, variant.Name)
+		}
+
+		name = "adaptive:" + strings.Join(names, ",")
 	}
 
-	sessionKey := sessionId.String()
-	sessions.SessionStreamsMu.Lock()
-	if _, exists := sessions.SessionStreams[sessionKey]; !exists {
-		sessions.SessionStreams[sessionKey] = make(map[string]*sessions.Stream)
+	if gain != 0 {
+		name += fmt.Sprintf(":gain=%.1f", gain)
 	}
-	sessions.SessionStreams[sessionKey][streamId] = &s
-	sessions.SessionStreamsMu.Unlock()
 
-	if err := cmd.Start(); err != nil {
-		http.Error(w, "Failed to start ffmpeg", http.StatusInternalServerError)
-		return
+	return name
+}
+
+// normalizationGain returns the gain in dB to apply to the track for the user's normalization setting, 0 if
+// normalization is off or the track's loudness isn't known yet
+func (app *application) normalizationGain(userId uuid.UUID, trackId int64) float64 {
+	user, err := app.db.GetUserById(userId)
+	if err != nil {
+		app.logger.Error("couldn't get user for normalization",
+			"error", err.Error(),
+			"userId", userId)
+		return 0
 	}
 
-	watcher, err := fsnotify.NewWatcher()
+	gain, err := app.loudness.Gain(trackId, user.Normalization)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
+		app.logger.Error("couldn't get normalization gain",
+			"error", err.Error(),
+			"userId", userId,
+			"trackId", trackId)
+		return 0
 	}
 
-	streamPath := sessions.GetStreamPath(sessionId, streamId)
-	err = watcher.Add(streamPath)
+	return gain
+}
+
+// createCachedStream creates a finished stream from a transcode cache entry without starting a transcode
+func (app *application) createCachedStream(
+	userId uuid.UUID,
+	sessionId *uuid.UUID,
+	trackId int64,
+	queueIndex int,
+	entry *database.TranscodeCacheEntry,
+	profile *profiles.Profile,
+	variants []*profiles.Profile,
+	gain float64,
+) (string, <-chan error, error) {
+	sessionDir, err := sessions.CreateSessionDir(sessionId)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
+		return "", nil, err
 	}
 
-	responseChan := make(chan error, 1)
-	var responseOnce sync.Once
-
-	go func() {
-		defer watcher.Close()
-
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-
-				if event.Op == fsnotify.Create && strings.HasSuffix(event.Name, "segment1.mp4") {
-					app.logger.Info("segment 1 was created, notifying client",
-						"sessionId", sessionId,
-						"streamId", streamId)
-					responseOnce.Do(func() {
-						responseChan <- nil
-					})
-					return
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				app.logger.Error(err.Error(),
-					"sessionId", sessionId,
-					"streamId", streamId)
-			}
-		}
-	}()
+	tempDir, err := os.MkdirTemp(sessionDir, "stream-*")
+	if err != nil {
+		return "", nil, err
+	}
 
-	// Handle ffmpeg completion in a separate goroutine
-	go func() {
-		cmd.Wait()
+	parts := strings.Split(tempDir, "-")
+	streamId := parts[len(parts)-1]
 
-		app.logger.Info("finished downloading track",
-			"sessionId", sessionId,
-			"streamId", streamId,
-			"trackId", fmt.Sprintf("%d", trackId),
-		)
+	err = app.cache.Link(entry, tempDir)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", nil, err
+	}
 
-		segmentFiles, err := os.ReadDir(streamPath)
-		segmentCount := -1
+	s := sessions.Stream{
+		Id:              streamId,
+		UserId:          userId,
+		CreatedAt:       time.Now(),
+		IsLoading:       false,
+		NrSegments:      entry.NrSegments,
+		SegmentsWritten: entry.NrSegments,
+		Ranges: []*sessions.StreamRange{{
+			Start:           0,
+			End:             -1,
+			SegmentsWritten: entry.NrSegments,
+		}},
+		TrackId:    trackId,
+		QueueIndex: queueIndex,
+		SeekOffset: 0,
+		Profile:    profile,
+		Variants:   variants,
+		Gain:       gain,
+	}
 
-		// For a playlist, there are the segment files + index.m3u8 + init.mp4
-		if err == nil && len(segmentFiles) > 2 {
-			segmentCount = len(segmentFiles) - 2
-		} else if err != nil {
-			app.logger.Error("error reading stream dir",
-				"sessionId", sessionId,
-				"streamId", streamId,
-				"error", err.Error(),
-			)
-		}
+	app.sessions.Streams().Add(*sessionId, &s)
 
-		sessions.SessionStreamsMu.Lock()
-		if sessionStreams, found := sessions.SessionStreams[sessionKey]; found {
-			if s, exists := sessionStreams[streamId]; exists {
-				s.IsLoading = false
-				if segmentCount > 0 {
-					s.NrSegments = segmentCount
-				}
-			}
-		}
-		sessions.SessionStreamsMu.Unlock()
+	app.logger.Info("serving stream from transcode cache",
+		"sessionId", sessionId,
+		"streamId", streamId,
+		"trackId", trackId,
+		"profile", profile.Name)
 
-		_, err = os.Stat(filepath.Join(streamPath, "segment0.mp4"))
-		if err == nil {
-			responseOnce.Do(func() {
-				app.logger.Info("ffmpeg finished, sending nil",
-					"sessionId", sessionId,
-					"streamId", streamId,
-				)
-				responseChan <- nil
-			})
-		} else {
-			responseOnce.Do(func() {
-				app.logger.Info("ffmpeg finished without any segments, sending error",
-					"sessionId", sessionId,
-					"streamId", streamId,
-				)
-				responseChan <- errors.New("ffmpeg didn't create any segments")
-			})
-		}
-	}()
+	ready := make(chan error, 1)
+	ready <- nil
 
-	err = <-responseChan
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-	} else {
-		err = app.writeJSON(w, http.StatusCreated, envelope{"streamId": streamId}, nil)
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-		}
-	}
+	return streamId, ready, nil
 }
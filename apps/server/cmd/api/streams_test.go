@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// getPlaylist requests the media playlist of the stream with its signed query, like an HLS player does
+func getPlaylist(t *testing.T, handler http.Handler, url string) (int, []string) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, url, nil))
+
+	body := strings.TrimSpace(rr.Body.String())
+
+	return rr.Code, strings.Split(body, "\n")
+}
+
+func TestServePlaylist(t *testing.T) {
+	app := newTestApplication(t, 5, time.Millisecond, 1)
+	handler := app.routes()
+
+	userId, sessionId, streamId := startTestStream(t, app)
+	stream := waitForStream(t, app, userId, sessionId, streamId)
+
+	playlistURL := fmt.Sprintf("http://oto.test/v1/streams/%s/playlist.m3u8?%s",
+		streamId, signedStreamQuery(userId, sessionId, streamId))
+
+	code, lines := getPlaylist(t, handler, playlistURL)
+	if code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", code, http.StatusOK)
+	}
+
+	segmentURLs := []string{}
+	for _, line := range lines {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			segmentURLs = append(segmentURLs, line)
+		}
+	}
+
+	if len(segmentURLs) != 5 {
+		t.Fatalf("got %d segments, want 5", len(segmentURLs))
+	}
+
+	if lines[len(lines)-1] != "#EXT-X-ENDLIST" {
+		t.Errorf("got last line %q, want the end tag", lines[len(lines)-1])
+	}
+
+	for i, segmentURL := range segmentURLs {
+		prefix := fmt.Sprintf("http://oto.test/v1/streams/%s/segments/%s?", streamId, stream.Profile.SegmentName(i))
+		if !strings.HasPrefix(segmentURL, prefix) || !strings.Contains(segmentURL, "sig=") {
+			t.Errorf("got segment url %q, want a signed url starting with %q", segmentURL, prefix)
+		}
+	}
+
+	// the segments are served with the signature in their url alone
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, segmentURLs[2], nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d for segment, want %d", rr.Code, http.StatusOK)
+	}
+
+	body, _ := io.ReadAll(rr.Body)
+	if string(body) != "fake segment 2\n" {
+		t.Errorf("got segment %q, want the third segment", body)
+	}
+}
+
+func TestServePlaylistWhileLoading(t *testing.T) {
+	app := newTestApplication(t, 20, 20*time.Millisecond, 1)
+	handler := app.routes()
+
+	userId, sessionId, streamId := startTestStream(t, app)
+
+	playlistURL := fmt.Sprintf("http://oto.test/v1/streams/%s/playlist.m3u8?%s",
+		streamId, signedStreamQuery(userId, sessionId, streamId))
+
+	code, lines := getPlaylist(t, handler, playlistURL)
+	if code != http.StatusOK {
+		t.Fatalf("got status %d, want %d", code, http.StatusOK)
+	}
+
+	// players keep reloading the playlist until the end tag shows up
+	if lines[len(lines)-1] == "#EXT-X-ENDLIST" {
+		t.Error("got the end tag while the stream is still loading")
+	}
+
+	waitForStream(t, app, userId, sessionId, streamId)
+
+	_, lines = getPlaylist(t, handler, playlistURL)
+	if lines[len(lines)-1] != "#EXT-X-ENDLIST" {
+		t.Errorf("got last line %q once the stream has loaded, want the end tag", lines[len(lines)-1])
+	}
+}
+
+func TestServePlaylistPublicURL(t *testing.T) {
+	app := newTestApplication(t, 2, time.Millisecond, 1)
+	app.config.publicURL = "https://music.example.com"
+	handler := app.routes()
+
+	userId, sessionId, streamId := startTestStream(t, app)
+	waitForStream(t, app, userId, sessionId, streamId)
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("http://oto.test/v1/streams/%s/playlist.m3u8?%s",
+		streamId, signedStreamQuery(userId, sessionId, streamId)), nil)
+	req.Header.Set("X-Forwarded-Host", "attacker.example.com")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	for line := range strings.SplitSeq(rr.Body.String(), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "https://music.example.com/") {
+			t.Errorf("got segment url %q, want one on the public url", line)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/altierawr/oto/internal/auth"
+	"github.com/altierawr/oto/internal/cache"
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/ffmpeg"
+	"github.com/altierawr/oto/internal/profiles"
+	"github.com/altierawr/oto/internal/sessions"
+	"github.com/altierawr/oto/internal/tidal"
+	"github.com/altierawr/oto/internal/transcoder"
+	"github.com/google/uuid"
+)
+
+// newTestApplication returns an application that streams with the fake transcoder, which writes the given number
+// of segments for every track, one every interval. Tidal is stood in for by a server that hands out playback info
+// for any track, and the database, the stream directories and the transcode cache are kept in temporary
+// directories.
+func newTestApplication(t *testing.T, segments int, interval time.Duration, maxWorkers int) *application {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_DATA_HOME", filepath.Join(dir, "data"))
+	t.Setenv("TMPDIR", filepath.Join(dir, "tmp"))
+
+	auth.SetTokenSecrets("access-secret", "refresh-secret")
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	db, err := database.New(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	err = db.MigrateUp()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tidalServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path == "/v1/oauth2/token" {
+			json.NewEncoder(w).Encode(map[string]any{
+				"access_token":  "access-token",
+				"refresh_token": "refresh-token",
+				"expires_in":    3600,
+				"token_type":    "Bearer",
+			})
+			return
+		}
+
+		if !strings.HasSuffix(r.URL.Path, "/playbackinfopostpaywall/v4") {
+			http.NotFound(w, r)
+			return
+		}
+
+		manifest, _ := json.Marshal(map[string]any{
+			"mimeType":       "audio/flac",
+			"codecs":         "flac",
+			"encryptionType": "NONE",
+			"urls":           []string{"http://" + r.Host + "/audio.flac"},
+		})
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"audioQuality":     r.URL.Query().Get("audioquality"),
+			"manifestMimeType": "application/vnd.tidal.bts",
+			"manifest":         base64.StdEncoding.EncodeToString(manifest),
+		})
+	}))
+	t.Cleanup(tidalServer.Close)
+
+	tidalClient, err := tidal.NewClient(tidal.Config{
+		APIBaseURL:   tidalServer.URL,
+		AuthBaseURL:  tidalServer.URL,
+		AccessToken:  "access-token",
+		RefreshToken: "refresh-token",
+		Logger:       logger,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transcodeCache, err := cache.New(db, logger, filepath.Join(dir, "transcodes"), 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		logger:      logger,
+		db:          db,
+		cache:       transcodeCache,
+		sessions:    sessions.New(db, logger, 0),
+		tidal:       tidal.New(db, logger, tidalClient),
+		transcoder:  transcoder.NewFake(segments, interval),
+		transcoders: ffmpeg.NewPool(maxWorkers, 0, time.Second),
+	}
+
+	// the transcodes that are still running write into the temporary directory until they're cancelled
+	t.Cleanup(func() { app.sessions.Streams().StopAll() })
+
+	return app
+}
+
+// startTestStream starts streaming a track into a new session and waits until its first segments are ready
+func startTestStream(t *testing.T, app *application) (uuid.UUID, uuid.UUID, string) {
+	t.Helper()
+
+	userId := uuid.New()
+	sessionId := uuid.New()
+
+	streamId, ready, err := app.createStream(context.Background(), userId, &sessionId, 1, -1, 0, profiles.Default(), nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = <-ready
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return userId, sessionId, streamId
+}
+
+// waitForStream waits until every range of the stream has finished transcoding and returns the stream
+func waitForStream(t *testing.T, app *application, userId uuid.UUID, sessionId uuid.UUID, streamId string) sessions.Stream {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		stream, err := app.sessions.Streams().Get(userId, sessionId, streamId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !stream.IsLoading {
+			return stream
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("stream is still loading")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+func (app *application) viewTidalPlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	playlistUUID, err := app.readPlaylistUUIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	page, err := app.readIntQueryOrZero(r, "page")
+	if err != nil || page < 0 {
+		app.badRequestResponse(w, r, errors.New("invalid page parameter"))
+		return
+	}
+
+	region := app.requestRegion(r)
+
+	playlist, err := app.tidal.GetPlaylist(playlistUUID, region)
+	if err != nil {
+		app.tidalErrorResponse(w, r, err)
+		return
+	}
+
+	tracks, err := app.tidal.GetPlaylistTracks(playlistUUID, page, region)
+	if err != nil {
+		app.tidalErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"playlist": playlist, "tracks": tracks}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// importTidalPlaylistHandler copies the tidal playlist into a new playlist of the user, which is kept in sync
+// with the tidal one if sync is set
+func (app *application) importTidalPlaylistHandler(w http.ResponseWriter, r *http.Request) {
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	playlistUUID, err := app.readPlaylistUUIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	var input struct {
+		Sync bool `json:"sync"`
+	}
+
+	// the body is optional, without it the playlist isn't synced
+	if r.ContentLength != 0 {
+		err = app.readJSON(w, r, &input)
+		if err != nil {
+			app.handleReadJSONError(w, r, err)
+			return
+		}
+	}
+
+	playlist, playlistImport, err := app.tidal.ImportPlaylist(*userId, playlistUUID, input.Sync, app.userRegion(*userId))
+	if err != nil {
+		app.tidalErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"playlist": playlist, "import": playlistImport}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
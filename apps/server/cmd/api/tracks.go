@@ -1,9 +1,15 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
-	"github.com/altierawr/oto/internal/tidal"
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/profiles"
+	"github.com/altierawr/oto/internal/validator"
+	"github.com/altierawr/oto/internal/waveform"
 )
 
 func (app *application) getSongStreamUrlHandler(w http.ResponseWriter, r *http.Request) {
@@ -13,9 +19,9 @@ func (app *application) getSongStreamUrlHandler(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	stream, err := tidal.GetSongStreamUrl(id)
+	stream, err := app.tidal.Client().GetSongStreamUrl(id, app.requestRegion(r))
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		app.tidalErrorResponse(w, r, err)
 		return
 	}
 
@@ -34,7 +40,36 @@ func (app *application) getSongStreamHandler(w http.ResponseWriter, r *http.Requ
 
 	ss := r.URL.Query().Get("ss")
 
-	app.startStream(w, r, id, ss)
+	// the position of the track in the session queue tells which entry to prefetch next when the queue has
+	// the track more than once
+	queueIndex := -1
+	if queueIndexStr := r.URL.Query().Get("queueIndex"); queueIndexStr != "" {
+		queueIndex, err = strconv.Atoi(queueIndexStr)
+		if err != nil || queueIndex < 0 {
+			app.badRequestResponse(w, r, errors.New("invalid queueIndex parameter"))
+			return
+		}
+	}
+
+	profile, err := app.resolveStreamProfile(r)
+	if err != nil {
+		switch {
+		case errors.Is(err, profiles.ErrUnknownProfile):
+			app.badRequestResponse(w, r, err)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	var variants []*profiles.Profile
+	if r.URL.Query().Get("adaptive") == "true" {
+		variants = profiles.Adaptive()
+		profile = variants[0]
+	}
+
+	app.startStream(w, r, id, queueIndex, ss, profile, variants)
 }
 
 func (app *application) getTrackPlaylistsHandler(w http.ResponseWriter, r *http.Request) {
@@ -61,3 +96,51 @@ func (app *application) getTrackPlaylistsHandler(w http.ResponseWriter, r *http.
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+func (app *application) getTrackWaveformHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil || id < 1 {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	v := validator.New()
+	resolution := app.readInt(r.URL.Query(), "resolution", waveform.DefaultResolution, v)
+	v.Check(resolution >= 1, "resolution", "must be at least 1")
+	v.Check(resolution <= waveform.MaxResolution, "resolution", fmt.Sprintf("must not be more than %d", waveform.MaxResolution))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	data, err := app.waveforms.Get(id, resolution)
+	if err != nil {
+		var failed *waveform.FailedError
+		switch {
+		case errors.As(err, &failed) && failed.NotFound:
+			app.notFoundResponse(w, r)
+		case errors.As(err, &failed):
+			app.waveformFailedResponse(w, r, failed)
+		// the waveform is extracted in the background, the client has to ask again later
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.acceptedResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	response := envelope{
+		"trackId":    id,
+		"duration":   data.Duration,
+		"resolution": len(data.Peaks),
+		"peaks":      data.Peaks,
+		"rms":        data.RMS,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"waveform": response}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
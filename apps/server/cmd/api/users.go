@@ -6,7 +6,10 @@ import (
 
 	"github.com/altierawr/oto/internal/data"
 	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/profiles"
+	"github.com/altierawr/oto/internal/tidal"
 	"github.com/altierawr/oto/internal/validator"
+	"github.com/google/uuid"
 )
 
 func (app *application) loginHandler(w http.ResponseWriter, r *http.Request) {
@@ -301,6 +304,105 @@ func (app *application) getCurrentUserHandler(w http.ResponseWriter, r *http.Req
 	}
 }
 
+func (app *application) updateCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	var input struct {
+		StreamProfile *string `json:"streamProfile"`
+		Normalization *string `json:"normalization"`
+		CountryCode   *string `json:"countryCode"`
+		Locale        *string `json:"locale"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.handleReadJSONError(w, r, err)
+		return
+	}
+
+	user, err := app.db.GetUserById(*userId)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	v := validator.New()
+
+	if input.StreamProfile != nil {
+		// an empty profile resets the user back to the server default
+		if *input.StreamProfile == "" {
+			user.StreamProfile = nil
+		} else {
+			_, err := profiles.Get(*input.StreamProfile)
+			v.Check(err == nil, "streamProfile", "is not a known transcoding profile")
+			user.StreamProfile = input.StreamProfile
+		}
+	}
+
+	if input.Normalization != nil {
+		// an empty mode turns normalization off
+		if *input.Normalization == "" {
+			user.Normalization = nil
+		} else {
+			v.Check(validator.In(*input.Normalization, data.NormalizationTrack, data.NormalizationAlbum),
+				"normalization", "must be track, album or empty")
+			user.Normalization = input.Normalization
+		}
+	}
+
+	// an empty country or locale resets the user back to the server's
+	if input.CountryCode != nil {
+		if *input.CountryCode == "" {
+			user.CountryCode = nil
+		} else {
+			v.Check(validator.Matches(*input.CountryCode, tidal.CountryCodeRX),
+				"countryCode", "must be a two letter country code such as US, or empty")
+			user.CountryCode = input.CountryCode
+		}
+	}
+
+	if input.Locale != nil {
+		if *input.Locale == "" {
+			user.Locale = nil
+		} else {
+			v.Check(validator.Matches(*input.Locale, tidal.LocaleRX), "locale", "must be a locale such as en_US, or empty")
+			user.Locale = input.Locale
+		}
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.db.UpdateUser(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, database.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, user, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
 func (app *application) logOutUserHandler(w http.ResponseWriter, r *http.Request) {
 	refreshTokenCookie, err := r.Cookie("refresh_token")
 	if err != nil {
@@ -351,3 +453,26 @@ func (app *application) logOutUserHandler(w http.ResponseWriter, r *http.Request
 		app.serverErrorResponse(w, r, err)
 	}
 }
+
+// userRegion returns the tidal region that the user has picked, falling back to the server's
+func (app *application) userRegion(userId uuid.UUID) tidal.Region {
+	user, err := app.db.GetUserById(userId)
+	if err != nil {
+		app.logger.Error("couldn't get user for tidal region",
+			"error", err.Error(),
+			"userId", userId)
+		return tidal.Region{}
+	}
+
+	return tidal.UserRegion(user)
+}
+
+// requestRegion returns the tidal region of the user making the request
+func (app *application) requestRegion(r *http.Request) tidal.Region {
+	userId := app.contextGetUserId(r)
+	if userId == nil {
+		return tidal.Region{}
+	}
+
+	return app.userRegion(*userId)
+}
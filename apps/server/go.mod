@@ -4,7 +4,6 @@ go 1.25.1
 
 require (
 	github.com/alexedwards/argon2id v1.0.0
-	github.com/fsnotify/fsnotify v1.9.0
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/golang-migrate/migrate/v4 v4.19.1
 	github.com/google/uuid v1.6.0
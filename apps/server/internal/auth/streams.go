@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StreamURLDuration is how long signed stream URLs stay valid
+const StreamURLDuration = 12 * time.Hour
+
+// StreamAccess is what a signed stream URL grants access to
+type StreamAccess struct {
+	UserId    uuid.UUID
+	SessionId uuid.UUID
+	StreamId  string
+}
+
+func streamSignature(access StreamAccess, expires int64) []byte {
+	mac := hmac.New(sha256.New, accessTokenSecret)
+	fmt.Fprintf(mac, "stream:%s:%s:%s:%d", access.UserId, access.SessionId, access.StreamId, expires)
+	return mac.Sum(nil)
+}
+
+// SignStreamQuery returns the query parameters that authorize requests for the files of a stream
+// without cookies or an authorization header, for HLS players that can't send them
+func SignStreamQuery(access StreamAccess) url.Values {
+	expires := time.Now().Add(StreamURLDuration).Unix()
+
+	query := url.Values{}
+	query.Set("user", access.UserId.String())
+	query.Set("session", access.SessionId.String())
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("sig", hex.EncodeToString(streamSignature(access, expires)))
+
+	return query
+}
+
+// ValidateStreamQuery checks the query parameters created with SignStreamQuery for the stream
+func ValidateStreamQuery(query url.Values, streamId string) (*StreamAccess, error) {
+	userId, err := uuid.Parse(query.Get("user"))
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	sessionId, err := uuid.Parse(query.Get("session"))
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	signature, err := hex.DecodeString(query.Get("sig"))
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	access := StreamAccess{
+		UserId:    userId,
+		SessionId: sessionId,
+		StreamId:  streamId,
+	}
+
+	if !hmac.Equal(signature, streamSignature(access, expires)) {
+		return nil, ErrTokenInvalid
+	}
+
+	if time.Now().Unix() > expires {
+		return nil, ErrTokenExpired
+	}
+
+	return &access, nil
+}
@@ -0,0 +1,391 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/altierawr/oto/internal/database"
+)
+
+type Stats struct {
+	Dir     string  `json:"dir"`
+	Entries int     `json:"entries"`
+	Size    int64   `json:"size"`
+	MaxSize int64   `json:"maxSize"`
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+}
+
+// Service is an on-disk cache of fully transcoded streams, keyed by track id and transcoding profile
+type Service struct {
+	db      *database.DB
+	logger  *slog.Logger
+	dir     string
+	maxSize int64
+
+	hits   atomic.Int64
+	misses atomic.Int64
+
+	// serializes stores and evictions so the size limit is respected
+	writeMu sync.Mutex
+	stop    chan bool
+	done    chan bool
+}
+
+func New(db *database.DB, logger *slog.Logger, dir string, maxSize int64) (*Service, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Service{
+		db:      db,
+		logger:  logger,
+		dir:     dir,
+		maxSize: maxSize,
+		stop:    make(chan bool),
+		done:    make(chan bool),
+	}, nil
+}
+
+func DefaultDir() (string, error) {
+	baseDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(baseDir, "oto", "transcodes"), nil
+}
+
+func (s *Service) RunBackground() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(15 * time.Minute)
+	defer ticker.Stop()
+
+	s.removeMissingEntries()
+	s.evict()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.removeMissingEntries()
+			s.evict()
+		}
+	}
+}
+
+func (s *Service) Stop() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+}
+
+func Key(trackId int64, profile string) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d:%s", trackId, profile))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Service) entryPath(key string) string {
+	return filepath.Join(s.dir, key[:2], key)
+}
+
+// Lookup returns the cache entry for the track and profile if it exists
+func (s *Service) Lookup(trackId int64, profile string) (*database.TranscodeCacheEntry, bool) {
+	key := Key(trackId, profile)
+
+	entry, err := s.db.GetTranscodeCacheEntry(key)
+	if err != nil {
+		if !errors.Is(err, database.ErrRecordNotFound) {
+			s.logger.Error("couldn't get transcode cache entry",
+				"error", err.Error(),
+				"trackId", trackId,
+				"profile", profile)
+		}
+
+		s.misses.Add(1)
+		return nil, false
+	}
+
+	info, err := os.Stat(s.entryPath(key))
+	if err != nil || !info.IsDir() {
+		s.logger.Warn("transcode cache entry is missing from disk",
+			"trackId", trackId,
+			"profile", profile)
+
+		err = s.db.DeleteTranscodeCacheEntry(key)
+		if err != nil {
+			s.logger.Error("couldn't delete transcode cache entry",
+				"error", err.Error(),
+				"key", key)
+		}
+
+		s.misses.Add(1)
+		return nil, false
+	}
+
+	s.hits.Add(1)
+	return entry, true
+}
+
+// Link populates dst with the files of the cache entry. Files are hard linked when possible so that
+// evicting the entry doesn't affect streams that are still using it.
+func (s *Service) Link(entry *database.TranscodeCacheEntry, dst string) error {
+	return copyTree(s.entryPath(entry.Key), dst, true)
+}
+
+// Open opens a file of the cache entry for reading
+func (s *Service) Open(entry *database.TranscodeCacheEntry, name string) (*os.File, error) {
+	return os.Open(filepath.Join(s.entryPath(entry.Key), name))
+}
+
+// Store copies the output of a finished transcode in src into the cache
+func (s *Service) Store(trackId int64, profile string, src string, nrSegments int) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	key := Key(trackId, profile)
+	entryPath := s.entryPath(key)
+
+	err := os.MkdirAll(filepath.Dir(entryPath), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	// copy into a temporary directory first so a half-written entry is never visible
+	tmpDir, err := os.MkdirTemp(filepath.Dir(entryPath), "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	err = copyTree(src, tmpDir, false)
+	if err != nil {
+		return err
+	}
+
+	size, err := dirSize(tmpDir)
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(entryPath)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpDir, entryPath)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.InsertTranscodeCacheEntry(&database.TranscodeCacheEntry{
+		Key:        key,
+		TrackId:    trackId,
+		Profile:    profile,
+		Size:       size,
+		NrSegments: nrSegments,
+	})
+	if err != nil {
+		os.RemoveAll(entryPath)
+		return err
+	}
+
+	s.logger.Info("stored transcode in cache",
+		"trackId", trackId,
+		"profile", profile,
+		"size", size)
+
+	s.evictLocked()
+
+	return nil
+}
+
+func (s *Service) Stats() (*Stats, error) {
+	size, count, err := s.db.GetTranscodeCacheSize()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := Stats{
+		Dir:     s.dir,
+		Entries: count,
+		Size:    size,
+		MaxSize: s.maxSize,
+		Hits:    s.hits.Load(),
+		Misses:  s.misses.Load(),
+	}
+
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+
+	return &stats, nil
+}
+
+func (s *Service) evict() {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.evictLocked()
+}
+
+func (s *Service) evictLocked() {
+	size, _, err := s.db.GetTranscodeCacheSize()
+	if err != nil {
+		s.logger.Error("couldn't get transcode cache size",
+			"error", err.Error())
+		return
+	}
+
+	if size <= s.maxSize {
+		return
+	}
+
+	entries, err := s.db.GetTranscodeCacheEntriesLRU()
+	if err != nil {
+		s.logger.Error("couldn't get transcode cache entries",
+			"error", err.Error())
+		return
+	}
+
+	for _, entry := range entries {
+		if size <= s.maxSize {
+			break
+		}
+
+		err = s.removeEntry(entry.Key)
+		if err != nil {
+			s.logger.Error("couldn't evict transcode cache entry",
+				"error", err.Error(),
+				"trackId", entry.TrackId,
+				"profile", entry.Profile)
+			continue
+		}
+
+		size -= entry.Size
+
+		s.logger.Info("evicted transcode cache entry",
+			"trackId", entry.TrackId,
+			"profile", entry.Profile,
+			"size", entry.Size)
+	}
+}
+
+// removeMissingEntries deletes database entries whose files have been removed from disk
+func (s *Service) removeMissingEntries() {
+	entries, err := s.db.GetTranscodeCacheEntriesLRU()
+	if err != nil {
+		s.logger.Error("couldn't get transcode cache entries",
+			"error", err.Error())
+		return
+	}
+
+	for _, entry := range entries {
+		_, err := os.Stat(s.entryPath(entry.Key))
+		if err == nil {
+			continue
+		}
+
+		err = s.db.DeleteTranscodeCacheEntry(entry.Key)
+		if err != nil {
+			s.logger.Error("couldn't delete transcode cache entry",
+				"error", err.Error(),
+				"key", entry.Key)
+		}
+	}
+}
+
+func (s *Service) removeEntry(key string) error {
+	err := os.RemoveAll(s.entryPath(key))
+	if err != nil {
+		return err
+	}
+
+	return s.db.DeleteTranscodeCacheEntry(key)
+}
+
+func copyTree(src string, dst string, link bool) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+
+		if link && os.Link(path, target) == nil {
+			return nil
+		}
+
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		size += info.Size()
+		return nil
+	})
+
+	return size, err
+}
@@ -13,13 +13,22 @@ var ErrDuplicateUsername = errors.New("duplicate username")
 
 var AnonymousUser = &User{}
 
+const (
+	NormalizationTrack = "track"
+	NormalizationAlbum = "album"
+)
+
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt UnixTime  `json:"createdAt"`
-	Username  string    `json:"username"`
-	Password  password  `json:"-"`
-	IsAdmin   bool      `json:"isAdmin"`
-	Version   int       `json:"-"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     UnixTime  `json:"createdAt"`
+	Username      string    `json:"username"`
+	Password      password  `json:"-"`
+	IsAdmin       bool      `json:"isAdmin"`
+	StreamProfile *string   `json:"streamProfile,omitempty"`
+	Normalization *string   `json:"normalization,omitempty"` // NormalizationTrack, NormalizationAlbum or nil when disabled
+	CountryCode   *string   `json:"countryCode,omitempty"`   // tidal country code, nil for the server's
+	Locale        *string   `json:"locale,omitempty"`        // tidal locale, nil for the server's
+	Version       int       `json:"-"`
 }
 
 func (u *User) IsAnonymous() bool {
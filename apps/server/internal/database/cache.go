@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type TranscodeCacheEntry struct {
+	Key            string `db:"key"`
+	TrackId        int64  `db:"track_id"`
+	Profile        string `db:"profile"`
+	Size           int64  `db:"size"`
+	NrSegments     int    `db:"nr_segments"`
+	CreatedAt      int64  `db:"created_at"`
+	LastAccessedAt int64  `db:"last_accessed_at"`
+}
+
+func (db *DB) InsertTranscodeCacheEntry(entry *TranscodeCacheEntry) error {
+	query := `
+		INSERT INTO transcode_cache_entries (key, track_id, profile, size, nr_segments)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO UPDATE
+		SET size = excluded.size,
+				nr_segments = excluded.nr_segments,
+				last_accessed_at = unixepoch()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{entry.Key, entry.TrackId, entry.Profile, entry.Size, entry.NrSegments}
+
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// GetTranscodeCacheEntry returns the entry with the given key and marks it as accessed
+func (db *DB) GetTranscodeCacheEntry(key string) (*TranscodeCacheEntry, error) {
+	query := `
+		UPDATE transcode_cache_entries
+		SET last_accessed_at = unixepoch()
+		WHERE key = $1
+		RETURNING key, track_id, profile, size, nr_segments, created_at, last_accessed_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entry := TranscodeCacheEntry{}
+	err := db.QueryRowxContext(ctx, query, key).StructScan(&entry)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &entry, nil
+}
+
+// GetTranscodeCacheEntriesLRU returns all entries, least recently accessed first
+func (db *DB) GetTranscodeCacheEntriesLRU() ([]TranscodeCacheEntry, error) {
+	query := `
+		SELECT key, track_id, profile, size, nr_segments, created_at, last_accessed_at
+		FROM transcode_cache_entries
+		ORDER BY last_accessed_at ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entries := []TranscodeCacheEntry{}
+	err := db.SelectContext(ctx, &entries, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (db *DB) GetTranscodeCacheSize() (int64, int, error) {
+	query := `
+		SELECT COALESCE(SUM(size), 0), COUNT(1)
+		FROM transcode_cache_entries`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var size int64
+	var count int
+	err := db.QueryRowContext(ctx, query).Scan(&size, &count)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return size, count, nil
+}
+
+func (db *DB) DeleteTranscodeCacheEntry(key string) error {
+	query := `DELETE FROM transcode_cache_entries WHERE key = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, query, key)
+	return err
+}
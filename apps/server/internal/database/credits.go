@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/altierawr/oto/internal/types"
+)
+
+// ReplaceTidalTrackCredits stores the credits of the track in place of the ones stored before
+func (db *DB) ReplaceTidalTrackCredits(trackId int64, credits []types.TidalCredit) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tidal_track_credits WHERE track_id = $1`, trackId)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT OR IGNORE INTO tidal_track_credits (track_id, type, name, contributor_id, position)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	position := 0
+	for _, credit := range credits {
+		for _, contributor := range credit.Contributors {
+			_, err = tx.ExecContext(ctx, query, trackId, credit.Type, contributor.Name, contributor.ID, position)
+			if err != nil {
+				return err
+			}
+
+			position++
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTidalContributorTracks returns the contributor and the stored tracks that they're credited on, with the
+// credits of each track narrowed down to the contributor's roles
+func (db *DB) GetTidalContributorTracks(contributorId int64) (*types.TidalContributor, []types.TidalSong, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT
+			credits.type,
+			credits.name,
+			tt.id,
+			tt.bpm,
+			tt.duration,
+			tt.explicit,
+			tt.isrc,
+			tt.stream_start_date,
+			tt.title,
+			tt.track_number,
+			tt.volume_number,
+			ta.id,
+			ta.name,
+			ta.picture,
+			ta.selected_album_cover_fallback,
+			tal.id,
+			tal.cover,
+			tal.duration,
+			tal.explicit,
+			tal.number_of_tracks,
+			tal.number_of_volumes,
+			tal.release_date,
+			tal.title,
+			tal.type,
+			tal.upc,
+			tal.vibrant_color,
+			tal.video_cover
+		FROM tidal_track_credits credits
+		JOIN tidal_tracks tt ON tt.id = credits.track_id
+		JOIN tidal_artists ta ON tt.artist_id = ta.id
+		JOIN tidal_albums tal ON tt.album_id = tal.id
+		WHERE credits.contributor_id = $1
+		ORDER BY tal.release_date DESC, tal.id, tt.volume_number, tt.track_number, tt.id, credits.position`
+
+	rows, err := db.QueryContext(ctx, query, contributorId)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	id := int(contributorId)
+	var contributor *types.TidalContributor
+	tracks := []types.TidalSong{}
+
+	for rows.Next() {
+		var creditType string
+		var name string
+		track := types.TidalSong{}
+		artist := types.TidalArtist{}
+		album := types.TidalAlbum{}
+		err = rows.Scan(
+			&creditType,
+			&name,
+			&track.ID,
+			&track.Bpm,
+			&track.Duration,
+			&track.Explicit,
+			&track.ISRC,
+			&track.StreamStartDate,
+			&track.Title,
+			&track.TrackNumber,
+			&track.VolumeNumber,
+			&artist.ID,
+			&artist.Name,
+			&artist.Picture,
+			&artist.SelectedAlbumCoverFallback,
+			&album.ID,
+			&album.Cover,
+			&album.Duration,
+			&album.Explicit,
+			&album.NumberOfTracks,
+			&album.NumberOfVolumes,
+			&album.ReleaseDate,
+			&album.Title,
+			&album.Type,
+			&album.UPC,
+			&album.VibrantColor,
+			&album.VideoCover,
+		)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if contributor == nil {
+			contributor = &types.TidalContributor{ID: &id, Name: name}
+		}
+
+		credit := types.TidalCredit{
+			Type:         creditType,
+			Contributors: []types.TidalContributor{*contributor},
+		}
+
+		// the rows of a track come one after another, one for each of the contributor's roles on it
+		if len(tracks) > 0 && tracks[len(tracks)-1].ID == track.ID {
+			tracks[len(tracks)-1].Credits = append(tracks[len(tracks)-1].Credits, credit)
+			continue
+		}
+
+		track.Artists = []types.TidalArtist{artist}
+		track.Album = &album
+		track.Credits = []types.TidalCredit{credit}
+
+		tracks = append(tracks, track)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if contributor == nil {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	return contributor, tracks, nil
+}
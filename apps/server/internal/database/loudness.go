@@ -0,0 +1,152 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"time"
+)
+
+// TrackLoudness is the EBU R128 loudness of a track and of the album it's on, nil if not analyzed yet
+type TrackLoudness struct {
+	TrackIntegrated *float64
+	TrackTruePeak   *float64
+	AlbumIntegrated *float64
+	AlbumTruePeak   *float64
+}
+
+func (db *DB) IsTidalTrackLoudnessAnalyzed(trackId int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT loudness_analyzed_at IS NOT NULL
+		FROM tidal_tracks
+		WHERE id = $1`
+
+	var analyzed bool
+	err := db.QueryRowContext(ctx, query, trackId).Scan(&analyzed)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return false, ErrRecordNotFound
+		default:
+			return false, err
+		}
+	}
+
+	return analyzed, nil
+}
+
+func (db *DB) GetTidalTrackLoudness(trackId int64) (*TrackLoudness, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+		SELECT tt.loudness_integrated, tt.loudness_true_peak, tal.loudness_integrated, tal.loudness_true_peak
+		FROM tidal_tracks tt
+		LEFT JOIN tidal_albums tal ON tal.id = tt.album_id
+		WHERE tt.id = $1`
+
+	var loudness TrackLoudness
+	err := db.QueryRowContext(ctx, query, trackId).Scan(
+		&loudness.TrackIntegrated,
+		&loudness.TrackTruePeak,
+		&loudness.AlbumIntegrated,
+		&loudness.AlbumTruePeak,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &loudness, nil
+}
+
+// UpdateTidalTrackLoudness stores the loudness of the track and recomputes the loudness of its album from
+// all of the album's analyzed tracks. The album loudness is the power average of the track loudnesses and
+// its peak is the highest track peak.
+func (db *DB) UpdateTidalTrackLoudness(trackId int64, integrated float64, truePeak float64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	updateTrackQuery := `
+		UPDATE tidal_tracks
+		SET loudness_integrated = $1, loudness_true_peak = $2, loudness_analyzed_at = unixepoch()
+		WHERE id = $3
+		RETURNING album_id`
+
+	var albumId *int64
+	err = tx.QueryRowContext(ctx, updateTrackQuery, integrated, truePeak, trackId).Scan(&albumId)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if albumId == nil {
+		return tx.Commit()
+	}
+
+	albumTracksQuery := `
+		SELECT loudness_integrated, loudness_true_peak
+		FROM tidal_tracks
+		WHERE album_id = $1 AND loudness_integrated IS NOT NULL AND loudness_true_peak IS NOT NULL`
+
+	rows, err := tx.QueryContext(ctx, albumTracksQuery, *albumId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	power := 0.0
+	peak := math.Inf(-1)
+	count := 0
+	for rows.Next() {
+		var trackIntegrated, trackPeak float64
+		err = rows.Scan(&trackIntegrated, &trackPeak)
+		if err != nil {
+			return err
+		}
+
+		power += math.Pow(10, trackIntegrated/10)
+		peak = math.Max(peak, trackPeak)
+		count++
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return tx.Commit()
+	}
+
+	albumIntegrated := 10 * math.Log10(power/float64(count))
+
+	updateAlbumQuery := `
+		UPDATE tidal_albums
+		SET loudness_integrated = $1, loudness_true_peak = $2
+		WHERE id = $3`
+
+	_, err = tx.ExecContext(ctx, updateAlbumQuery, albumIntegrated, peak, *albumId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,141 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sources of stored lyrics. LyricsSourceNone records that Tidal had no lyrics for the track, so that it
+// isn't asked again on every request.
+const (
+	LyricsSourceTidal = "tidal"
+	LyricsSourceUser  = "user"
+	LyricsSourceNone  = "none"
+)
+
+type TrackLyrics struct {
+	TrackId  int64   `db:"track_id"`
+	Source   string  `db:"source"`
+	Provider *string `db:"provider"`
+	// Unsynced lyrics
+	Text *string `db:"text"`
+	// Synced lyrics in the LRC format
+	Subtitles     *string    `db:"subtitles"`
+	IsRightToLeft bool       `db:"is_right_to_left"`
+	UploadedBy    *uuid.UUID `db:"uploaded_by"`
+	FetchedAt     int64      `db:"fetched_at"`
+}
+
+func (db *DB) GetTidalTrackLyrics(trackId int64) (*TrackLyrics, error) {
+	query := `
+		SELECT track_id, source, provider, text, subtitles, is_right_to_left, uploaded_by, fetched_at
+		FROM tidal_track_lyrics
+		WHERE track_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	lyrics := TrackLyrics{}
+	err := db.QueryRowxContext(ctx, query, trackId).StructScan(&lyrics)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &lyrics, nil
+}
+
+// UpsertTidalTrackLyrics stores lyrics fetched from Tidal, or that Tidal has none. Lyrics uploaded by a user are
+// kept.
+func (db *DB) UpsertTidalTrackLyrics(lyrics *TrackLyrics) error {
+	query := `
+		INSERT INTO tidal_track_lyrics (track_id, source, provider, text, subtitles, is_right_to_left)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO UPDATE
+		SET source = excluded.source,
+				provider = excluded.provider,
+				text = excluded.text,
+				subtitles = excluded.subtitles,
+				is_right_to_left = excluded.is_right_to_left,
+				uploaded_by = NULL,
+				fetched_at = unixepoch()
+		WHERE tidal_track_lyrics.source != 'user'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{lyrics.TrackId, lyrics.Source, lyrics.Provider, lyrics.Text, lyrics.Subtitles, lyrics.IsRightToLeft}
+
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+// UpsertUserTrackLyrics stores lyrics uploaded by the user. It returns ErrEditConflict if the track has lyrics
+// from Tidal.
+func (db *DB) UpsertUserTrackLyrics(trackId int64, userId uuid.UUID, text *string, subtitles *string) error {
+	query := `
+		INSERT INTO tidal_track_lyrics (track_id, source, text, subtitles, uploaded_by)
+		VALUES ($1, 'user', $2, $3, $4)
+		ON CONFLICT DO UPDATE
+		SET source = excluded.source,
+				provider = NULL,
+				text = excluded.text,
+				subtitles = excluded.subtitles,
+				is_right_to_left = 0,
+				uploaded_by = excluded.uploaded_by,
+				fetched_at = unixepoch()
+		WHERE tidal_track_lyrics.source != 'tidal'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, query, trackId, text, subtitles, userId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrEditConflict
+	}
+
+	return nil
+}
+
+// DeleteUserTrackLyrics deletes lyrics uploaded by a user, the ones from Tidal can't be deleted
+func (db *DB) DeleteUserTrackLyrics(trackId int64) error {
+	query := `
+		DELETE FROM tidal_track_lyrics
+		WHERE track_id = $1 AND source = 'user'`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, query, trackId)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -170,7 +170,7 @@ func (db *DB) GetUserPlaylists(userId uuid.UUID) ([]UserPlaylistSummary, error)
 		JOIN tidal_artists ta ON tt.artist_id = ta.id
 		JOIN tidal_albums tal ON tt.album_id = tal.id
 		WHERE pt.playlist_id IN (?)
-		ORDER BY pt.created_at ASC
+		ORDER BY pt.position ASC
 	`, playlistIds)
 	if err != nil {
 		return nil, err
@@ -274,7 +274,7 @@ func (db *DB) GetUserPlaylist(userID uuid.UUID, playlistID int64) (*UserPlaylist
 		JOIN tidal_artists ta ON tt.artist_id = ta.id
 		JOIN tidal_albums tal ON tt.album_id = tal.id
 		WHERE playlist_tracks.playlist_id = $1
-		ORDER BY playlist_tracks.created_at ASC`
+		ORDER BY playlist_tracks.position ASC`
 
 	seenCoverURLs := map[string]struct{}{}
 
@@ -395,7 +395,9 @@ func (db *DB) AddTrackToPlaylist(userID uuid.UUID, playlistID int64, track *type
 		return err
 	}
 
-	addPlaylistTrackQuery := `INSERT OR IGNORE INTO playlist_tracks (playlist_id, track_id) VALUES ($1, $2)`
+	addPlaylistTrackQuery := `
+		INSERT OR IGNORE INTO playlist_tracks (playlist_id, track_id, position)
+		VALUES ($1, $2, (SELECT COALESCE(MAX(position) + 1, 0) FROM playlist_tracks WHERE playlist_id = $1))`
 	result, err := tx.ExecContext(ctx, addPlaylistTrackQuery, playlistID, track.ID)
 	if err != nil {
 		return err
@@ -464,6 +466,83 @@ func (db *DB) RemoveTrackFromPlaylist(userID uuid.UUID, playlistID int64, trackI
 	return tx.Commit()
 }
 
+// SetPlaylistTracks makes the playlist have the given tracks in their order. Tracks that stay in the playlist keep
+// when they were added, only their position changes. A track that is given more than once is only added where
+// it first appears.
+func (db *DB) SetPlaylistTracks(userID uuid.UUID, playlistID int64, tracks []types.TidalSong) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var count int
+	checkPlaylistQuery := `SELECT COUNT(1) FROM playlists WHERE user_id = $1 AND id = $2`
+	err = tx.QueryRowContext(ctx, checkPlaylistQuery, userID, playlistID).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrRecordNotFound
+	}
+
+	err = db.InsertTidalTracks(tracks, tx)
+	if err != nil {
+		return err
+	}
+
+	var existing []int64
+	existingQuery := `SELECT track_id FROM playlist_tracks WHERE playlist_id = $1`
+	err = tx.SelectContext(ctx, &existing, existingQuery, playlistID)
+	if err != nil {
+		return err
+	}
+
+	positions := make(map[int64]int, len(tracks))
+	for _, track := range tracks {
+		if _, found := positions[int64(track.ID)]; !found {
+			positions[int64(track.ID)] = len(positions)
+		}
+	}
+
+	deletePlaylistTrackQuery := `DELETE FROM playlist_tracks WHERE playlist_id = $1 AND track_id = $2`
+	for _, trackID := range existing {
+		if _, found := positions[trackID]; found {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, deletePlaylistTrackQuery, playlistID, trackID)
+		if err != nil {
+			return err
+		}
+	}
+
+	setPlaylistTrackQuery := `
+		INSERT INTO playlist_tracks (playlist_id, track_id, position)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (playlist_id, track_id) DO UPDATE
+		SET position = excluded.position
+		WHERE position != excluded.position`
+	for trackID, position := range positions {
+		_, err = tx.ExecContext(ctx, setPlaylistTrackQuery, playlistID, trackID, position)
+		if err != nil {
+			return err
+		}
+	}
+
+	updatePlaylistQuery := `UPDATE playlists SET updated_at = unixepoch() WHERE id = $1`
+	_, err = tx.ExecContext(ctx, updatePlaylistQuery, playlistID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 func (db *DB) GetTrackPlaylists(userId uuid.UUID, trackId int64) ([]TrackPlaylist, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -484,7 +563,7 @@ func (db *DB) GetTrackPlaylists(userId uuid.UUID, trackId int64) ([]TrackPlaylis
 			WHERE matching_tracks.playlist_id = playlists.id
 			AND matching_tracks.track_id = $2
 		)
-		ORDER BY playlists.created_at DESC, playlist_tracks.created_at ASC`
+		ORDER BY playlists.created_at DESC, playlist_tracks.position ASC`
 
 	playlists := []TrackPlaylist{}
 	indexById := map[int64]int{}
@@ -341,6 +341,8 @@ func (db *DB) GetSession(userId uuid.UUID, sessionId uuid.UUID) (*data.Session,
 			tt.title,
 			tt.track_number,
 			tt.volume_number,
+			tt.loudness_integrated,
+			tt.loudness_true_peak,
 			ta.id,
 			ta.name,
 			ta.picture,
@@ -356,7 +358,9 @@ func (db *DB) GetSession(userId uuid.UUID, sessionId uuid.UUID) (*data.Session,
 			tal.type,
 			tal.upc,
 			tal.vibrant_color,
-			tal.video_cover
+			tal.video_cover,
+			tal.loudness_integrated,
+			tal.loudness_true_peak
 		FROM session_tracks
 		INNER JOIN tidal_tracks tt ON tt.id = session_tracks.track_id
 		INNER JOIN tidal_artists ta ON tt.artist_id = ta.id
@@ -387,6 +391,8 @@ func (db *DB) GetSession(userId uuid.UUID, sessionId uuid.UUID) (*data.Session,
 			&track.Title,
 			&track.TrackNumber,
 			&track.VolumeNumber,
+			&track.IntegratedLoudness,
+			&track.TruePeak,
 			&artist.ID,
 			&artist.Name,
 			&artist.Picture,
@@ -403,6 +409,8 @@ func (db *DB) GetSession(userId uuid.UUID, sessionId uuid.UUID) (*data.Session,
 			&album.UPC,
 			&album.VibrantColor,
 			&album.VideoCover,
+			&album.IntegratedLoudness,
+			&album.TruePeak,
 		)
 
 		if err != nil {
@@ -287,6 +287,8 @@ func (db *DB) GetTidalTrack(id int64) (*types.TidalSong, error) {
 			tt.title,
 			tt.track_number,
 			tt.volume_number,
+			tt.loudness_integrated,
+			tt.loudness_true_peak,
 			ta.id,
 			ta.name,
 			ta.picture,
@@ -302,7 +304,9 @@ func (db *DB) GetTidalTrack(id int64) (*types.TidalSong, error) {
 			tal.type,
 			tal.upc,
 			tal.vibrant_color,
-			tal.video_cover
+			tal.video_cover,
+			tal.loudness_integrated,
+			tal.loudness_true_peak
 		FROM tidal_tracks tt
 		JOIN tidal_artists ta ON tt.artist_id = ta.id
 		JOIN tidal_albums tal ON tt.album_id = tal.id
@@ -321,6 +325,8 @@ func (db *DB) GetTidalTrack(id int64) (*types.TidalSong, error) {
 		&track.Title,
 		&track.TrackNumber,
 		&track.VolumeNumber,
+		&track.IntegratedLoudness,
+		&track.TruePeak,
 		&artist.ID,
 		&artist.Name,
 		&artist.Picture,
@@ -337,6 +343,8 @@ func (db *DB) GetTidalTrack(id int64) (*types.TidalSong, error) {
 		&album.UPC,
 		&album.VibrantColor,
 		&album.VideoCover,
+		&album.IntegratedLoudness,
+		&album.TruePeak,
 	)
 	if err != nil {
 		switch {
@@ -368,6 +376,8 @@ func (db *DB) GetTidalAlbum(id int) (*types.TidalAlbum, error) {
 	    tt.title,
 	    tt.track_number,
 	    tt.volume_number,
+	    tt.loudness_integrated,
+	    tt.loudness_true_peak,
 	    ta.id,
 	    ta.name,
 	    ta.picture,
@@ -384,6 +394,8 @@ func (db *DB) GetTidalAlbum(id int) (*types.TidalAlbum, error) {
 	    tal.upc,
 	    tal.vibrant_color,
 	    tal.video_cover,
+	    tal.loudness_integrated,
+	    tal.loudness_true_peak,
 	    aa.id,
 	    aa.name,
 	    aa.picture,
@@ -418,6 +430,8 @@ func (db *DB) GetTidalAlbum(id int) (*types.TidalAlbum, error) {
 			&track.Title,
 			&track.TrackNumber,
 			&track.VolumeNumber,
+			&track.IntegratedLoudness,
+			&track.TruePeak,
 			&artist.ID,
 			&artist.Name,
 			&artist.Picture,
@@ -434,6 +448,8 @@ func (db *DB) GetTidalAlbum(id int) (*types.TidalAlbum, error) {
 			&scanAlbum.UPC,
 			&scanAlbum.VibrantColor,
 			&scanAlbum.VideoCover,
+			&scanAlbum.IntegratedLoudness,
+			&scanAlbum.TruePeak,
 			&albumArtist.ID,
 			&albumArtist.Name,
 			&albumArtist.Picture,
@@ -470,6 +486,8 @@ func (db *DB) GetTidalTrackByArtistAndTitle(artistName string, title string) (*t
 			tt.title,
 			tt.track_number,
 			tt.volume_number,
+			tt.loudness_integrated,
+			tt.loudness_true_peak,
 			ta.id,
 			ta.name,
 			ta.picture,
@@ -485,7 +503,9 @@ func (db *DB) GetTidalTrackByArtistAndTitle(artistName string, title string) (*t
 			tal.type,
 			tal.upc,
 			tal.vibrant_color,
-			tal.video_cover
+			tal.video_cover,
+			tal.loudness_integrated,
+			tal.loudness_true_peak
 		FROM tidal_tracks tt
 		INNER JOIN tidal_artists ta ON tt.artist_id = ta.id
 		INNER JOIN tidal_albums tal ON tt.album_id = tal.id
@@ -507,6 +527,8 @@ func (db *DB) GetTidalTrackByArtistAndTitle(artistName string, title string) (*t
 		&track.Title,
 		&track.TrackNumber,
 		&track.VolumeNumber,
+		&track.IntegratedLoudness,
+		&track.TruePeak,
 		&artist.ID,
 		&artist.Name,
 		&artist.Picture,
@@ -523,6 +545,8 @@ func (db *DB) GetTidalTrackByArtistAndTitle(artistName string, title string) (*t
 		&album.UPC,
 		&album.VibrantColor,
 		&album.VideoCover,
+		&album.IntegratedLoudness,
+		&album.TruePeak,
 	)
 	if err != nil {
 		switch {
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// TidalResponse is a raw response of the Tidal catalog, keyed by the path and query it was requested with
+type TidalResponse struct {
+	Key       string `db:"key"`
+	Resource  string `db:"resource"`
+	Body      []byte `db:"body"`
+	FetchedAt int64  `db:"fetched_at"`
+}
+
+func (db *DB) GetTidalResponse(key string) (*TidalResponse, error) {
+	query := `
+		SELECT key, resource, body, fetched_at
+		FROM tidal_response_cache
+		WHERE key = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	response := TidalResponse{}
+	err := db.QueryRowxContext(ctx, query, key).StructScan(&response)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &response, nil
+}
+
+func (db *DB) UpsertTidalResponse(key string, resource string, body []byte) error {
+	query := `
+		INSERT INTO tidal_response_cache (key, resource, body)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO UPDATE
+		SET resource = excluded.resource,
+				body = excluded.body,
+				fetched_at = unixepoch()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, query, key, resource, body)
+	return err
+}
+
+func (db *DB) DeleteTidalResponse(key string) error {
+	query := `DELETE FROM tidal_response_cache WHERE key = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, query, key)
+	return err
+}
+
+// DeleteTidalResponsesBefore deletes the responses that were fetched before the time and returns how many there were
+func (db *DB) DeleteTidalResponsesBefore(before time.Time) (int64, error) {
+	query := `DELETE FROM tidal_response_cache WHERE fetched_at < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := db.ExecContext(ctx, query, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// GetTidalResponseCounts returns how many responses are cached for each resource
+func (db *DB) GetTidalResponseCounts() (map[string]int, error) {
+	query := `
+		SELECT resource, COUNT(*)
+		FROM tidal_response_cache
+		GROUP BY resource`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var resource string
+		var count int
+		if err := rows.Scan(&resource, &count); err != nil {
+			return nil, err
+		}
+
+		counts[resource] = count
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TidalPlaylistImport links a local playlist to the tidal playlist it was imported from
+type TidalPlaylistImport struct {
+	PlaylistId int64     `db:"playlist_id" json:"playlistId"`
+	UserId     uuid.UUID `db:"user_id" json:"-"`
+	TidalUUID  string    `db:"tidal_uuid" json:"tidalUuid"`
+	Sync       bool      `db:"sync" json:"sync"`
+	SyncedAt   int64     `db:"synced_at" json:"syncedAt"`
+}
+
+func (db *DB) InsertTidalPlaylistImport(playlistImport *TidalPlaylistImport) error {
+	query := `
+		INSERT INTO tidal_playlist_imports (playlist_id, user_id, tidal_uuid, sync)
+		VALUES ($1, $2, $3, $4)
+		RETURNING synced_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return db.QueryRowContext(
+		ctx,
+		query,
+		playlistImport.PlaylistId,
+		playlistImport.UserId,
+		playlistImport.TidalUUID,
+		playlistImport.Sync,
+	).Scan(&playlistImport.SyncedAt)
+}
+
+// GetTidalPlaylistImportsToSync returns the imports that are kept in sync and were last synced before the time
+func (db *DB) GetTidalPlaylistImportsToSync(before time.Time) ([]TidalPlaylistImport, error) {
+	query := `
+		SELECT playlist_id, user_id, tidal_uuid, sync, synced_at
+		FROM tidal_playlist_imports
+		WHERE sync = 1 AND synced_at < $1
+		ORDER BY synced_at ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	imports := []TidalPlaylistImport{}
+	err := db.SelectContext(ctx, &imports, query, before.Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	return imports, nil
+}
+
+func (db *DB) SetTidalPlaylistSynced(playlistId int64) error {
+	query := `UPDATE tidal_playlist_imports SET synced_at = unixepoch() WHERE playlist_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, query, playlistId)
+	return err
+}
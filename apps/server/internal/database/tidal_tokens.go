@@ -0,0 +1,70 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// TidalTokens are the oauth tokens of a Tidal account along with the client they were issued to, which is the
+// only client that can refresh them
+type TidalTokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	ClientId     string
+	ClientSecret string
+}
+
+func (db *DB) GetTidalTokens(account string) (*TidalTokens, error) {
+	query := `
+		SELECT access_token, refresh_token, expires_at, client_id, client_secret
+		FROM tidal_tokens
+		WHERE account = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var tokens TidalTokens
+	var expiresAt int64
+	err := db.QueryRowContext(ctx, query, account).Scan(
+		&tokens.AccessToken,
+		&tokens.RefreshToken,
+		&expiresAt,
+		&tokens.ClientId,
+		&tokens.ClientSecret,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	tokens.ExpiresAt = time.Unix(expiresAt, 0)
+
+	return &tokens, nil
+}
+
+func (db *DB) UpsertTidalTokens(account string, tokens *TidalTokens) error {
+	query := `
+		INSERT INTO tidal_tokens (account, access_token, refresh_token, expires_at, client_id, client_secret)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO UPDATE
+		SET access_token = excluded.access_token,
+				refresh_token = excluded.refresh_token,
+				expires_at = excluded.expires_at,
+				client_id = excluded.client_id,
+				client_secret = excluded.client_secret,
+				updated_at = unixepoch()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, query, account, tokens.AccessToken, tokens.RefreshToken, tokens.ExpiresAt.Unix(),
+		tokens.ClientId, tokens.ClientSecret)
+	return err
+}
@@ -128,7 +128,7 @@ func (db *DB) GetAdminUsers() ([]data.User, error) {
 
 func (db *DB) GetUserById(id uuid.UUID) (*data.User, error) {
 	query := `
-		SELECT id, created_at, username, password_hash, is_admin, version
+		SELECT id, created_at, username, password_hash, is_admin, stream_profile, normalization, country_code, locale, version
 		FROM users
 		WHERE id = $1`
 
@@ -143,6 +143,10 @@ func (db *DB) GetUserById(id uuid.UUID) (*data.User, error) {
 		&user.Username,
 		&user.Password.Hash,
 		&user.IsAdmin,
+		&user.StreamProfile,
+		&user.Normalization,
+		&user.CountryCode,
+		&user.Locale,
 		&user.Version,
 	)
 
@@ -160,7 +164,7 @@ func (db *DB) GetUserById(id uuid.UUID) (*data.User, error) {
 
 func (db *DB) GetUserByUsername(username string) (*data.User, error) {
 	query := `
-		SELECT id, created_at, username, password_hash, is_admin, version
+		SELECT id, created_at, username, password_hash, is_admin, stream_profile, normalization, country_code, locale, version
 		FROM users
 		WHERE username = $1`
 
@@ -175,6 +179,10 @@ func (db *DB) GetUserByUsername(username string) (*data.User, error) {
 		&user.Username,
 		&user.Password.Hash,
 		&user.IsAdmin,
+		&user.StreamProfile,
+		&user.Normalization,
+		&user.CountryCode,
+		&user.Locale,
 		&user.Version,
 	)
 
@@ -193,14 +201,18 @@ func (db *DB) GetUserByUsername(username string) (*data.User, error) {
 func (db *DB) UpdateUser(user *data.User) error {
 	query := `
 		UPDATE users
-		SET username = $1, password_hash = $2, is_admin = $3, version = version + 1
-		WHERE id = $4 AND version = $5
+		SET username = $1, password_hash = $2, is_admin = $3, stream_profile = $4, normalization = $5, country_code = $6, locale = $7, version = version + 1
+		WHERE id = $8 AND version = $9
 		RETURNING version`
 
 	args := []any{
 		user.Username,
 		user.Password.Hash,
 		user.IsAdmin,
+		user.StreamProfile,
+		user.Normalization,
+		user.CountryCode,
+		user.Locale,
 		user.ID,
 		user.Version,
 	}
@@ -225,7 +237,7 @@ func (db *DB) UpdateUser(user *data.User) error {
 
 func (db *DB) GetUserForToken(tokenScope, tokenPlaintext string) (*data.User, error) {
 	query := `
-		SELECT users.id, users.created_at, users.username, users.password_hash, users.is_admin, users.version
+		SELECT users.id, users.created_at, users.username, users.password_hash, users.is_admin, users.stream_profile, users.normalization, users.country_code, users.locale, users.version
 		FROM users
 		INNER JOIN tokens
 		ON users.id = tokens.user_id
@@ -248,6 +260,10 @@ func (db *DB) GetUserForToken(tokenScope, tokenPlaintext string) (*data.User, er
 		&user.Username,
 		&user.Password.Hash,
 		&user.IsAdmin,
+		&user.StreamProfile,
+		&user.Normalization,
+		&user.CountryCode,
+		&user.Locale,
 		&user.Version,
 	)
 	if err != nil {
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// TrackWaveform is the peak and RMS amplitude of a track in evenly sized buckets, from 0 to 1
+type TrackWaveform struct {
+	TrackId int64
+	// Duration of the decoded audio in seconds
+	Duration float64
+	Peaks    []float32
+	RMS      []float32
+}
+
+func (db *DB) InsertTidalTrackWaveform(waveform *TrackWaveform) error {
+	if len(waveform.Peaks) != len(waveform.RMS) {
+		return fmt.Errorf("waveform has %d peaks but %d rms values", len(waveform.Peaks), len(waveform.RMS))
+	}
+
+	query := `
+		INSERT INTO tidal_track_waveforms (track_id, duration, resolution, peaks, rms)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO UPDATE
+		SET duration = excluded.duration,
+				resolution = excluded.resolution,
+				peaks = excluded.peaks,
+				rms = excluded.rms,
+				created_at = unixepoch()`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	args := []any{
+		waveform.TrackId,
+		waveform.Duration,
+		len(waveform.Peaks),
+		encodeFloats(waveform.Peaks),
+		encodeFloats(waveform.RMS),
+	}
+
+	_, err := db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (db *DB) GetTidalTrackWaveform(trackId int64) (*TrackWaveform, error) {
+	query := `
+		SELECT duration, peaks, rms
+		FROM tidal_track_waveforms
+		WHERE track_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	waveform := TrackWaveform{TrackId: trackId}
+	var peaks, rms []byte
+	err := db.QueryRowContext(ctx, query, trackId).Scan(&waveform.Duration, &peaks, &rms)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	waveform.Peaks = decodeFloats(peaks)
+	waveform.RMS = decodeFloats(rms)
+
+	return &waveform, nil
+}
+
+// encodeFloats packs the values as little endian float32s
+func encodeFloats(values []float32) []byte {
+	data := make([]byte, 0, len(values)*4)
+	for _, value := range values {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(value))
+	}
+
+	return data
+}
+
+func decodeFloats(data []byte) []float32 {
+	values := make([]float32, len(data)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+
+	return values
+}
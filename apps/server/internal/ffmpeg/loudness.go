@@ -0,0 +1,92 @@
+package ffmpeg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+var ErrNoLoudnessSummary = errors.New("ffmpeg output has no loudness summary")
+
+// Loudness is the EBU R128 loudness of a piece of audio
+type Loudness struct {
+	// Integrated loudness in LUFS
+	Integrated float64
+	// True peak in dBTP
+	TruePeak float64
+}
+
+// AnalyzeLoudness decodes the whole input through the ebur128 filter and returns its loudness
+func AnalyzeLoudness(ctx context.Context, input string) (*Loudness, error) {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats", "-loglevel", "info",
+		"-i", input,
+		"-map", "0:a:0",
+		"-af", "ebur128=peak=true",
+		"-f", "null", "-",
+	)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		tail := strings.TrimSpace(string(output))
+		if i := strings.LastIndex(tail, "\n"); i >= 0 {
+			tail = tail[i+1:]
+		}
+
+		return nil, fmt.Errorf("ffmpeg loudness analysis failed: %w: %s", err, tail)
+	}
+
+	return ParseLoudnessSummary(string(output))
+}
+
+// ParseLoudnessSummary reads the summary that the ebur128 filter logs once the input ends
+func ParseLoudnessSummary(output string) (*Loudness, error) {
+	_, summary, found := strings.Cut(output, "Summary:")
+	if !found {
+		return nil, ErrNoLoudnessSummary
+	}
+
+	integrated, hasIntegrated := summaryValue(summary, "I:")
+	truePeak, hasPeak := summaryValue(summary, "Peak:")
+	if !hasIntegrated || !hasPeak {
+		return nil, ErrNoLoudnessSummary
+	}
+
+	// silence has no measurable loudness
+	if math.IsInf(integrated, 0) || math.IsNaN(integrated) || math.IsNaN(truePeak) {
+		return nil, fmt.Errorf("audio has no measurable loudness")
+	}
+
+	return &Loudness{
+		Integrated: integrated,
+		TruePeak:   truePeak,
+	}, nil
+}
+
+// summaryValue parses lines such as "I:         -14.2 LUFS"
+func summaryValue(summary string, label string) (float64, bool) {
+	for _, line := range strings.Split(summary, "\n") {
+		value, found := strings.CutPrefix(strings.TrimSpace(line), label)
+		if !found {
+			continue
+		}
+
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return 0, false
+		}
+
+		number, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return 0, false
+		}
+
+		return number, true
+	}
+
+	return 0, false
+}
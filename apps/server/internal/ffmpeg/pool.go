@@ -0,0 +1,193 @@
+package ffmpeg
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPoolFull  = errors.New("all transcoders are busy")
+	ErrUserLimit = errors.New("too many concurrent transcodes for user")
+)
+
+// Pool limits how many ffmpeg processes run at once, globally and per user. Requests over the global limit
+// wait in a FIFO queue until a slot frees up or the queue timeout passes.
+type Pool struct {
+	mu           sync.Mutex
+	maxWorkers   int
+	maxPerUser   int
+	queueTimeout time.Duration
+	running      int
+	users        map[string]int
+	waiters      *list.List
+}
+
+type PoolState struct {
+	MaxWorkers   int            `json:"maxWorkers"`
+	MaxPerUser   int            `json:"maxPerUser"`
+	QueueTimeout float64        `json:"queueTimeout"`
+	Running      int            `json:"running"`
+	Queued       int            `json:"queued"`
+	Users        map[string]int `json:"users"`
+}
+
+// Slot is a reserved place in the pool, it has to be released once the ffmpeg process exits
+type Slot struct {
+	pool   *Pool
+	userId string
+	once   sync.Once
+}
+
+type waiter struct {
+	userId string
+	ready  chan struct{}
+}
+
+// NewPool creates a pool. A maxPerUser of 0 disables the per user limit.
+func NewPool(maxWorkers int, maxPerUser int, queueTimeout time.Duration) *Pool {
+	return &Pool{
+		maxWorkers:   maxWorkers,
+		maxPerUser:   maxPerUser,
+		queueTimeout: queueTimeout,
+		users:        map[string]int{},
+		waiters:      list.New(),
+	}
+}
+
+// Acquire reserves a slot for the user, waiting in the queue if needed. A user at their limit while the pool
+// has free slots gets ErrUserLimit right away, since only one of their own transcodes exiting would let them
+// in. Otherwise it returns ErrUserLimit if the user was still at their limit when the wait timed out and
+// ErrPoolFull if the pool was.
+func (p *Pool) Acquire(ctx context.Context, userId string) (*Slot, error) {
+	w := &waiter{
+		userId: userId,
+		ready:  make(chan struct{}),
+	}
+
+	p.mu.Lock()
+	if p.isUserLimitedLocked(userId) && p.running < p.maxWorkers {
+		p.mu.Unlock()
+		return nil, ErrUserLimit
+	}
+
+	elem := p.waiters.PushBack(w)
+	p.dispatchLocked()
+	p.mu.Unlock()
+
+	timer := time.NewTimer(p.queueTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-w.ready:
+		return &Slot{pool: p, userId: userId}, nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timer.C:
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// the slot might have been granted while the timeout fired
+	select {
+	case <-w.ready:
+		return &Slot{pool: p, userId: userId}, nil
+	default:
+	}
+
+	p.waiters.Remove(elem)
+	p.dispatchLocked()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isUserLimitedLocked(userId) {
+		return nil, ErrUserLimit
+	}
+
+	return nil, ErrPoolFull
+}
+
+// TryAcquire reserves a slot only if one is free right away and nobody is waiting for it
+func (p *Pool) TryAcquire(userId string) (*Slot, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.isUserLimitedLocked(userId) {
+		return nil, ErrUserLimit
+	}
+
+	if p.waiters.Len() > 0 || p.running >= p.maxWorkers {
+		return nil, ErrPoolFull
+	}
+
+	p.takeLocked(userId)
+
+	return &Slot{pool: p, userId: userId}, nil
+}
+
+func (p *Pool) State() PoolState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	users := make(map[string]int, len(p.users))
+	for userId, count := range p.users {
+		users[userId] = count
+	}
+
+	return PoolState{
+		MaxWorkers:   p.maxWorkers,
+		MaxPerUser:   p.maxPerUser,
+		QueueTimeout: p.queueTimeout.Seconds(),
+		Running:      p.running,
+		Queued:       p.waiters.Len(),
+		Users:        users,
+	}
+}
+
+// Release frees the slot, it is safe to call more than once
+func (s *Slot) Release() {
+	s.once.Do(func() {
+		s.pool.mu.Lock()
+		defer s.pool.mu.Unlock()
+
+		s.pool.running--
+		s.pool.users[s.userId]--
+		if s.pool.users[s.userId] <= 0 {
+			delete(s.pool.users, s.userId)
+		}
+
+		s.pool.dispatchLocked()
+	})
+}
+
+func (p *Pool) isUserLimitedLocked(userId string) bool {
+	return p.maxPerUser > 0 && p.users[userId] >= p.maxPerUser
+}
+
+func (p *Pool) takeLocked(userId string) {
+	p.running++
+	p.users[userId]++
+}
+
+// dispatchLocked hands free slots to waiters in FIFO order, skipping waiters whose user is at their limit so
+// that they don't hold up everyone else
+func (p *Pool) dispatchLocked() {
+	for elem := p.waiters.Front(); elem != nil && p.running < p.maxWorkers; {
+		next := elem.Next()
+
+		w := elem.Value.(*waiter)
+		if !p.isUserLimitedLocked(w.userId) {
+			p.takeLocked(w.userId)
+			p.waiters.Remove(elem)
+			close(w.ready)
+		}
+
+		elem = next
+	}
+}
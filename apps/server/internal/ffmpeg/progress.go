@@ -0,0 +1,133 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProgressArgs makes ffmpeg write machine readable progress to stdout and only errors to stderr
+var ProgressArgs = []string{"-hide_banner", "-loglevel", "error", "-nostats", "-progress", "pipe:1"}
+
+// Progress is one block of the key=value output that ffmpeg writes with -progress
+type Progress struct {
+	OutTime time.Duration
+	// Speed relative to realtime, 0 if ffmpeg didn't report it
+	Speed float64
+	// Done is set on the last block, written when ffmpeg finishes
+	Done bool
+}
+
+// ReadProgress reads ffmpeg -progress output from r and calls fn after every complete block.
+// It returns when r is closed.
+func ReadProgress(r io.Reader, fn func(Progress)) error {
+	progress := Progress{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !found {
+			continue
+		}
+
+		switch key {
+		case "out_time_us":
+			us, err := strconv.ParseInt(value, 10, 64)
+			if err == nil && us >= 0 {
+				progress.OutTime = time.Duration(us) * time.Microsecond
+			}
+		case "speed":
+			speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "x"), 64)
+			if err == nil {
+				progress.Speed = speed
+			}
+		case "progress":
+			progress.Done = value == "end"
+			fn(progress)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// PlaylistSegments returns the number of segments listed in an HLS media playlist. ffmpeg only adds a
+// segment to the playlist once it has been completely written.
+func PlaylistSegments(playlistPath string) (int, error) {
+	file, err := os.Open(playlistPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "#EXTINF:") {
+			count++
+		}
+	}
+
+	return count, scanner.Err()
+}
+
+// LogTail is an io.Writer that keeps the last lines written to it, used for capturing ffmpeg's stderr
+type LogTail struct {
+	mu      sync.Mutex
+	lines   []string
+	partial string
+	max     int
+}
+
+func NewLogTail(max int) *LogTail {
+	return &LogTail{max: max}
+}
+
+func (t *LogTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	parts := strings.Split(t.partial+string(p), "\n")
+	t.partial = parts[len(parts)-1]
+
+	for _, line := range parts[:len(parts)-1] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		t.lines = append(t.lines, line)
+		if len(t.lines) > t.max {
+			t.lines = t.lines[1:]
+		}
+	}
+
+	return len(p), nil
+}
+
+// Last returns the last line written, or an empty string
+func (t *LogTail) Last() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.partial != "" {
+		return strings.TrimSpace(t.partial)
+	}
+
+	if len(t.lines) == 0 {
+		return ""
+	}
+
+	return t.lines[len(t.lines)-1]
+}
+
+// String returns the kept lines joined with newlines
+func (t *LogTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return strings.Join(t.lines, "\n")
+}
@@ -0,0 +1,182 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strconv"
+)
+
+var ErrNoAudio = errors.New("input has no audio samples")
+
+const (
+	// waveformSampleRate is the rate the audio is decoded at for the waveform. Peaks between the samples are
+	// lost, which doesn't matter at the resolution of a scrubber.
+	waveformSampleRate = 8000
+	// waveformWindow is the number of samples that are summarized together while decoding, 10ms
+	waveformWindow = waveformSampleRate / 100
+)
+
+// Waveform is the peak and RMS amplitude of a piece of audio in evenly sized buckets, from 0 to 1
+type Waveform struct {
+	// Duration of the decoded audio
+	Duration float64
+	Peaks    []float32
+	RMS      []float32
+}
+
+type waveformWindowSummary struct {
+	peak       float64
+	sumSquares float64
+	samples    int
+}
+
+// ExtractWaveform decodes the whole input mixed down to mono and summarizes it in the given number of buckets
+func ExtractWaveform(ctx context.Context, input string, resolution int) (*Waveform, error) {
+	if resolution < 1 {
+		return nil, fmt.Errorf("invalid waveform resolution %d", resolution)
+	}
+
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-hide_banner", "-nostats", "-loglevel", "error",
+		"-i", input,
+		"-map", "0:a:0",
+		"-ac", "1",
+		"-ar", strconv.Itoa(waveformSampleRate),
+		"-f", "f32le", "-",
+	)
+
+	stderr := NewLogTail(20)
+	cmd.Stderr = stderr
+
+	output, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	windows, readErr := readWaveformWindows(output)
+
+	err = cmd.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg waveform extraction failed: %w: %s", err, stderr.Last())
+	}
+
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	return summarizeWaveform(windows, resolution)
+}
+
+// readWaveformWindows reads f32le samples and summarizes every window of them
+func readWaveformWindows(r io.Reader) ([]waveformWindowSummary, error) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	windows := []waveformWindowSummary{}
+	current := waveformWindowSummary{}
+
+	sample := make([]byte, 4)
+	for {
+		_, err := io.ReadFull(reader, sample)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+
+			return nil, err
+		}
+
+		value := math.Abs(float64(math.Float32frombits(binary.LittleEndian.Uint32(sample))))
+		current.peak = max(current.peak, value)
+		current.sumSquares += value * value
+		current.samples++
+
+		if current.samples == waveformWindow {
+			windows = append(windows, current)
+			current = waveformWindowSummary{}
+		}
+	}
+
+	if current.samples > 0 {
+		windows = append(windows, current)
+	}
+
+	return windows, nil
+}
+
+func summarizeWaveform(windows []waveformWindowSummary, resolution int) (*Waveform, error) {
+	totalSamples := 0
+	for _, window := range windows {
+		totalSamples += window.samples
+	}
+
+	if totalSamples == 0 {
+		return nil, ErrNoAudio
+	}
+
+	waveform := &Waveform{
+		Duration: float64(totalSamples) / waveformSampleRate,
+		Peaks:    make([]float32, resolution),
+		RMS:      make([]float32, resolution),
+	}
+
+	// short inputs have fewer windows than buckets, those buckets repeat the window they fall into
+	for i := range resolution {
+		start := i * len(windows) / resolution
+		end := max((i+1)*len(windows)/resolution, start+1)
+
+		peak := 0.0
+		sumSquares := 0.0
+		samples := 0
+		for _, window := range windows[start:end] {
+			peak = max(peak, window.peak)
+			sumSquares += window.sumSquares
+			samples += window.samples
+		}
+
+		waveform.Peaks[i] = float32(min(peak, 1))
+		waveform.RMS[i] = float32(min(math.Sqrt(sumSquares/float64(samples)), 1))
+	}
+
+	return waveform, nil
+}
+
+// Downsample returns the waveform summarized in fewer buckets. Every bucket takes the highest peak and the
+// combined RMS of the buckets it covers, resolutions at or above the current one return the waveform as is.
+func (w *Waveform) Downsample(resolution int) *Waveform {
+	if resolution < 1 || resolution >= len(w.Peaks) {
+		return w
+	}
+
+	downsampled := &Waveform{
+		Duration: w.Duration,
+		Peaks:    make([]float32, resolution),
+		RMS:      make([]float32, resolution),
+	}
+
+	for i := range resolution {
+		start := i * len(w.Peaks) / resolution
+		end := max((i+1)*len(w.Peaks)/resolution, start+1)
+
+		peak := float32(0)
+		sumSquares := 0.0
+		for j := start; j < end; j++ {
+			peak = max(peak, w.Peaks[j])
+			sumSquares += float64(w.RMS[j]) * float64(w.RMS[j])
+		}
+
+		downsampled.Peaks[i] = peak
+		downsampled.RMS[i] = float32(math.Sqrt(sumSquares / float64(end-start)))
+	}
+
+	return downsampled
+}
@@ -0,0 +1,253 @@
+package loudness
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/altierawr/oto/internal/data"
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/ffmpeg"
+	"github.com/altierawr/oto/internal/tidal"
+)
+
+const (
+	// TargetLoudness is the integrated loudness in LUFS that normalized streams are brought to
+	TargetLoudness = -14.0
+	// MaxTruePeak is the highest true peak in dBTP a normalized stream may reach, the gain is lowered to
+	// keep the peak under it instead of clipping
+	MaxTruePeak = -1.0
+
+	defaultQueueSize = 10_000
+	analysisTimeout  = 10 * time.Minute
+	// failedRetryDelay is how long a track whose analysis failed isn't tried again
+	failedRetryDelay = time.Hour
+)
+
+// transcoderUserId is the user the analysis jobs are counted under in the transcoder pool
+const transcoderUserId = "loudness"
+
+// Service analyzes the loudness of played tracks in the background, one track at a time
+type Service struct {
+	db          *database.DB
+	logger      *slog.Logger
+	transcoders *ffmpeg.Pool
+	tidal       *tidal.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// pendingMu guards pending, which has the tracks that are queued or being analyzed, and failed, which has
+	// the time that the tracks whose analysis failed can be tried again at
+	pendingMu sync.Mutex
+	pending   map[int64]bool
+	failed    map[int64]time.Time
+	queue     chan int64
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func New(db *database.DB, logger *slog.Logger, transcoders *ffmpeg.Pool, tidalClient *tidal.Client) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		db:          db,
+		logger:      logger,
+		transcoders: transcoders,
+		tidal:       tidalClient,
+		ctx:         ctx,
+		cancel:      cancel,
+		pending:     map[int64]bool{},
+		failed:      map[int64]time.Time{},
+		queue:       make(chan int64, defaultQueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Enqueue queues the track for analysis unless it has been analyzed already or is queued. A track whose analysis
+// failed isn't queued until it can be tried again.
+func (s *Service) Enqueue(trackId int64) {
+	analyzed, err := s.db.IsTidalTrackLoudnessAnalyzed(trackId)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		s.logger.Error("couldn't check if track loudness is analyzed",
+			"error", err.Error(),
+			"trackId", trackId)
+		return
+	}
+
+	if analyzed {
+		return
+	}
+
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	if s.pending[trackId] {
+		return
+	}
+
+	if retryAt, found := s.failed[trackId]; found {
+		if time.Now().Before(retryAt) {
+			return
+		}
+
+		delete(s.failed, trackId)
+	}
+
+	select {
+	case s.queue <- trackId:
+		s.pending[trackId] = true
+	default:
+		s.logger.Warn("loudness queue is full, dropping track",
+			"trackId", trackId)
+	}
+}
+
+func (s *Service) Run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case trackId := <-s.queue:
+			failed := s.analyze(trackId)
+
+			// the failure is recorded together with the track leaving pending, so that it isn't queued again
+			// in between
+			s.pendingMu.Lock()
+			delete(s.pending, trackId)
+			if failed {
+				s.recordFailureLocked(trackId)
+			}
+			s.pendingMu.Unlock()
+		}
+	}
+}
+
+func (s *Service) Stop() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+
+	s.cancel()
+
+	<-s.done
+}
+
+// recordFailureLocked keeps the track from being analyzed again until the retry delay has passed, forgetting
+// the failures that have expired. It has to be called with pendingMu held.
+func (s *Service) recordFailureLocked(trackId int64) {
+	now := time.Now()
+	for id, retryAt := range s.failed {
+		if now.After(retryAt) {
+			delete(s.failed, id)
+		}
+	}
+
+	s.failed[trackId] = now.Add(failedRetryDelay)
+}
+
+// analyze analyzes and stores the loudness of the track. It returns whether the analysis failed, failures that
+// can go away on their own, like tidal being unavailable or no transcoder being free, aren't reported so that
+// the track is tried again the next time it's played.
+func (s *Service) analyze(trackId int64) bool {
+	// the analysis shares the transcoders with the streams, so it only runs when one is free
+	slot, err := s.transcoders.Acquire(s.ctx, transcoderUserId)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			s.logger.Warn("no transcoder free for loudness analysis, skipping track",
+				"error", err.Error(),
+				"trackId", trackId)
+		}
+
+		return false
+	}
+	defer slot.Release()
+
+	source, err := s.tidal.GetSongSource(trackId, tidal.QualityLossless, tidal.Region{})
+	if err != nil {
+		s.logger.Error("couldn't get track source for loudness analysis",
+			"error", err.Error(),
+			"trackId", trackId)
+		return !errors.Is(err, tidal.ErrUnavailable)
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, analysisTimeout)
+	defer cancel()
+
+	start := time.Now()
+	loudness, err := ffmpeg.AnalyzeLoudness(ctx, source.Input())
+	if err != nil {
+		// the analysis was stopped by the shutdown
+		if s.ctx.Err() != nil {
+			return false
+		}
+
+		s.logger.Error("couldn't analyze track loudness",
+			"error", err.Error(),
+			"trackId", trackId)
+		return true
+	}
+
+	err = s.db.UpdateTidalTrackLoudness(trackId, loudness.Integrated, loudness.TruePeak)
+	if err != nil {
+		s.logger.Error("couldn't store track loudness",
+			"error", err.Error(),
+			"trackId", trackId)
+		return true
+	}
+
+	s.logger.Info("analyzed track loudness",
+		"trackId", trackId,
+		"integrated", loudness.Integrated,
+		"truePeak", loudness.TruePeak,
+		"took", time.Since(start).String())
+
+	return false
+}
+
+// Gain returns the gain in dB that brings the track to the target loudness in the given normalization
+// mode. Album mode uses the loudness of the whole album so that the volume differences between its tracks
+// are kept, falling back to the track loudness if the album hasn't been analyzed. The gain is 0 if the
+// mode is nil or the track hasn't been analyzed.
+func (s *Service) Gain(trackId int64, mode *string) (float64, error) {
+	if mode == nil {
+		return 0, nil
+	}
+
+	loudness, err := s.db.GetTidalTrackLoudness(trackId)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	integrated, truePeak := loudness.TrackIntegrated, loudness.TrackTruePeak
+	if *mode == data.NormalizationAlbum && loudness.AlbumIntegrated != nil && loudness.AlbumTruePeak != nil {
+		integrated, truePeak = loudness.AlbumIntegrated, loudness.AlbumTruePeak
+	}
+
+	if integrated == nil || truePeak == nil {
+		return 0, nil
+	}
+
+	return gainFor(*integrated, *truePeak), nil
+}
+
+func gainFor(integrated float64, truePeak float64) float64 {
+	gain := TargetLoudness - integrated
+	if truePeak+gain > MaxTruePeak {
+		gain = MaxTruePeak - truePeak
+	}
+
+	// rounded so that the transcode cache isn't split by insignificant differences
+	return math.Round(gain*10) / 10
+}
@@ -0,0 +1,115 @@
+package lyrics
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/altierawr/oto/internal/types"
+)
+
+var ErrNoSyncedLines = errors.New("lyrics have no time tags")
+
+var (
+	// timeTagPattern matches time tags such as [01:23.45], [01:23:45] and [01:23]
+	timeTagPattern = regexp.MustCompile(`^\[(\d+):(\d{1,2})(?:[.:](\d{1,3}))?\]`)
+	// metadataTagPattern matches ID tags such as [ar:Artist] or [offset:+250]
+	metadataTagPattern = regexp.MustCompile(`^\[([a-zA-Z#]+):(.*)\]$`)
+)
+
+// ParseLRC parses lyrics in the LRC format into lines ordered by time. A line with several time tags is
+// repeated at every time, lines without a time tag are left out and the offset tag is applied to all times.
+func ParseLRC(content string) ([]types.LyricsLine, error) {
+	lines := []types.LyricsLine{}
+	offset := 0.0
+
+	for _, rawLine := range strings.Split(content, "\n") {
+		line := strings.TrimSpace(strings.TrimPrefix(rawLine, "\uFEFF"))
+		if line == "" {
+			continue
+		}
+
+		if match := metadataTagPattern.FindStringSubmatch(line); match != nil {
+			if strings.EqualFold(match[1], "offset") {
+				// a positive offset shifts the lyrics earlier
+				milliseconds, err := strconv.Atoi(strings.TrimSpace(match[2]))
+				if err == nil {
+					offset = -float64(milliseconds) / 1000
+				}
+			}
+
+			continue
+		}
+
+		times := []float64{}
+		for {
+			match := timeTagPattern.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+
+			times = append(times, parseTimeTag(match))
+			line = line[len(match[0]):]
+		}
+
+		text := strings.TrimSpace(line)
+		for _, time := range times {
+			lines = append(lines, types.LyricsLine{Time: &time, Text: text})
+		}
+	}
+
+	if len(lines) == 0 {
+		return nil, ErrNoSyncedLines
+	}
+
+	for i := range lines {
+		time := max(*lines[i].Time+offset, 0)
+		lines[i].Time = &time
+	}
+
+	slices.SortStableFunc(lines, func(a, b types.LyricsLine) int {
+		switch {
+		case *a.Time < *b.Time:
+			return -1
+		case *a.Time > *b.Time:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return lines, nil
+}
+
+func parseTimeTag(match []string) float64 {
+	minutes, _ := strconv.Atoi(match[1])
+	seconds, _ := strconv.Atoi(match[2])
+	time := float64(minutes*60 + seconds)
+
+	// the fraction is in hundredths in most files, but some have tenths or milliseconds
+	if match[3] != "" {
+		fraction, _ := strconv.Atoi(match[3])
+		switch len(match[3]) {
+		case 1:
+			time += float64(fraction) / 10
+		case 2:
+			time += float64(fraction) / 100
+		default:
+			time += float64(fraction) / 1000
+		}
+	}
+
+	return time
+}
+
+// PlainLines splits unsynced lyrics into lines without times, keeping empty lines between verses
+func PlainLines(text string) []types.LyricsLine {
+	lines := []types.LyricsLine{}
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		lines = append(lines, types.LyricsLine{Text: strings.TrimSpace(line)})
+	}
+
+	return lines
+}
@@ -0,0 +1,239 @@
+package profiles
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/altierawr/oto/internal/tidal"
+)
+
+var (
+	ErrUnknownProfile = errors.New("unknown transcoding profile")
+)
+
+const (
+	SegmentTypeFmp4   = "fmp4"
+	SegmentTypeMpegts = "mpegts"
+)
+
+type Profile struct {
+	Name        string `json:"name"`
+	Codec       string `json:"codec"`
+	Encoder     string `json:"-"`
+	Bitrate     int    `json:"bitrate,omitempty"` // kbps, 0 for lossless
+	SegmentType string `json:"segmentType"`
+	ContentType string `json:"contentType"`
+	// Tidal quality tier the profile is transcoded from, lower tiers are used if it isn't available
+	SourceQuality string `json:"sourceQuality"`
+	// ffmpeg muxer, extension and content type of the single file served by /v1/tracks/:id/audio
+	FileFormat      string `json:"-"`
+	FileExtension   string `json:"-"`
+	FileContentType string `json:"fileContentType"`
+}
+
+var DefaultName = "flac-lossless"
+
+// AdaptiveRenditions are the profiles listed in the master playlist of adaptive streams, highest bitrate first.
+// All of them have to share the same segment type.
+var AdaptiveRenditions = []string{"aac-256", "aac-128", "aac-64"}
+
+var all = []Profile{
+	{
+		Name:            "flac-hires",
+		Codec:           "flac",
+		SourceQuality:   tidal.QualityHiResLossless,
+		Encoder:         "flac",
+		SegmentType:     SegmentTypeFmp4,
+		ContentType:     "audio/mp4",
+		FileFormat:      "flac",
+		FileExtension:   ".flac",
+		FileContentType: "audio/flac",
+	},
+	{
+		Name:            "flac-lossless",
+		Codec:           "flac",
+		SourceQuality:   tidal.QualityLossless,
+		Encoder:         "flac",
+		SegmentType:     SegmentTypeFmp4,
+		ContentType:     "audio/mp4",
+		FileFormat:      "flac",
+		FileExtension:   ".flac",
+		FileContentType: "audio/flac",
+	},
+	{
+		Name:            "aac-256",
+		Codec:           "aac",
+		SourceQuality:   tidal.QualityLossless,
+		Encoder:         "aac",
+		Bitrate:         256,
+		SegmentType:     SegmentTypeFmp4,
+		ContentType:     "audio/mp4",
+		FileFormat:      "adts",
+		FileExtension:   ".aac",
+		FileContentType: "audio/aac",
+	},
+	{
+		Name:            "aac-128",
+		Codec:           "aac",
+		SourceQuality:   tidal.QualityLossless,
+		Encoder:         "aac",
+		Bitrate:         128,
+		SegmentType:     SegmentTypeFmp4,
+		ContentType:     "audio/mp4",
+		FileFormat:      "adts",
+		FileExtension:   ".aac",
+		FileContentType: "audio/aac",
+	},
+	{
+		Name:            "aac-64",
+		Codec:           "aac",
+		SourceQuality:   tidal.QualityLossless,
+		Encoder:         "aac",
+		Bitrate:         64,
+		SegmentType:     SegmentTypeFmp4,
+		ContentType:     "audio/mp4",
+		FileFormat:      "adts",
+		FileExtension:   ".aac",
+		FileContentType: "audio/aac",
+	},
+	{
+		Name:            "opus-128",
+		Codec:           "opus",
+		SourceQuality:   tidal.QualityLossless,
+		Encoder:         "libopus",
+		Bitrate:         128,
+		SegmentType:     SegmentTypeFmp4,
+		ContentType:     "audio/mp4",
+		FileFormat:      "ogg",
+		FileExtension:   ".opus",
+		FileContentType: "audio/ogg",
+	},
+	{
+		Name:            "mp3-320",
+		Codec:           "mp3",
+		SourceQuality:   tidal.QualityLossless,
+		Encoder:         "libmp3lame",
+		Bitrate:         320,
+		SegmentType:     SegmentTypeMpegts,
+		ContentType:     "video/mp2t",
+		FileFormat:      "mp3",
+		FileExtension:   ".mp3",
+		FileContentType: "audio/mpeg",
+	},
+}
+
+func All() []Profile {
+	return all
+}
+
+func Get(name string) (*Profile, error) {
+	for i := range all {
+		if all[i].Name == name {
+			return &all[i], nil
+		}
+	}
+
+	return nil, ErrUnknownProfile
+}
+
+func Default() *Profile {
+	profile, err := Get(DefaultName)
+	if err != nil {
+		panic(err)
+	}
+
+	return profile
+}
+
+// Adaptive returns the profiles of AdaptiveRenditions
+func Adaptive() []*Profile {
+	result := []*Profile{}
+	for _, name := range AdaptiveRenditions {
+		profile, err := Get(name)
+		if err != nil {
+			panic(err)
+		}
+
+		result = append(result, profile)
+	}
+
+	return result
+}
+
+// Args returns the ffmpeg encoder args for an output with a single audio stream
+func (p *Profile) Args() []string {
+	args := []string{"-c:a", p.Encoder}
+	if p.Bitrate > 0 {
+		args = append(args, "-b:a", fmt.Sprintf("%dk", p.Bitrate))
+	}
+
+	return args
+}
+
+// StreamArgs returns the ffmpeg encoder args for the audio stream with the given index of a multi-stream output
+func (p *Profile) StreamArgs(index int) []string {
+	args := []string{fmt.Sprintf("-c:a:%d", index), p.Encoder}
+	if p.Bitrate > 0 {
+		args = append(args, fmt.Sprintf("-b:a:%d", index), fmt.Sprintf("%dk", p.Bitrate))
+	}
+
+	return args
+}
+
+// FileName returns the name of the single file transcode of the profile
+func (p *Profile) FileName() string {
+	return "audio" + p.FileExtension
+}
+
+func (p *Profile) SegmentExtension() string {
+	if p.SegmentType == SegmentTypeMpegts {
+		return ".ts"
+	}
+
+	return ".mp4"
+}
+
+// HasInitSegment reports whether ffmpeg writes a separate init.mp4 for this profile
+func (p *Profile) HasInitSegment() bool {
+	return p.SegmentType == SegmentTypeFmp4
+}
+
+// InitSegmentName returns the name of the init segment of a stream range that starts at the given segment.
+// Ranges after the first one get their own name, so that init segments of different ranges can't be mixed up.
+func (p *Profile) InitSegmentName(start int) string {
+	if start == 0 {
+		return "init.mp4"
+	}
+
+	return fmt.Sprintf("init-%d.mp4", start)
+}
+
+func (p *Profile) SegmentName(nr int) string {
+	return fmt.Sprintf("segment%d%s", nr, p.SegmentExtension())
+}
+
+func (p *Profile) SegmentPattern() string {
+	return "segment%d" + p.SegmentExtension()
+}
+
+// ParseSegmentName returns the number of a segment file name created with SegmentPattern
+func (p *Profile) ParseSegmentName(name string) (int, bool) {
+	nrStr, found := strings.CutPrefix(name, "segment")
+	if !found {
+		return 0, false
+	}
+
+	nrStr, found = strings.CutSuffix(nrStr, p.SegmentExtension())
+	if !found {
+		return 0, false
+	}
+
+	nr, err := strconv.Atoi(nrStr)
+	if err != nil || nr < 0 {
+		return 0, false
+	}
+
+	return nr, true
+}
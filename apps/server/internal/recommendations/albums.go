@@ -9,6 +9,7 @@ import (
 
 	"github.com/altierawr/oto/internal/data"
 	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/tidal"
 	"github.com/altierawr/oto/internal/types"
 	"github.com/hbollon/go-edlib"
 )
@@ -226,7 +227,7 @@ func (s *Service) updateSingleUserRecommendedAlbums(ctx context.Context, user da
 			s.logger.Info("tidal album wasn't found in database, need to fetch",
 				"artist", meta.ArtistName,
 				"album", meta.AlbumTitle)
-			results, err := s.tidal.Search(fmt.Sprintf("%s - %s", meta.ArtistName, meta.AlbumTitle))
+			results, err := s.tidal.Search(fmt.Sprintf("%s - %s", meta.ArtistName, meta.AlbumTitle), tidal.Region{})
 			if err != nil {
 				if !errors.Is(err, database.ErrRecordNotFound) {
 					s.logger.Warn("couldn't search tidal for lastfm album recommendation",
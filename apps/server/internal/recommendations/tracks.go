@@ -6,6 +6,7 @@ import (
 	"slices"
 
 	"github.com/altierawr/oto/internal/data"
+	"github.com/altierawr/oto/internal/tidal"
 	"github.com/altierawr/oto/internal/types"
 	"github.com/hbollon/go-edlib"
 )
@@ -73,7 +74,7 @@ func (s *Service) updateSingleUserRecommendedTracks(ctx context.Context, user da
 					continue
 				}
 
-				results, err := s.tidal.Search(fmt.Sprintf("%s - %s", artistName, title))
+				results, err := s.tidal.Search(fmt.Sprintf("%s - %s", artistName, title), tidal.Region{})
 				if err != nil {
 					return err
 				}
@@ -0,0 +1,424 @@
+package sessions
+
+import (
+	"errors"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/altierawr/oto/internal/transcoder"
+	"github.com/google/uuid"
+)
+
+type StreamEventType int
+
+const (
+	// StreamStarted is emitted once a stream has been added to its session
+	StreamStarted StreamEventType = iota
+	// StreamEnded is emitted once a stream has been ended and its files deleted
+	StreamEnded
+)
+
+type StreamEvent struct {
+	Type      StreamEventType
+	UserId    uuid.UUID
+	SessionId uuid.UUID
+	StreamId  string
+	TrackId   int64
+}
+
+// StreamManager owns the streams of every session and the prefetched stream of each session. Streams can only
+// be looked up by the user that created them.
+type StreamManager struct {
+	logger     *slog.Logger
+	mu         sync.RWMutex
+	streams    map[uuid.UUID]map[string]*Stream
+	prefetches map[uuid.UUID]*Prefetch
+
+	listenersMu sync.RWMutex
+	listeners   []func(StreamEvent)
+}
+
+func newStreamManager(logger *slog.Logger) *StreamManager {
+	return &StreamManager{
+		logger:     logger,
+		streams:    make(map[uuid.UUID]map[string]*Stream),
+		prefetches: make(map[uuid.UUID]*Prefetch),
+	}
+}
+
+// Subscribe calls the listener for every stream that starts or ends. Listeners are called synchronously
+// without any lock held, so they must not block.
+func (m *StreamManager) Subscribe(listener func(StreamEvent)) {
+	m.listenersMu.Lock()
+	m.listeners = append(m.listeners, listener)
+	m.listenersMu.Unlock()
+}
+
+func (m *StreamManager) emit(event StreamEvent) {
+	m.listenersMu.RLock()
+	listeners := slices.Clone(m.listeners)
+	m.listenersMu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// Add adds the stream to the session under its Id, UserId has to be set to the owner of the session
+func (m *StreamManager) Add(sessionId uuid.UUID, stream *Stream) {
+	stream.LastAccessedAt = time.Now()
+
+	m.mu.Lock()
+	if _, exists := m.streams[sessionId]; !exists {
+		m.streams[sessionId] = make(map[string]*Stream)
+	}
+	m.streams[sessionId][stream.Id] = stream
+	m.mu.Unlock()
+
+	m.emit(StreamEvent{
+		Type:      StreamStarted,
+		UserId:    stream.UserId,
+		SessionId: sessionId,
+		StreamId:  stream.Id,
+		TrackId:   stream.TrackId,
+	})
+}
+
+// Get returns a snapshot of the stream. Streams of other users are reported as not found.
+func (m *StreamManager) Get(userId uuid.UUID, sessionId uuid.UUID, streamId string) (Stream, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stream, exists := m.streams[sessionId][streamId]
+	if !exists || stream.UserId != userId {
+		return Stream{}, ErrStreamNotFound
+	}
+
+	return stream.Snapshot(), nil
+}
+
+// Touch marks the stream as accessed so that it isn't reaped for being idle and returns a snapshot of it, like Get
+func (m *StreamManager) Touch(userId uuid.UUID, sessionId uuid.UUID, streamId string) (Stream, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, exists := m.streams[sessionId][streamId]
+	if !exists || stream.UserId != userId {
+		return Stream{}, ErrStreamNotFound
+	}
+
+	stream.LastAccessedAt = time.Now()
+
+	return stream.Snapshot(), nil
+}
+
+// Update calls fn with the stream while holding the lock of the manager, so fn must not call any method of
+// the manager
+func (m *StreamManager) Update(sessionId uuid.UUID, streamId string, fn func(stream *Stream)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stream, exists := m.streams[sessionId][streamId]
+	if !exists {
+		return ErrStreamNotFound
+	}
+
+	fn(stream)
+
+	return nil
+}
+
+// UpdateSession calls fn with every stream of the session like Update
+func (m *StreamManager) UpdateSession(sessionId uuid.UUID, fn func(stream *Stream)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, stream := range m.streams[sessionId] {
+		fn(stream)
+	}
+}
+
+// List returns snapshots of the streams of the session owned by the user, oldest first
+func (m *StreamManager) List(userId uuid.UUID, sessionId uuid.UUID) []Stream {
+	m.mu.RLock()
+	streams := []Stream{}
+	for _, stream := range m.streams[sessionId] {
+		if stream.UserId == userId {
+			streams = append(streams, stream.Snapshot())
+		}
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(streams, func(a, b Stream) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return streams
+}
+
+// End cancels the transcodes of the stream and deletes its files
+func (m *StreamManager) End(sessionId uuid.UUID, streamId string) error {
+	m.mu.Lock()
+	stream, exists := m.streams[sessionId][streamId]
+	if streamId == "" || !exists {
+		m.mu.Unlock()
+		return ErrStreamNotFound
+	}
+
+	// removing the stream first makes sure that it is ended only once
+	delete(m.streams[sessionId], streamId)
+	if len(m.streams[sessionId]) == 0 {
+		delete(m.streams, sessionId)
+	}
+	if prefetch, found := m.prefetches[sessionId]; found && prefetch.StreamId == streamId {
+		delete(m.prefetches, sessionId)
+	}
+
+	processes := []transcoder.Process{}
+	for _, streamRange := range stream.Ranges {
+		if streamRange.Transcode != nil {
+			processes = append(processes, streamRange.Transcode)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, process := range processes {
+		err := process.Cancel()
+		if err != nil {
+			m.logger.Error(err.Error(),
+				"sessionId", sessionId,
+				"streamId", streamId)
+		}
+	}
+
+	streamPath := GetStreamPath(&sessionId, streamId)
+
+	err := os.RemoveAll(streamPath)
+	if err != nil {
+		m.logger.Error(err.Error(),
+			"sessionId", sessionId,
+			"streamId", streamId)
+	}
+
+	m.logger.Info("ended stream",
+		"sessionId", sessionId,
+		"streamId", streamId)
+
+	m.emit(StreamEvent{
+		Type:      StreamEnded,
+		UserId:    stream.UserId,
+		SessionId: sessionId,
+		StreamId:  streamId,
+		TrackId:   stream.TrackId,
+	})
+
+	return nil
+}
+
+type streamRef struct {
+	sessionId uuid.UUID
+	streamId  string
+}
+
+// endWhere ends every stream for which keep returns false and returns how many were ended
+func (m *StreamManager) endWhere(keep func(sessionId uuid.UUID, stream *Stream) bool) int {
+	refs := []streamRef{}
+
+	m.mu.RLock()
+	for sessionId, sessionStreams := range m.streams {
+		for streamId, stream := range sessionStreams {
+			if !keep(sessionId, stream) {
+				refs = append(refs, streamRef{sessionId: sessionId, streamId: streamId})
+			}
+		}
+	}
+	m.mu.RUnlock()
+
+	ended := 0
+	for _, ref := range refs {
+		err := m.End(ref.sessionId, ref.streamId)
+		if err != nil {
+			if !errors.Is(err, ErrStreamNotFound) {
+				m.logger.Error("couldn't end stream",
+					"error", err.Error(),
+					"sessionId", ref.sessionId,
+					"streamId", ref.streamId)
+			}
+
+			continue
+		}
+
+		ended++
+	}
+
+	return ended
+}
+
+// EndSession ends every stream of the session and forgets its prefetched stream
+func (m *StreamManager) EndSession(sessionId uuid.UUID) int {
+	ended := m.endWhere(func(streamSessionId uuid.UUID, stream *Stream) bool {
+		return streamSessionId != sessionId
+	})
+
+	m.mu.Lock()
+	delete(m.prefetches, sessionId)
+	m.mu.Unlock()
+
+	return ended
+}
+
+// EndIdle ends the streams that haven't been accessed for the timeout
+func (m *StreamManager) EndIdle(timeout time.Duration) int {
+	now := time.Now()
+	return m.endWhere(func(sessionId uuid.UUID, stream *Stream) bool {
+		return now.Sub(stream.LastAccessedAt) < timeout
+	})
+}
+
+// StopAll ends every stream, cancelling the transcodes that are still running
+func (m *StreamManager) StopAll() int {
+	return m.endWhere(func(sessionId uuid.UUID, stream *Stream) bool {
+		return false
+	})
+}
+
+// Prefetch returns the prefetch of the session, if there is one. Its StreamId is empty while the stream is
+// still being started.
+func (m *StreamManager) Prefetch(sessionId uuid.UUID) (Prefetch, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prefetch, exists := m.prefetches[sessionId]
+	if !exists {
+		return Prefetch{}, false
+	}
+
+	return *prefetch, true
+}
+
+// SetPrefetch registers the prefetch of the session before its stream exists, so that a queue change in the
+// meantime can cancel it. The prefetch it replaces is returned so that its stream can be ended.
+func (m *StreamManager) SetPrefetch(sessionId uuid.UUID, prefetch *Prefetch) (Prefetch, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	replaced, exists := m.prefetches[sessionId]
+	m.prefetches[sessionId] = prefetch
+	if !exists {
+		return Prefetch{}, false
+	}
+
+	return *replaced, true
+}
+
+// CompletePrefetch sets the stream of the prefetch once it has started. It returns false if the prefetch has
+// been cancelled or replaced in the meantime, in which case the stream has to be ended by the caller.
+func (m *StreamManager) CompletePrefetch(sessionId uuid.UUID, prefetch *Prefetch, streamId string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.prefetches[sessionId] != prefetch {
+		return false
+	}
+
+	prefetch.StreamId = streamId
+
+	return true
+}
+
+// RemovePrefetch removes the prefetch of the session if it's still the registered one
+func (m *StreamManager) RemovePrefetch(sessionId uuid.UUID, prefetch *Prefetch) {
+	m.mu.Lock()
+	if m.prefetches[sessionId] == prefetch {
+		delete(m.prefetches, sessionId)
+	}
+	m.mu.Unlock()
+}
+
+// TakePrefetch removes the prefetch of the session and returns it
+func (m *StreamManager) TakePrefetch(sessionId uuid.UUID) (Prefetch, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefetch, exists := m.prefetches[sessionId]
+	if !exists {
+		return Prefetch{}, false
+	}
+	delete(m.prefetches, sessionId)
+
+	return *prefetch, true
+}
+
+// ClaimPrefetch hands over the prefetched stream of the session if it was prepared for the track and profile
+// at the queue index. A negative queue index matches the prefetch of the track at any index.
+func (m *StreamManager) ClaimPrefetch(sessionId uuid.UUID, trackId int64, queueIndex int, profileKey string) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefetch, exists := m.prefetches[sessionId]
+	if !exists || prefetch.TrackId != trackId || prefetch.ProfileKey != profileKey {
+		return "", false
+	}
+
+	if queueIndex >= 0 && prefetch.QueueIndex != queueIndex {
+		return "", false
+	}
+
+	delete(m.prefetches, sessionId)
+
+	stream, streamExists := m.streams[sessionId][prefetch.StreamId]
+	if !streamExists {
+		return "", false
+	}
+	stream.LastAccessedAt = time.Now()
+
+	return prefetch.StreamId, true
+}
+
+// EndUnclaimedPrefetches ends the prefetched streams that haven't been claimed within the timeout of being
+// started and returns how many were ended
+func (m *StreamManager) EndUnclaimedPrefetches(timeout time.Duration) int {
+	now := time.Now()
+	refs := []streamRef{}
+
+	m.mu.Lock()
+	for sessionId, prefetch := range m.prefetches {
+		// the stream is still being started
+		if prefetch.StreamId == "" {
+			continue
+		}
+
+		stream, exists := m.streams[sessionId][prefetch.StreamId]
+		if exists && now.Sub(stream.CreatedAt) < timeout {
+			continue
+		}
+
+		delete(m.prefetches, sessionId)
+		if exists {
+			refs = append(refs, streamRef{sessionId: sessionId, streamId: prefetch.StreamId})
+		}
+	}
+	m.mu.Unlock()
+
+	ended := 0
+	for _, ref := range refs {
+		err := m.End(ref.sessionId, ref.streamId)
+		if err != nil {
+			if !errors.Is(err, ErrStreamNotFound) {
+				m.logger.Error("couldn't end prefetched stream",
+					"error", err.Error(),
+					"sessionId", ref.sessionId,
+					"streamId", ref.streamId)
+			}
+
+			continue
+		}
+
+		ended++
+	}
+
+	return ended
+}
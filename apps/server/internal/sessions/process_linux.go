@@ -0,0 +1,32 @@
+//go:build linux
+
+package sessions
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// processStartTime returns the time the process started at, in clock ticks since boot
+func processStartTime(pid int) (string, bool) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return "", false
+	}
+
+	// the command name in parentheses can contain spaces, so the fields are counted from the end of it
+	end := bytes.LastIndexByte(stat, ')')
+	if end == -1 {
+		return "", false
+	}
+
+	// the start time is the 22nd field, the ones after the command name start at the 3rd
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) < 20 {
+		return "", false
+	}
+
+	return fields[19], true
+}
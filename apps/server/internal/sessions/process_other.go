@@ -0,0 +1,8 @@
+//go:build !linux
+
+package sessions
+
+// processStartTime isn't known on other systems, where only the pid of the owner of a directory is checked
+func processStartTime(pid int) (string, bool) {
+	return "", false
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package sessions
+
+import (
+	"os"
+)
+
+func processAlive(pid int) bool {
+	// FindProcess opens a handle to the process on windows, which fails if it doesn't exist
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	process.Release()
+	return true
+}
@@ -4,28 +4,87 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"sync"
 	"time"
 
 	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/profiles"
+	"github.com/altierawr/oto/internal/transcoder"
 	"github.com/google/uuid"
 )
 
 type Stream struct {
-	IsLoading  bool
+	Id string
+	// User that created the stream, only they can access it
+	UserId    uuid.UUID
+	CreatedAt time.Time
+	// Whether any range of the stream is still being transcoded
+	IsLoading bool
+	// Total number of segments, -1 until the transcode of the last range has finished
 	NrSegments int
-	Ffmpeg     *exec.Cmd
+	// Number of segments that have been completely written from the start of the stream without a gap
+	SegmentsWritten int
+	// Media time encoded so far by the range that reported progress last, counted from its start
+	EncodedTime time.Duration
+	// Transcoding speed relative to realtime
+	Speed float64
+	// Why ffmpeg failed, empty if it didn't
+	Error string
+	// Parts of the stream that are transcoded by separate jobs, ordered by their first segment. Together they
+	// cover the whole stream, a new range is split off when the client seeks ahead of the transcode.
+	Ranges     []*StreamRange
 	TrackId    int64
 	SeekOffset float64
+	// Length of the track in seconds, 0 if it isn't known
+	Duration float64
+	Profile  *profiles.Profile
+	// Renditions of an adaptive stream, nil for single rendition streams
+	Variants []*profiles.Profile
+	// Normalization gain in dB applied by ffmpeg, 0 if the stream isn't normalized
+	Gain float64
+	// Tidal quality tier the stream is transcoded from
+	SourceQuality string
+	// Position of the track in the session queue, -1 if the client didn't say which entry the stream plays
+	QueueIndex int
+	// Whether the next track in the session queue has already been prefetched for this stream
+	PrefetchedNext bool
+	// Last time the client requested a file of the stream, idle streams are reaped
+	LastAccessedAt time.Time
 }
 
-var SessionStreams map[string]map[string]*Stream = make(map[string]map[string]*Stream)
-var SessionStreamsMu sync.RWMutex
+// StreamRange is a part of a stream that is transcoded by one job. Segments are numbered from the start of
+// the stream in every range, so that they join into one continuous stream.
+type StreamRange struct {
+	// First segment of the range
+	Start int
+	// Time the first segment of the range starts at, in seconds from the seek offset of the stream
+	StartTime float64
+	// Segment at which the next range starts, -1 for the last range
+	End int
+	// Directory the range is written to, relative to the stream directory
+	Dir string
+	// Number of segments of the range that have been completely written so far, counted from Start
+	SegmentsWritten int
+	IsLoading       bool
+	// Whether the transcode was cancelled on purpose, because it reached the next range
+	Stopped bool
+	// The transcode of the range, nil for streams served from the transcode cache
+	Transcode transcoder.Process
+	// Closed once the transcode has exited and released its transcoder
+	Done chan struct{}
+}
+
+// Prefetch is a stream that was started ahead of time for the next track in the session queue
+type Prefetch struct {
+	TrackId int64
+	// Position of the track in the session queue
+	QueueIndex int
+	StreamId   string
+	ProfileKey string
+}
 
 func GetSessionPath(sessionId *uuid.UUID) string {
-	return filepath.Join(os.TempDir(), "oto", fmt.Sprintf("session-%s", sessionId.String()))
+	return filepath.Join(getRootPath(), fmt.Sprintf("session-%s", sessionId.String()))
 }
 
 func GetStreamPath(sessionId *uuid.UUID, streamId string) string {
@@ -35,35 +94,52 @@ func GetStreamPath(sessionId *uuid.UUID, streamId string) string {
 type Service struct {
 	db     *database.DB
 	logger *slog.Logger
-	stop   chan bool
-	done   chan bool
+	// Streams that haven't been accessed for this long are ended, 0 disables reaping
+	idleTimeout time.Duration
+	streams     *StreamManager
+	stop        chan bool
+	done        chan bool
 }
 
-func New(db *database.DB, logger *slog.Logger) *Service {
+func New(db *database.DB, logger *slog.Logger, idleTimeout time.Duration) *Service {
 	return &Service{
-		db:     db,
-		logger: logger,
-		stop:   make(chan bool),
-		done:   make(chan bool),
+		db:          db,
+		logger:      logger,
+		idleTimeout: idleTimeout,
+		streams:     newStreamManager(logger),
+		stop:        make(chan bool),
+		done:        make(chan bool),
 	}
 }
 
+// Streams returns the manager of the streams of every session
+func (s *Service) Streams() *StreamManager {
+	return s.streams
+}
+
 func (s *Service) RunBackground() {
 	defer close(s.done)
 
 	ticker := time.NewTicker(30 * time.Minute)
 	defer ticker.Stop()
 
+	reapTicker := time.NewTicker(time.Minute)
+	defer reapTicker.Stop()
+
 	for {
 		select {
 		case <-s.stop:
 			return
 		case <-ticker.C:
 			s.cleanupExpiredSessions()
+		case <-reapTicker.C:
+			s.reapIdleStreams()
+			s.reapUnclaimedPrefetches()
 		}
 	}
 }
 
+// Stop stops the background loop and ends every stream, cancelling the transcodes that are still running
 func (s *Service) Stop() {
 	select {
 	case <-s.stop:
@@ -71,6 +147,12 @@ func (s *Service) Stop() {
 		close(s.stop)
 	}
 	<-s.done
+
+	count := s.streams.StopAll()
+	if count > 0 {
+		s.logger.Info("stopped all streams",
+			"count", count)
+	}
 }
 
 func (s *Service) cleanupExpiredSessions() {
@@ -82,6 +164,8 @@ func (s *Service) cleanupExpiredSessions() {
 
 	if sessions != nil {
 		for _, session := range *sessions {
+			s.streams.EndSession(session.ID)
+
 			sessionPath := GetSessionPath(&session.ID)
 			s.logger.Info("deleting expired session",
 				"id", session.ID,
@@ -94,10 +178,6 @@ func (s *Service) cleanupExpiredSessions() {
 					"id", session.ID,
 					"path", sessionPath)
 			}
-
-			SessionStreamsMu.Lock()
-			delete(SessionStreams, session.ID.String())
-			SessionStreamsMu.Unlock()
 		}
 	}
 }
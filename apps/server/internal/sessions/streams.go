@@ -0,0 +1,274 @@
+package sessions
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrStreamNotFound = errors.New("stream not found")
+
+// ownerFileName is written to every session directory with the pid of the server that uses it and the time the
+// server started, so that directories left behind by a crashed server can be told apart from ones of a running
+// server. The start time tells whether the pid has been reused by another process since.
+const ownerFileName = "owner.pid"
+
+func getRootPath() string {
+	return filepath.Join(os.TempDir(), "oto")
+}
+
+// CreateSessionDir creates the directory for the streams of the session, marking it as owned by this process
+func CreateSessionDir(sessionId *uuid.UUID) (string, error) {
+	sessionDir := GetSessionPath(sessionId)
+
+	err := os.MkdirAll(sessionDir, os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+
+	err = writeOwnerFile(sessionDir)
+	if err != nil {
+		return "", err
+	}
+
+	return sessionDir, nil
+}
+
+// CreateAudioDir creates a directory for a single file transcode of /v1/tracks/:id/audio, owned by this
+// process like the session directories
+func CreateAudioDir() (string, error) {
+	rootPath := getRootPath()
+
+	err := os.MkdirAll(rootPath, os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.MkdirTemp(rootPath, "audio-*")
+	if err != nil {
+		return "", err
+	}
+
+	err = writeOwnerFile(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return dir, nil
+}
+
+// Snapshot returns a copy of the stream and its ranges that can be read after the lock of the
+// StreamManager is released. It has to be called with the lock held.
+func (s *Stream) Snapshot() Stream {
+	snapshot := *s
+	snapshot.Ranges = make([]*StreamRange, len(s.Ranges))
+	for i, streamRange := range s.Ranges {
+		rangeCopy := *streamRange
+		snapshot.Ranges[i] = &rangeCopy
+	}
+
+	return snapshot
+}
+
+// Range returns the range that the segment belongs to, nil if there is none
+func (s *Stream) Range(segmentNr int) *StreamRange {
+	for _, streamRange := range s.Ranges {
+		if segmentNr >= streamRange.Start && (streamRange.End == -1 || segmentNr < streamRange.End) {
+			return streamRange
+		}
+	}
+
+	return nil
+}
+
+// HasSegment reports whether the segment has been completely written
+func (s *Stream) HasSegment(segmentNr int) bool {
+	streamRange := s.Range(segmentNr)
+	return streamRange != nil && segmentNr < streamRange.Start+streamRange.SegmentsWritten
+}
+
+// SplitRange adds the range to the stream, taking over the rest of the range that its first segment belongs
+// to. A range without any written segments that starts at the same segment is replaced and returned so that
+// its files can be deleted.
+func (s *Stream) SplitRange(newRange *StreamRange) *StreamRange {
+	for i, streamRange := range s.Ranges {
+		if newRange.Start < streamRange.Start || (streamRange.End != -1 && newRange.Start >= streamRange.End) {
+			continue
+		}
+
+		newRange.End = streamRange.End
+
+		if newRange.Start == streamRange.Start {
+			s.Ranges[i] = newRange
+			s.Refresh()
+			return streamRange
+		}
+
+		streamRange.End = newRange.Start
+		s.Ranges = slices.Insert(s.Ranges, i+1, newRange)
+		s.Refresh()
+		return nil
+	}
+
+	return nil
+}
+
+// Refresh recomputes the state of the stream from its ranges
+func (s *Stream) Refresh() {
+	s.IsLoading = false
+	for _, streamRange := range s.Ranges {
+		if streamRange.IsLoading {
+			s.IsLoading = true
+		}
+	}
+
+	next := 0
+	for _, streamRange := range s.Ranges {
+		if streamRange.Start != next {
+			break
+		}
+
+		next = streamRange.Start + streamRange.SegmentsWritten
+		if streamRange.End != -1 && next > streamRange.End {
+			next = streamRange.End
+		}
+
+		if streamRange.End == -1 || next < streamRange.End {
+			break
+		}
+	}
+
+	s.SegmentsWritten = next
+}
+
+// ReachedEnd reports whether the range has written every segment up to the next range
+func (r *StreamRange) ReachedEnd() bool {
+	return r.End != -1 && r.Start+r.SegmentsWritten >= r.End
+}
+
+// prefetchTimeout is how long a prefetched stream waits to be claimed. Tracks are prefetched close to the end
+// of the playing one, so a prefetch that hasn't been claimed by then was skipped and only holds on to its
+// transcode.
+const prefetchTimeout = 2 * time.Minute
+
+func (s *Service) reapUnclaimedPrefetches() {
+	count := s.streams.EndUnclaimedPrefetches(prefetchTimeout)
+
+	if count > 0 {
+		s.logger.Info("reaped unclaimed prefetched streams",
+			"count", count,
+			"prefetchTimeout", prefetchTimeout.String())
+	}
+}
+
+func (s *Service) reapIdleStreams() {
+	if s.idleTimeout <= 0 {
+		return
+	}
+
+	count := s.streams.EndIdle(s.idleTimeout)
+
+	if count > 0 {
+		s.logger.Info("reaped idle streams",
+			"count", count,
+			"idleTimeout", s.idleTimeout.String())
+	}
+}
+
+// CleanupOrphans deletes the session and audio directories that aren't owned by a running server process. It has to be
+// called before any streams are created.
+func (s *Service) CleanupOrphans() {
+	rootPath := getRootPath()
+
+	entries, err := os.ReadDir(rootPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			s.logger.Error("couldn't read stream directory",
+				"error", err.Error(),
+				"path", rootPath)
+		}
+
+		return
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		isOwnedDir := strings.HasPrefix(entry.Name(), "session-") || strings.HasPrefix(entry.Name(), "audio-")
+		if !entry.IsDir() || !isOwnedDir {
+			continue
+		}
+
+		sessionPath := filepath.Join(rootPath, entry.Name())
+		if isOwnedByLiveProcess(sessionPath) {
+			continue
+		}
+
+		err = os.RemoveAll(sessionPath)
+		if err != nil {
+			s.logger.Error("couldn't delete orphaned session directory",
+				"error", err.Error(),
+				"path", sessionPath)
+			continue
+		}
+
+		removed++
+	}
+
+	if removed > 0 {
+		s.logger.Info("deleted orphaned session directories",
+			"count", removed)
+	}
+}
+
+// writeOwnerFile marks the directory as owned by this process
+func writeOwnerFile(dir string) error {
+	owner := strconv.Itoa(os.Getpid())
+	if startTime, ok := processStartTime(os.Getpid()); ok {
+		owner += " " + startTime
+	}
+
+	return os.WriteFile(filepath.Join(dir, ownerFileName), []byte(owner), 0o644)
+}
+
+func isOwnedByLiveProcess(sessionPath string) bool {
+	contents, err := os.ReadFile(filepath.Join(sessionPath, ownerFileName))
+	if err != nil {
+		return false
+	}
+
+	fields := strings.Fields(string(contents))
+	if len(fields) == 0 {
+		return false
+	}
+
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || pid <= 0 {
+		return false
+	}
+
+	// a directory from an earlier run can carry our pid if it was reused
+	if pid == os.Getpid() {
+		return false
+	}
+
+	if !processAlive(pid) {
+		return false
+	}
+
+	// the pid belongs to another process than the owner if that one started at a different time
+	if len(fields) > 1 {
+		startTime, ok := processStartTime(pid)
+		if ok && startTime != fields[1] {
+			return false
+		}
+	}
+
+	return true
+}
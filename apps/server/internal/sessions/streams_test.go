@@ -0,0 +1,43 @@
+package sessions
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestIsOwnedByLiveProcess(t *testing.T) {
+	// the test binary's parent, usually go test, is alive for as long as the test runs
+	pid := os.Getppid()
+	startTime, knowsStartTime := processStartTime(pid)
+
+	tests := []struct {
+		name  string
+		owner string
+		owned bool
+	}{
+		{"live process", strconv.Itoa(pid), true},
+		{"live process with its start time", strconv.Itoa(pid) + " " + startTime, true},
+		{"reused pid of an earlier run", strconv.Itoa(os.Getpid()), false},
+		{"invalid pid", "abc", false},
+		{"empty", "", false},
+		// only the pid can be checked on systems where the start time isn't known
+		{"pid reused by another process", strconv.Itoa(pid) + " 1", !knowsStartTime},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			err := os.WriteFile(filepath.Join(dir, ownerFileName), []byte(tt.owner), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if owned := isOwnedByLiveProcess(dir); owned != tt.owned {
+				t.Errorf("got owned %t for %q, want %t", owned, tt.owner, tt.owned)
+			}
+		})
+	}
+}
@@ -3,8 +3,6 @@ package tidal
 import (
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 
 	"github.com/altierawr/oto/internal/types"
@@ -60,40 +58,18 @@ type TidalAlbumItemsResponse struct {
 	} `json:"items"`
 }
 
-func (s *Service) GetAlbum(id int64) (*types.TidalAlbum, error) {
-	err := refreshTokens()
-	if err != nil {
-		return nil, err
-	}
-
-	albumUrl := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   fmt.Sprintf("/v1/albums/%d", id),
-	}
-
-	albumQuery := albumUrl.Query()
-	albumQuery.Set("countryCode", "FI")
-	albumUrl.RawQuery = albumQuery.Encode()
+func (c *Client) GetAlbum(id int64, region Region) (*types.TidalAlbum, error) {
+	region = c.resolveRegion(region)
 
-	albumItemsUrl := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   fmt.Sprintf("/v1/albums/%d/items", id),
-	}
+	albumQuery := url.Values{}
+	albumQuery.Set("countryCode", region.CountryCode)
+	albumQuery.Set("locale", region.Locale)
 
-	itemsQuery := albumItemsUrl.Query()
-	itemsQuery.Set("countryCode", "FI")
-	itemsQuery.Set("locale", "en_US")
+	itemsQuery := url.Values{}
+	itemsQuery.Set("countryCode", region.CountryCode)
+	itemsQuery.Set("locale", region.Locale)
 	itemsQuery.Set("limit", "100")
 	itemsQuery.Set("offset", "0")
-	albumItemsUrl.RawQuery = itemsQuery.Encode()
-
-	albumReq, _ := http.NewRequest(http.MethodGet, albumUrl.String(), nil)
-	albumReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
-
-	itemsReq, _ := http.NewRequest(http.MethodGet, albumItemsUrl.String(), nil)
-	itemsReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
 
 	type Result struct {
 		Body  []byte
@@ -104,37 +80,13 @@ func (s *Service) GetAlbum(id int64) (*types.TidalAlbum, error) {
 	itemsResultChan := make(chan Result, 1)
 
 	go func() {
-		resp, err := http.DefaultClient.Do(albumReq)
-		if err != nil {
-			albumResultChan <- Result{Error: err}
-			return
-		}
-
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			albumResultChan <- Result{Error: err}
-		}
-
-		albumResultChan <- Result{Body: body}
+		body, err := c.getCatalog(ResourceAlbum, fmt.Sprintf("/v1/albums/%d", id), albumQuery)
+		albumResultChan <- Result{Body: body, Error: err}
 	}()
 
 	go func() {
-		resp, err := http.DefaultClient.Do(itemsReq)
-		if err != nil {
-			itemsResultChan <- Result{Error: err}
-			return
-		}
-
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			itemsResultChan <- Result{Error: err}
-		}
-
-		itemsResultChan <- Result{Body: body}
+		body, err := c.getCatalog(ResourceAlbum, fmt.Sprintf("/v1/albums/%d/items", id), itemsQuery)
+		itemsResultChan <- Result{Body: body, Error: err}
 	}()
 
 	albumResult := <-albumResultChan
@@ -149,12 +101,12 @@ func (s *Service) GetAlbum(id int64) (*types.TidalAlbum, error) {
 	}
 
 	var albumResponse TidalAlbumResponse
-	if err = json.Unmarshal(albumResult.Body, &albumResponse); err != nil {
+	if err := json.Unmarshal(albumResult.Body, &albumResponse); err != nil {
 		return nil, err
 	}
 
 	var itemsResponse TidalAlbumItemsResponse
-	if err = json.Unmarshal(itemsResult.Body, &itemsResponse); err != nil {
+	if err := json.Unmarshal(itemsResult.Body, &itemsResponse); err != nil {
 		return nil, err
 	}
 
@@ -213,21 +165,5 @@ func (s *Service) GetAlbum(id int64) (*types.TidalAlbum, error) {
 		album.Songs = append(album.Songs, song)
 	}
 
-	err = s.db.InsertTidalAlbum(&album, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal album",
-			"error", err.Error(),
-			"id", album.ID,
-			"title", album.Title)
-	}
-
-	err = s.db.InsertTidalTracks(album.Songs, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal tracks of album",
-			"error", err.Error(),
-			"albumId", album.ID,
-			"albumTitle", album.Title)
-	}
-
 	return &album, nil
 }
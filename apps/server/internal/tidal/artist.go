@@ -3,8 +3,6 @@ package tidal
 import (
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 
 	"github.com/altierawr/oto/internal/types"
@@ -200,36 +198,10 @@ type TidalArtistAlbumTypeResponse struct {
 	} `json:"items"`
 }
 
-func (s *Service) GetArtistPage(id int64) (*types.TidalArtistPage, error) {
-	err := refreshTokens()
-	if err != nil {
-		return nil, err
-	}
-
-	artistUrl := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   fmt.Sprintf("/v2/artist/%d", id),
-	}
-
-	q := artistUrl.Query()
-	q.Set("locale", "en_US")
-	q.Set("countryCode", "US")
-	q.Set("deviceType", "BROWSER")
-	q.Set("platform", "WEB")
-	artistUrl.RawQuery = q.Encode()
-
-	req, _ := http.NewRequest(http.MethodGet, artistUrl.String(), nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
-	req.Header.Set("x-tidal-client-version", "2026.1.5")
+func (c *Client) GetArtistPage(id int64, region Region) (*types.TidalArtistPage, error) {
+	q := url.Values{}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.getCatalogPage(ResourceArtist, fmt.Sprintf("/v2/artist/%d", id), q, region)
 	if err != nil {
 		return nil, err
 	}
@@ -341,51 +313,6 @@ func (s *Service) GetArtistPage(id int64) (*types.TidalArtistPage, error) {
 		}
 	}
 
-	artist := types.TidalArtist{
-		ID:                         page.ID,
-		Name:                       page.Name,
-		Picture:                    page.Picture,
-		SelectedAlbumCoverFallback: page.SelectedAlbumCoverFallback,
-	}
-
-	err = s.db.InsertTidalArtist(&artist, nil)
-	if err != nil {
-		s.logger.Error("error inserting artist in artist page getter",
-			"error", err.Error(),
-			"id", id)
-	}
-
-	err = s.db.InsertTidalArtists(page.SimilarArtists, nil)
-	if err != nil {
-		s.logger.Error("error inserting similar artists in artist page getter",
-			"error", err.Error(),
-			"id", id)
-	}
-
-	err = s.db.InsertTidalAlbums(page.Albums, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal albums in artist page getter",
-			"error", err.Error())
-	}
-
-	err = s.db.InsertTidalAlbums(page.AppearsOn, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal albums (appears on) in artist page getter",
-			"error", err.Error())
-	}
-
-	err = s.db.InsertTidalAlbums(page.Compilations, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal albums (compilations) in artist page getter",
-			"error", err.Error())
-	}
-
-	err = s.db.InsertTidalTracks(page.TopTracks, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal tracks (top tracks) in artist page getter",
-			"error", err.Error())
-	}
-
 	return &page, nil
 }
 
@@ -396,32 +323,14 @@ type TidalArtistInfoResponse struct {
 	SelectedAlbumCoverFallback *string `json:"selectedAlbumCoverFallback"`
 }
 
-func GetArtistBasicInfo(id int64) (*types.TidalArtist, error) {
-	err := refreshTokens()
-	if err != nil {
-		return nil, err
-	}
-
-	artistUrl := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   fmt.Sprintf("/v1/artists/%d", id),
-	}
-
-	q := artistUrl.Query()
-	q.Set("countryCode", "US")
-	artistUrl.RawQuery = q.Encode()
+func (c *Client) GetArtistBasicInfo(id int64, region Region) (*types.TidalArtist, error) {
+	region = c.resolveRegion(region)
 
-	req, _ := http.NewRequest(http.MethodGet, artistUrl.String(), nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
+	q := url.Values{}
+	q.Set("countryCode", region.CountryCode)
+	q.Set("locale", region.Locale)
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.getCatalog(ResourceArtist, fmt.Sprintf("/v1/artists/%d", id), q)
 	if err != nil {
 		return nil, err
 	}
@@ -473,39 +382,13 @@ type ArtistTopTracksResult struct {
 	MaybeHasMorePages bool              `json:"maybeHasMorePages"`
 }
 
-func (s *Service) GetArtistTopTracks(id int64, page int) (*ArtistTopTracksResult, error) {
-	err := refreshTokens()
-	if err != nil {
-		return nil, err
-	}
-
-	artistUrl := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   "/v2/artist/ARTIST_TOP_TRACKS/view-all",
-	}
-
-	q := artistUrl.Query()
-	q.Set("locale", "en_US")
+func (c *Client) GetArtistTopTracks(id int64, page int, region Region) (*ArtistTopTracksResult, error) {
+	q := url.Values{}
 	q.Set("itemId", fmt.Sprintf("%d", id))
-	q.Set("countryCode", "US")
-	q.Set("deviceType", "BROWSER")
-	q.Set("platform", "WEB")
 	q.Set("limit", fmt.Sprintf("%d", artistPageSize))
 	q.Set("offset", fmt.Sprintf("%d", page*artistPageSize))
-	artistUrl.RawQuery = q.Encode()
-
-	req, _ := http.NewRequest(http.MethodGet, artistUrl.String(), nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
-	req.Header.Set("x-tidal-client-version", "2026.1.5")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.getCatalogPage(ResourceArtistItems, "/v2/artist/ARTIST_TOP_TRACKS/view-all", q, region)
 	if err != nil {
 		return nil, err
 	}
@@ -547,13 +430,6 @@ func (s *Service) GetArtistTopTracks(id int64, page int) (*ArtistTopTracksResult
 		tracks = append(tracks, song)
 	}
 
-	err = s.db.InsertTidalTracks(tracks, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal artist top tracks",
-			"error", err.Error(),
-			"artistId", id)
-	}
-
 	return &ArtistTopTracksResult{
 		Items:             tracks,
 		MaybeHasMorePages: len(tracks) == artistPageSize,
@@ -565,39 +441,13 @@ type ArtistAlbumsResult struct {
 	MaybeHasMorePages bool               `json:"maybeHasMorePages"`
 }
 
-func (s *Service) GetArtistAlbums(id int64, page int) (*ArtistAlbumsResult, error) {
-	err := refreshTokens()
-	if err != nil {
-		return nil, err
-	}
-
-	artistUrl := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   "/v2/artist/ARTIST_ALBUMS/view-all",
-	}
-
-	q := artistUrl.Query()
-	q.Set("locale", "en_US")
+func (c *Client) GetArtistAlbums(id int64, page int, region Region) (*ArtistAlbumsResult, error) {
+	q := url.Values{}
 	q.Set("itemId", fmt.Sprintf("%d", id))
-	q.Set("countryCode", "US")
-	q.Set("deviceType", "BROWSER")
-	q.Set("platform", "WEB")
 	q.Set("limit", fmt.Sprintf("%d", artistPageSize))
 	q.Set("offset", fmt.Sprintf("%d", page*artistPageSize))
-	artistUrl.RawQuery = q.Encode()
-
-	req, _ := http.NewRequest(http.MethodGet, artistUrl.String(), nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
-	req.Header.Set("x-tidal-client-version", "2026.1.5")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.getCatalogPage(ResourceArtistItems, "/v2/artist/ARTIST_ALBUMS/view-all", q, region)
 	if err != nil {
 		return nil, err
 	}
@@ -637,12 +487,6 @@ func (s *Service) GetArtistAlbums(id int64, page int) (*ArtistAlbumsResult, erro
 		albums = append(albums, album)
 	}
 
-	err = s.db.InsertTidalAlbums(albums, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal albums in artist albums getter",
-			"error", err.Error())
-	}
-
 	return &ArtistAlbumsResult{
 		Items:             albums,
 		MaybeHasMorePages: len(albums) == artistPageSize,
@@ -654,39 +498,13 @@ type ArtistSinglesAndEpsResult struct {
 	MaybeHasMorePages bool               `json:"maybeHasMorePages"`
 }
 
-func (s *Service) GetArtistSinglesAndEps(id int64, page int) (*ArtistSinglesAndEpsResult, error) {
-	err := refreshTokens()
-	if err != nil {
-		return nil, err
-	}
-
-	artistUrl := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   "/v2/artist/ARTIST_TOP_SINGLES/view-all",
-	}
-
-	q := artistUrl.Query()
-	q.Set("locale", "en_US")
+func (c *Client) GetArtistSinglesAndEps(id int64, page int, region Region) (*ArtistSinglesAndEpsResult, error) {
+	q := url.Values{}
 	q.Set("itemId", fmt.Sprintf("%d", id))
-	q.Set("countryCode", "US")
-	q.Set("deviceType", "BROWSER")
-	q.Set("platform", "WEB")
 	q.Set("limit", fmt.Sprintf("%d", artistPageSize))
 	q.Set("offset", fmt.Sprintf("%d", page*artistPageSize))
-	artistUrl.RawQuery = q.Encode()
-
-	req, _ := http.NewRequest(http.MethodGet, artistUrl.String(), nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
-	req.Header.Set("x-tidal-client-version", "2026.1.5")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.getCatalogPage(ResourceArtistItems, "/v2/artist/ARTIST_TOP_SINGLES/view-all", q, region)
 	if err != nil {
 		return nil, err
 	}
@@ -726,12 +544,6 @@ func (s *Service) GetArtistSinglesAndEps(id int64, page int) (*ArtistSinglesAndE
 		albums = append(albums, album)
 	}
 
-	err = s.db.InsertTidalAlbums(albums, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal singles & eps in artist singles & eps getter",
-			"error", err.Error())
-	}
-
 	return &ArtistSinglesAndEpsResult{
 		Items:             albums,
 		MaybeHasMorePages: len(albums) == artistPageSize,
@@ -743,39 +555,13 @@ type ArtistCompilationsResult struct {
 	MaybeHasMorePages bool               `json:"maybeHasMorePages"`
 }
 
-func (s *Service) GetArtistCompilations(id int64, page int) (*ArtistCompilationsResult, error) {
-	err := refreshTokens()
-	if err != nil {
-		return nil, err
-	}
-
-	artistUrl := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   "/v2/artist/ARTIST_COMPILATIONS/view-all",
-	}
-
-	q := artistUrl.Query()
-	q.Set("locale", "en_US")
+func (c *Client) GetArtistCompilations(id int64, page int, region Region) (*ArtistCompilationsResult, error) {
+	q := url.Values{}
 	q.Set("itemId", fmt.Sprintf("%d", id))
-	q.Set("countryCode", "US")
-	q.Set("deviceType", "BROWSER")
-	q.Set("platform", "WEB")
 	q.Set("limit", fmt.Sprintf("%d", artistPageSize))
 	q.Set("offset", fmt.Sprintf("%d", page*artistPageSize))
-	artistUrl.RawQuery = q.Encode()
-
-	req, _ := http.NewRequest(http.MethodGet, artistUrl.String(), nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
-	req.Header.Set("x-tidal-client-version", "2026.1.5")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.getCatalogPage(ResourceArtistItems, "/v2/artist/ARTIST_COMPILATIONS/view-all", q, region)
 	if err != nil {
 		return nil, err
 	}
@@ -815,12 +601,6 @@ func (s *Service) GetArtistCompilations(id int64, page int) (*ArtistCompilations
 		albums = append(albums, album)
 	}
 
-	err = s.db.InsertTidalAlbums(albums, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal compilations in artist compilations getter",
-			"error", err.Error())
-	}
-
 	return &ArtistCompilationsResult{
 		Items:             albums,
 		MaybeHasMorePages: len(albums) == artistPageSize,
@@ -832,39 +612,13 @@ type ArtistAppearsOnResult struct {
 	MaybeHasMorePages bool               `json:"maybeHasMorePages"`
 }
 
-func (s *Service) GetArtistAppearsOn(id int64, page int) (*ArtistAppearsOnResult, error) {
-	err := refreshTokens()
-	if err != nil {
-		return nil, err
-	}
-
-	artistUrl := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   "/v2/artist/ARTIST_APPEARS_ON/view-all",
-	}
-
-	q := artistUrl.Query()
-	q.Set("locale", "en_US")
+func (c *Client) GetArtistAppearsOn(id int64, page int, region Region) (*ArtistAppearsOnResult, error) {
+	q := url.Values{}
 	q.Set("itemId", fmt.Sprintf("%d", id))
-	q.Set("countryCode", "US")
-	q.Set("deviceType", "BROWSER")
-	q.Set("platform", "WEB")
 	q.Set("limit", fmt.Sprintf("%d", artistPageSize))
 	q.Set("offset", fmt.Sprintf("%d", page*artistPageSize))
-	artistUrl.RawQuery = q.Encode()
-
-	req, _ := http.NewRequest(http.MethodGet, artistUrl.String(), nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
-	req.Header.Set("x-tidal-client-version", "2026.1.5")
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.getCatalogPage(ResourceArtistItems, "/v2/artist/ARTIST_APPEARS_ON/view-all", q, region)
 	if err != nil {
 		return nil, err
 	}
@@ -904,12 +658,6 @@ func (s *Service) GetArtistAppearsOn(id int64, page int) (*ArtistAppearsOnResult
 		albums = append(albums, album)
 	}
 
-	err = s.db.InsertTidalAlbums(albums, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal albums in artist appears on getter",
-			"error", err.Error())
-	}
-
 	return &ArtistAppearsOnResult{
 		Items:             albums,
 		MaybeHasMorePages: len(albums) == artistPageSize,
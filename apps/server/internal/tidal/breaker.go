@@ -0,0 +1,138 @@
+package tidal
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// breakerThreshold is how many calls in a row have to fail with an outage, after their retries, before the
+	// circuit opens
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+type CircuitState string
+
+const (
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen fails calls right away until the cooldown has passed
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen lets a single call through to find out whether tidal is back
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitStatus describes the circuit breaker of a client
+type CircuitStatus struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutiveFailures"`
+	OpenedAt            *time.Time   `json:"openedAt"`
+	RetryAt             *time.Time   `json:"retryAt"`
+}
+
+// circuitBreaker stops calling tidal during an outage, so that calls fail fast instead of each waiting for
+// their retries, and tidal isn't flooded with calls once it comes back
+type circuitBreaker struct {
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// trialing is whether the call that was let through in the half-open state is still in progress
+	trialing bool
+}
+
+func newCircuitBreaker(logger *slog.Logger) *circuitBreaker {
+	return &circuitBreaker{
+		logger: logger,
+		state:  CircuitClosed,
+	}
+}
+
+// allow returns whether a call can be made and whether it's the trial call of the half-open state. If the call
+// can't be made, it also returns how long until one can.
+func (b *circuitBreaker) allow() (bool, time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		remaining := time.Until(b.openedAt.Add(breakerCooldown))
+		if remaining > 0 {
+			return false, remaining, false
+		}
+
+		b.state = CircuitHalfOpen
+		b.trialing = true
+		return true, 0, true
+	case CircuitHalfOpen:
+		if b.trialing {
+			return false, time.Second, false
+		}
+
+		b.trialing = true
+		return true, 0, true
+	default:
+		return false, 0, true
+	}
+}
+
+// record records the outcome of a call that allow let through, trial is what allow returned for it
+func (b *circuitBreaker) record(trial bool, outage bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if trial {
+		b.trialing = false
+	} else if b.state != CircuitClosed {
+		// the call was let through before the circuit opened, so it doesn't tell whether tidal is back. Only the
+		// trial call decides that.
+		return
+	}
+
+	if !outage {
+		if b.state != CircuitClosed {
+			b.logger.Info("tidal is reachable again, closing the circuit")
+		}
+
+		b.state = CircuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+
+	switch {
+	case b.state == CircuitHalfOpen:
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	case b.state == CircuitClosed && b.failures >= breakerThreshold:
+		b.logger.Warn("tidal calls keep failing, opening the circuit",
+			"failures", b.failures,
+			"cooldown", breakerCooldown)
+
+		b.state = CircuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *circuitBreaker) status() CircuitStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := CircuitStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+	}
+
+	if b.state != CircuitClosed {
+		openedAt := b.openedAt
+		retryAt := b.openedAt.Add(breakerCooldown)
+		status.OpenedAt = &openedAt
+		status.RetryAt = &retryAt
+	}
+
+	return status
+}
@@ -0,0 +1,206 @@
+package tidal
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/altierawr/oto/internal/database"
+)
+
+// Resource is a type of catalog response, which decides how long the responses are cached for
+type Resource string
+
+const (
+	ResourceArtist      Resource = "artist"
+	ResourceArtistItems Resource = "artist-items"
+	ResourceAlbum       Resource = "album"
+	ResourceTrack       Resource = "track"
+	ResourceSearch      Resource = "search"
+	ResourcePlaylist    Resource = "playlist"
+	ResourceRadio       Resource = "radio"
+	ResourceCredits     Resource = "credits"
+)
+
+// CacheTTL is how long the responses of a resource are used. Until Fresh has passed they're used as they are,
+// and until Stale has passed they're used while they're refreshed in the background. Older responses are only
+// used when tidal can't be reached.
+type CacheTTL struct {
+	Fresh time.Duration
+	Stale time.Duration
+}
+
+var DefaultCacheTTLs = map[Resource]CacheTTL{
+	ResourceArtist:      {Fresh: 6 * time.Hour, Stale: 7 * 24 * time.Hour},
+	ResourceArtistItems: {Fresh: 6 * time.Hour, Stale: 7 * 24 * time.Hour},
+	ResourceAlbum:       {Fresh: 24 * time.Hour, Stale: 14 * 24 * time.Hour},
+	ResourceTrack:       {Fresh: 24 * time.Hour, Stale: 14 * 24 * time.Hour},
+	ResourceSearch:      {Fresh: time.Hour, Stale: 24 * time.Hour},
+	// playlists go stale before they're synced again, so that syncs see the changes to them
+	ResourcePlaylist: {Fresh: 10 * time.Minute, Stale: time.Hour},
+	ResourceRadio:    {Fresh: 6 * time.Hour, Stale: 24 * time.Hour},
+	ResourceCredits:  {Fresh: 7 * 24 * time.Hour, Stale: 30 * 24 * time.Hour},
+}
+
+// cacheRetention is how long responses are kept around for when tidal can't be reached
+const cacheRetention = 30 * 24 * time.Hour
+
+// Cache keeps raw catalog responses in the database
+type Cache struct {
+	db     *database.DB
+	logger *slog.Logger
+	ttls   map[Resource]CacheTTL
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+	wg         sync.WaitGroup
+}
+
+// NewCache returns a cache with the TTLs of each resource, nil for the default ones. Responses of resources
+// without a TTL aren't cached.
+func NewCache(db *database.DB, logger *slog.Logger, ttls map[Resource]CacheTTL) *Cache {
+	if ttls == nil {
+		ttls = DefaultCacheTTLs
+	}
+
+	return &Cache{
+		db:         db,
+		logger:     logger,
+		ttls:       ttls,
+		refreshing: make(map[string]bool),
+	}
+}
+
+// get returns the cached response of the key if it's fresh enough, and otherwise fetches it
+func (c *Cache) get(resource Resource, key string, fetch func() ([]byte, error)) ([]byte, error) {
+	ttl, found := c.ttls[resource]
+	if !found {
+		return fetch()
+	}
+
+	cached, err := c.db.GetTidalResponse(key)
+	if err != nil {
+		if !errors.Is(err, database.ErrRecordNotFound) {
+			c.logger.Error("couldn't get cached tidal response",
+				"error", err.Error(),
+				"key", key)
+		}
+
+		cached = nil
+	}
+
+	if cached != nil {
+		age := time.Since(time.Unix(cached.FetchedAt, 0))
+
+		if age < ttl.Fresh {
+			return cached.Body, nil
+		}
+
+		if age < ttl.Stale {
+			c.refresh(resource, key, fetch)
+			return cached.Body, nil
+		}
+	}
+
+	body, err := fetch()
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			if cached != nil {
+				c.delete(key)
+			}
+
+			return nil, err
+		}
+
+		if cached != nil {
+			c.logger.Warn("couldn't fetch from tidal, using an expired cached response",
+				"error", err.Error(),
+				"key", key)
+			return cached.Body, nil
+		}
+
+		return nil, err
+	}
+
+	c.store(resource, key, body)
+
+	return body, nil
+}
+
+// refresh fetches the response again in the background, unless it's already being refreshed
+func (c *Cache) refresh(resource Resource, key string, fetch func() ([]byte, error)) {
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
+		return
+	}
+	c.refreshing[key] = true
+	c.mu.Unlock()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer func() {
+			c.mu.Lock()
+			delete(c.refreshing, key)
+			c.mu.Unlock()
+		}()
+
+		body, err := fetch()
+		switch {
+		case err == nil:
+			c.store(resource, key, body)
+		case errors.Is(err, database.ErrRecordNotFound):
+			c.delete(key)
+		default:
+			// the stale response keeps being used until tidal can be reached again
+			c.logger.Warn("couldn't refresh cached tidal response",
+				"error", err.Error(),
+				"key", key)
+		}
+	}()
+}
+
+func (c *Cache) store(resource Resource, key string, body []byte) {
+	err := c.db.UpsertTidalResponse(key, string(resource), body)
+	if err != nil {
+		c.logger.Error("couldn't cache tidal response",
+			"error", err.Error(),
+			"key", key)
+	}
+}
+
+func (c *Cache) delete(key string) {
+	err := c.db.DeleteTidalResponse(key)
+	if err != nil {
+		c.logger.Error("couldn't delete cached tidal response",
+			"error", err.Error(),
+			"key", key)
+	}
+}
+
+// Purge deletes the responses that are too old to be used even when tidal can't be reached
+func (c *Cache) Purge() {
+	deleted, err := c.db.DeleteTidalResponsesBefore(time.Now().Add(-cacheRetention))
+	if err != nil {
+		c.logger.Error("couldn't purge cached tidal responses",
+			"error", err.Error())
+		return
+	}
+
+	if deleted > 0 {
+		c.logger.Info("purged cached tidal responses",
+			"count", deleted)
+	}
+}
+
+// Counts returns how many responses are cached for each resource
+func (c *Cache) Counts() (map[string]int, error) {
+	return c.db.GetTidalResponseCounts()
+}
+
+// Wait waits for the background refreshes to finish
+func (c *Cache) Wait() {
+	c.wg.Wait()
+}
@@ -0,0 +1,208 @@
+package tidal
+
+import (
+	"github.com/altierawr/oto/internal/types"
+)
+
+// GetAlbum fetches the album with its tracks and stores them
+func (s *Service) GetAlbum(id int64, region Region) (*types.TidalAlbum, error) {
+	album, err := s.client.GetAlbum(id, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalAlbum(album, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal album",
+			"error", err.Error(),
+			"id", album.ID,
+			"title", album.Title)
+	}
+
+	err = s.db.InsertTidalTracks(album.Songs, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal tracks of album",
+			"error", err.Error(),
+			"albumId", album.ID,
+			"albumTitle", album.Title)
+	}
+
+	return album, nil
+}
+
+// GetArtistPage fetches the page of the artist and stores the artist and everything listed on it
+func (s *Service) GetArtistPage(id int64, region Region) (*types.TidalArtistPage, error) {
+	page, err := s.client.GetArtistPage(id, region)
+	if err != nil {
+		return nil, err
+	}
+
+	artist := types.TidalArtist{
+		ID:                         page.ID,
+		Name:                       page.Name,
+		Picture:                    page.Picture,
+		SelectedAlbumCoverFallback: page.SelectedAlbumCoverFallback,
+	}
+
+	err = s.db.InsertTidalArtist(&artist, nil)
+	if err != nil {
+		s.logger.Error("error inserting artist in artist page getter",
+			"error", err.Error(),
+			"id", id)
+	}
+
+	err = s.db.InsertTidalArtists(page.SimilarArtists, nil)
+	if err != nil {
+		s.logger.Error("error inserting similar artists in artist page getter",
+			"error", err.Error(),
+			"id", id)
+	}
+
+	err = s.db.InsertTidalAlbums(page.Albums, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal albums in artist page getter",
+			"error", err.Error())
+	}
+
+	err = s.db.InsertTidalAlbums(page.AppearsOn, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal albums (appears on) in artist page getter",
+			"error", err.Error())
+	}
+
+	err = s.db.InsertTidalAlbums(page.Compilations, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal albums (compilations) in artist page getter",
+			"error", err.Error())
+	}
+
+	err = s.db.InsertTidalTracks(page.TopTracks, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal tracks (top tracks) in artist page getter",
+			"error", err.Error())
+	}
+
+	return page, nil
+}
+
+func (s *Service) GetArtistTopTracks(id int64, page int, region Region) (*ArtistTopTracksResult, error) {
+	result, err := s.client.GetArtistTopTracks(id, page, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalTracks(result.Items, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal artist top tracks",
+			"error", err.Error(),
+			"artistId", id)
+	}
+
+	return result, nil
+}
+
+func (s *Service) GetArtistAlbums(id int64, page int, region Region) (*ArtistAlbumsResult, error) {
+	result, err := s.client.GetArtistAlbums(id, page, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalAlbums(result.Items, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal albums in artist albums getter",
+			"error", err.Error())
+	}
+
+	return result, nil
+}
+
+func (s *Service) GetArtistSinglesAndEps(id int64, page int, region Region) (*ArtistSinglesAndEpsResult, error) {
+	result, err := s.client.GetArtistSinglesAndEps(id, page, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalAlbums(result.Items, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal singles & eps in artist singles & eps getter",
+			"error", err.Error())
+	}
+
+	return result, nil
+}
+
+func (s *Service) GetArtistCompilations(id int64, page int, region Region) (*ArtistCompilationsResult, error) {
+	result, err := s.client.GetArtistCompilations(id, page, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalAlbums(result.Items, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal compilations in artist compilations getter",
+			"error", err.Error())
+	}
+
+	return result, nil
+}
+
+func (s *Service) GetArtistAppearsOn(id int64, page int, region Region) (*ArtistAppearsOnResult, error) {
+	result, err := s.client.GetArtistAppearsOn(id, page, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalAlbums(result.Items, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal albums in artist appears on getter",
+			"error", err.Error())
+	}
+
+	return result, nil
+}
+
+// Search searches the catalog and stores the artists, albums and tracks that were found
+func (s *Service) Search(query string, region Region) (*types.TidalSearch, error) {
+	result, err := s.client.Search(query, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalArtists(result.Artists, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal artists in search",
+			"error", err.Error())
+	}
+
+	err = s.db.InsertTidalAlbums(result.Albums, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal albums in search",
+			"error", err.Error())
+	}
+
+	err = s.db.InsertTidalTracks(result.Songs, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal tracks in search",
+			"error", err.Error())
+	}
+
+	return result, nil
+}
+
+// GetSong fetches the track and stores it
+func (s *Service) GetSong(id int64, region Region) (*types.TidalSong, error) {
+	song, err := s.client.GetSong(id, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalTrack(song, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal track in tidal.GetSong",
+			"error", err.Error(),
+			"id", song.ID,
+			"title", song.Title)
+	}
+
+	return song, nil
+}
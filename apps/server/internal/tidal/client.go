@@ -0,0 +1,244 @@
+package tidal
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/altierawr/oto/internal/data"
+	"github.com/altierawr/oto/internal/database"
+)
+
+const (
+	DefaultAPIBaseURL  = "https://api.tidal.com"
+	DefaultAuthBaseURL = "https://auth.tidal.com"
+	DefaultCountryCode = "US"
+	DefaultLocale      = "en_US"
+)
+
+var (
+	// CountryCodeRX matches ISO 3166-1 alpha-2 country codes such as US
+	CountryCodeRX = regexp.MustCompile(`^[A-Z]{2}$`)
+	// LocaleRX matches locales such as en_US
+	LocaleRX = regexp.MustCompile(`^[a-z]{2}_[A-Z]{2}$`)
+)
+
+// clientVersion is sent to the v2 endpoints, which answer differently depending on the web client version
+const clientVersion = "2026.1.5"
+
+var ErrInvalidBaseURL = errors.New("invalid tidal base url")
+
+// Config configures a Client. Empty fields fall back to the defaults.
+type Config struct {
+	HTTPClient *http.Client
+	// APIBaseURL is where the catalog, search and playback endpoints are served, without a trailing slash
+	APIBaseURL string
+	// AuthBaseURL is where the oauth endpoints are served, without a trailing slash
+	AuthBaseURL string
+	// ClientId and Secret are the oauth client that the configured tokens were issued to. Like the tokens,
+	// they're replaced by the stored ones.
+	ClientId     string
+	Secret       string
+	AccessToken  string
+	RefreshToken string
+	CountryCode  string
+	Locale       string
+	// Store persists refreshed tokens, nil to keep them in memory only
+	Store TokenStore
+	// Cache keeps catalog responses, nil to always fetch them
+	Cache  *Cache
+	Logger *slog.Logger
+}
+
+// Client calls the Tidal API with the credentials of one account. It's safe for concurrent use.
+type Client struct {
+	httpClient  *http.Client
+	apiBaseURL  *url.URL
+	authBaseURL *url.URL
+	countryCode string
+	locale      string
+	cache       *Cache
+	logger      *slog.Logger
+
+	tokens  *tokenSource
+	breaker *circuitBreaker
+}
+
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{
+			Timeout: 30 * time.Second,
+		}
+	}
+
+	if cfg.APIBaseURL == "" {
+		cfg.APIBaseURL = DefaultAPIBaseURL
+	}
+
+	if cfg.AuthBaseURL == "" {
+		cfg.AuthBaseURL = DefaultAuthBaseURL
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	if cfg.CountryCode == "" {
+		cfg.CountryCode = DefaultCountryCode
+	}
+
+	if cfg.Locale == "" {
+		cfg.Locale = DefaultLocale
+	}
+
+	apiBaseURL, err := parseBaseURL(cfg.APIBaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	authBaseURL, err := parseBaseURL(cfg.AuthBaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		httpClient:  cfg.HTTPClient,
+		apiBaseURL:  apiBaseURL,
+		authBaseURL: authBaseURL,
+		countryCode: cfg.CountryCode,
+		locale:      cfg.Locale,
+		cache:       cfg.Cache,
+		logger:      cfg.Logger,
+		breaker:     newCircuitBreaker(cfg.Logger),
+	}
+	c.tokens = newTokenSource(c, cfg.Store, cfg.Logger, database.TidalTokens{
+		AccessToken:  cfg.AccessToken,
+		RefreshToken: cfg.RefreshToken,
+		ClientId:     cfg.ClientId,
+		ClientSecret: cfg.Secret,
+	})
+
+	return c, nil
+}
+
+func parseBaseURL(raw string) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimSuffix(raw, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %w", ErrInvalidBaseURL, raw, err)
+	}
+
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("%w %q: expected an absolute url", ErrInvalidBaseURL, raw)
+	}
+
+	return parsed, nil
+}
+
+// Cache returns the cache of catalog responses, nil if responses aren't cached
+func (c *Client) Cache() *Cache {
+	return c.cache
+}
+
+// Region is the country that the catalog is requested for, which decides what is available, and the locale
+// that its texts are in. Empty fields fall back to the defaults of the client.
+type Region struct {
+	CountryCode string `json:"countryCode"`
+	Locale      string `json:"locale"`
+}
+
+// DefaultRegion returns the region that calls fall back to
+func (c *Client) DefaultRegion() Region {
+	return Region{
+		CountryCode: c.countryCode,
+		Locale:      c.locale,
+	}
+}
+
+// UserRegion returns the region that the user has picked, with the fields they haven't picked left empty
+func UserRegion(user *data.User) Region {
+	var region Region
+	if user.CountryCode != nil {
+		region.CountryCode = *user.CountryCode
+	}
+
+	if user.Locale != nil {
+		region.Locale = *user.Locale
+	}
+
+	return region
+}
+
+func (c *Client) resolveRegion(region Region) Region {
+	if region.CountryCode == "" {
+		region.CountryCode = c.countryCode
+	}
+
+	if region.Locale == "" {
+		region.Locale = c.locale
+	}
+
+	return region
+}
+
+func resolveURL(base *url.URL, path string, query url.Values) string {
+	resolved := *base
+	resolved.Path = base.Path + path
+	if query != nil {
+		resolved.RawQuery = query.Encode()
+	}
+
+	return resolved.String()
+}
+
+// getCatalog returns the body of a catalog response, from the cache if it's fresh enough
+func (c *Client) getCatalog(resource Resource, path string, query url.Values) ([]byte, error) {
+	return c.getCached(resource, path, query, false)
+}
+
+// getCatalogPage is like getCatalog for the v2 endpoints that the web client uses to build its pages
+func (c *Client) getCatalogPage(resource Resource, path string, query url.Values, region Region) ([]byte, error) {
+	region = c.resolveRegion(region)
+	query.Set("locale", region.Locale)
+	query.Set("countryCode", region.CountryCode)
+	query.Set("deviceType", "BROWSER")
+	query.Set("platform", "WEB")
+
+	return c.getCached(resource, path, query, true)
+}
+
+func (c *Client) getCached(resource Resource, path string, query url.Values, page bool) ([]byte, error) {
+	fetch := func() ([]byte, error) {
+		return c.get(path, query, page)
+	}
+
+	if c.cache == nil {
+		return fetch()
+	}
+
+	// the query holds the country and locale, so responses for different ones are cached separately
+	return c.cache.get(resource, path+"?"+query.Encode(), fetch)
+}
+
+func (c *Client) get(path string, query url.Values, page bool) ([]byte, error) {
+	header := http.Header{}
+	if page {
+		header.Set("x-tidal-client-version", clientVersion)
+	}
+
+	res, err := c.do(http.MethodGet, path, query, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.body, nil
+}
+
+// CircuitStatus returns the state of the circuit breaker of the client
+func (c *Client) CircuitStatus() CircuitStatus {
+	return c.breaker.status()
+}
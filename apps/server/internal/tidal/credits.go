@@ -0,0 +1,170 @@
+package tidal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/types"
+)
+
+const albumCreditsPageSize = 100 // Max limit = 100
+
+type TidalCreditResponse struct {
+	Type         string `json:"type"`
+	Contributors []struct {
+		Name string `json:"name"`
+		ID   *int   `json:"id"`
+	} `json:"contributors"`
+}
+
+type TidalAlbumCreditsResponse struct {
+	TotalNumberOfItems int `json:"totalNumberOfItems"`
+	Items              []struct {
+		Type    string                `json:"type"` // track, video
+		Item    TidalTrackResponse    `json:"item"`
+		Credits []TidalCreditResponse `json:"credits"`
+	} `json:"items"`
+}
+
+func toCredits(creditsResp []TidalCreditResponse) []types.TidalCredit {
+	credits := []types.TidalCredit{}
+	for _, creditResp := range creditsResp {
+		credit := types.TidalCredit{
+			Type:         creditResp.Type,
+			Contributors: []types.TidalContributor{},
+		}
+
+		for _, contributor := range creditResp.Contributors {
+			credit.Contributors = append(credit.Contributors, types.TidalContributor{
+				ID:   contributor.ID,
+				Name: contributor.Name,
+			})
+		}
+
+		credits = append(credits, credit)
+	}
+
+	return credits
+}
+
+// GetTrackCredits fetches the credits of the track, such as its producers and composers
+func (c *Client) GetTrackCredits(id int64, region Region) ([]types.TidalCredit, error) {
+	region = c.resolveRegion(region)
+
+	q := url.Values{}
+	q.Set("countryCode", region.CountryCode)
+	q.Set("locale", region.Locale)
+	q.Set("includeContributors", "true")
+
+	body, err := c.getCatalog(ResourceCredits, fmt.Sprintf("/v1/tracks/%d/credits", id), q)
+	if err != nil {
+		return nil, err
+	}
+
+	var creditsResp []TidalCreditResponse
+	if err = json.Unmarshal(body, &creditsResp); err != nil {
+		return nil, err
+	}
+
+	return toCredits(creditsResp), nil
+}
+
+// GetAlbumCredits fetches the tracks of the album with the credits of each track. Videos on the album are left
+// out.
+func (c *Client) GetAlbumCredits(id int64, region Region) ([]types.TidalSong, error) {
+	region = c.resolveRegion(region)
+	tracks := []types.TidalSong{}
+
+	for offset := 0; ; offset += albumCreditsPageSize {
+		q := url.Values{}
+		q.Set("countryCode", region.CountryCode)
+		q.Set("locale", region.Locale)
+		q.Set("includeContributors", "true")
+		q.Set("replace", "true")
+		q.Set("limit", fmt.Sprintf("%d", albumCreditsPageSize))
+		q.Set("offset", fmt.Sprintf("%d", offset))
+
+		body, err := c.getCatalog(ResourceCredits, fmt.Sprintf("/v1/albums/%d/items/credits", id), q)
+		if err != nil {
+			return nil, err
+		}
+
+		var creditsResp TidalAlbumCreditsResponse
+		if err = json.Unmarshal(body, &creditsResp); err != nil {
+			return nil, err
+		}
+
+		for _, item := range creditsResp.Items {
+			if item.Type != "track" {
+				continue
+			}
+
+			track := item.Item.toSong()
+			track.Credits = toCredits(item.Credits)
+			tracks = append(tracks, track)
+		}
+
+		if len(creditsResp.Items) == 0 || offset+albumCreditsPageSize >= creditsResp.TotalNumberOfItems {
+			return tracks, nil
+		}
+	}
+}
+
+// GetTrackCredits fetches the credits of the track and stores them. The track is fetched and stored first if
+// it isn't yet, since credits are stored for stored tracks only.
+func (s *Service) GetTrackCredits(id int64, region Region) ([]types.TidalCredit, error) {
+	credits, err := s.client.GetTrackCredits(id, region)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.GetTidalTrack(id)
+	if errors.Is(err, database.ErrRecordNotFound) {
+		_, err = s.GetSong(id, region)
+	}
+	if err != nil {
+		s.logger.Error("couldn't get tidal track for credits",
+			"error", err.Error(),
+			"trackId", id)
+		return credits, nil
+	}
+
+	err = s.db.ReplaceTidalTrackCredits(id, credits)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal track credits",
+			"error", err.Error(),
+			"trackId", id)
+	}
+
+	return credits, nil
+}
+
+// GetAlbumCredits fetches the tracks of the album with their credits and stores them
+func (s *Service) GetAlbumCredits(id int64, region Region) ([]types.TidalSong, error) {
+	tracks, err := s.client.GetAlbumCredits(id, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalTracks(tracks, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal album credits tracks",
+			"error", err.Error(),
+			"albumId", id)
+		return tracks, nil
+	}
+
+	for _, track := range tracks {
+		err = s.db.ReplaceTidalTrackCredits(int64(track.ID), track.Credits)
+		if err != nil {
+			s.logger.Error("couldn't insert tidal track credits",
+				"error", err.Error(),
+				"trackId", track.ID)
+		}
+	}
+
+	return tracks, nil
+}
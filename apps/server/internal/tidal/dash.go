@@ -0,0 +1,306 @@
+package tidal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the parts of a DASH MPD that Tidal uses for audio
+type dashMPD struct {
+	MediaPresentationDuration string       `xml:"mediaPresentationDuration,attr"`
+	BaseURL                   string       `xml:"BaseURL"`
+	Periods                   []dashPeriod `xml:"Period"`
+}
+
+type dashPeriod struct {
+	Duration       string              `xml:"duration,attr"`
+	BaseURL        string              `xml:"BaseURL"`
+	AdaptationSets []dashAdaptationSet `xml:"AdaptationSet"`
+}
+
+type dashAdaptationSet struct {
+	MimeType        string               `xml:"mimeType,attr"`
+	ContentType     string               `xml:"contentType,attr"`
+	Codecs          string               `xml:"codecs,attr"`
+	BaseURL         string               `xml:"BaseURL"`
+	SegmentTemplate *dashSegmentTemplate `xml:"SegmentTemplate"`
+	Representations []dashRepresentation `xml:"Representation"`
+}
+
+type dashRepresentation struct {
+	ID              string               `xml:"id,attr"`
+	MimeType        string               `xml:"mimeType,attr"`
+	Codecs          string               `xml:"codecs,attr"`
+	Bandwidth       int                  `xml:"bandwidth,attr"`
+	BaseURL         string               `xml:"BaseURL"`
+	SegmentTemplate *dashSegmentTemplate `xml:"SegmentTemplate"`
+}
+
+type dashSegmentTemplate struct {
+	Timescale       int64                `xml:"timescale,attr"`
+	Duration        int64                `xml:"duration,attr"`
+	StartNumber     *int64               `xml:"startNumber,attr"`
+	Initialization  string               `xml:"initialization,attr"`
+	Media           string               `xml:"media,attr"`
+	SegmentTimeline *dashSegmentTimeline `xml:"SegmentTimeline"`
+}
+
+type dashSegmentTimeline struct {
+	Segments []dashTimelineSegment `xml:"S"`
+}
+
+type dashTimelineSegment struct {
+	Duration int64 `xml:"d,attr"`
+	Repeat   int64 `xml:"r,attr"`
+}
+
+// matches $Identifier$ and $Identifier%0Nd$ in segment templates
+var dashTemplatePattern = regexp.MustCompile(`\$(RepresentationID|Number|Bandwidth|Time)(%0(\d+)d)?\$`)
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseDASHManifest turns the audio representation with the highest bandwidth of the first period into
+// the urls of its initialization and media segments
+func parseDASHManifest(decoded []byte) (*Source, error) {
+	var mpd dashMPD
+	if err := xml.Unmarshal(decoded, &mpd); err != nil {
+		return nil, err
+	}
+
+	if len(mpd.Periods) == 0 {
+		return nil, fmt.Errorf("%w: dash manifest has no periods", ErrUnsupportedManifest)
+	}
+
+	period := mpd.Periods[0]
+
+	var adaptationSet *dashAdaptationSet
+	var representation *dashRepresentation
+	for i := range period.AdaptationSets {
+		set := &period.AdaptationSets[i]
+		if set.ContentType != "" && set.ContentType != "audio" {
+			continue
+		}
+
+		for j := range set.Representations {
+			if representation == nil || set.Representations[j].Bandwidth > representation.Bandwidth {
+				adaptationSet = set
+				representation = &set.Representations[j]
+			}
+		}
+	}
+
+	if representation == nil {
+		return nil, fmt.Errorf("%w: dash manifest has no audio representation", ErrUnsupportedManifest)
+	}
+
+	template := representation.SegmentTemplate
+	if template == nil {
+		template = adaptationSet.SegmentTemplate
+	}
+
+	base, err := resolveDASHBaseURL(mpd.BaseURL, period.BaseURL, adaptationSet.BaseURL, representation.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	mimeType := representation.MimeType
+	if mimeType == "" {
+		mimeType = adaptationSet.MimeType
+	}
+
+	codecs := representation.Codecs
+	if codecs == "" {
+		codecs = adaptationSet.Codecs
+	}
+
+	source := &Source{
+		MimeType: mimeType,
+		Codecs:   codecs,
+	}
+
+	// a representation without a template is a single file at its base url
+	if template == nil {
+		if base == nil {
+			return nil, fmt.Errorf("%w: dash representation has no segments", ErrUnsupportedManifest)
+		}
+
+		source.URLs = []string{base.String()}
+		return source, nil
+	}
+
+	durationStr := period.Duration
+	if durationStr == "" {
+		durationStr = mpd.MediaPresentationDuration
+	}
+
+	urls, err := dashSegmentURLs(template, representation, base, durationStr)
+	if err != nil {
+		return nil, err
+	}
+
+	source.URLs = urls
+	return source, nil
+}
+
+func dashSegmentURLs(
+	template *dashSegmentTemplate,
+	representation *dashRepresentation,
+	base *url.URL,
+	durationStr string,
+) ([]string, error) {
+	if template.Media == "" {
+		return nil, fmt.Errorf("%w: dash segment template has no media", ErrUnsupportedManifest)
+	}
+
+	number := int64(1)
+	if template.StartNumber != nil {
+		number = *template.StartNumber
+	}
+
+	// the start time of every segment, from the timeline or from the fixed segment duration
+	times := []int64{}
+	if template.SegmentTimeline != nil {
+		t := int64(0)
+		for _, segment := range template.SegmentTimeline.Segments {
+			for range segment.Repeat + 1 {
+				times = append(times, t)
+				t += segment.Duration
+			}
+		}
+	} else {
+		if template.Duration <= 0 || template.Timescale <= 0 {
+			return nil, fmt.Errorf("%w: dash segment template has no timeline or duration", ErrUnsupportedManifest)
+		}
+
+		total, err := parseISODuration(durationStr)
+		if err != nil {
+			return nil, err
+		}
+
+		segmentDuration := float64(template.Duration) / float64(template.Timescale)
+		count := int64(math.Ceil(total.Seconds() / segmentDuration))
+		for i := range count {
+			times = append(times, i*template.Duration)
+		}
+	}
+
+	if len(times) == 0 {
+		return nil, fmt.Errorf("%w: dash manifest has no segments", ErrUnsupportedManifest)
+	}
+
+	urls := []string{}
+	if template.Initialization != "" {
+		initURL, err := resolveDASHURL(base, expandDASHTemplate(template.Initialization, representation, number, 0))
+		if err != nil {
+			return nil, err
+		}
+
+		urls = append(urls, initURL)
+	}
+
+	for i, t := range times {
+		mediaURL, err := resolveDASHURL(base, expandDASHTemplate(template.Media, representation, number+int64(i), t))
+		if err != nil {
+			return nil, err
+		}
+
+		urls = append(urls, mediaURL)
+	}
+
+	return urls, nil
+}
+
+func expandDASHTemplate(template string, representation *dashRepresentation, number int64, startTime int64) string {
+	expanded := dashTemplatePattern.ReplaceAllStringFunc(template, func(match string) string {
+		parts := dashTemplatePattern.FindStringSubmatch(match)
+
+		var value string
+		switch parts[1] {
+		case "RepresentationID":
+			return representation.ID
+		case "Number":
+			value = strconv.FormatInt(number, 10)
+		case "Bandwidth":
+			value = strconv.Itoa(representation.Bandwidth)
+		case "Time":
+			value = strconv.FormatInt(startTime, 10)
+		}
+
+		if width, err := strconv.Atoi(parts[3]); err == nil && len(value) < width {
+			value = strings.Repeat("0", width-len(value)) + value
+		}
+
+		return value
+	})
+
+	return strings.ReplaceAll(expanded, "$$", "$")
+}
+
+// resolveDASHBaseURL resolves the nested BaseURL elements, each relative to the one above it
+func resolveDASHBaseURL(baseURLs ...string) (*url.URL, error) {
+	var base *url.URL
+	for _, baseURL := range baseURLs {
+		baseURL = strings.TrimSpace(baseURL)
+		if baseURL == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(baseURL)
+		if err != nil {
+			return nil, err
+		}
+
+		if base != nil {
+			parsed = base.ResolveReference(parsed)
+		}
+
+		base = parsed
+	}
+
+	return base, nil
+}
+
+func resolveDASHURL(base *url.URL, ref string) (string, error) {
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+
+	if base == nil {
+		return parsed.String(), nil
+	}
+
+	return base.ResolveReference(parsed).String(), nil
+}
+
+// parseISODuration parses the ISO 8601 durations used by DASH, such as PT3M21.5S
+func parseISODuration(value string) (time.Duration, error) {
+	parts := isoDurationPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if parts == nil || value == "P" || value == "PT" {
+		return 0, fmt.Errorf("%w: invalid duration %q", ErrUnsupportedManifest, value)
+	}
+
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+
+	total := 0.0
+	for i, unit := range units {
+		if parts[i+1] == "" {
+			continue
+		}
+
+		amount, err := strconv.ParseFloat(parts[i+1], 64)
+		if err != nil {
+			return 0, err
+		}
+
+		total += amount * float64(unit)
+	}
+
+	return time.Duration(total), nil
+}
@@ -0,0 +1,178 @@
+package tidal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+	deviceLoginScope    = "r_usr w_usr w_sub"
+	// deviceSlowDownStep is how much longer the polling interval gets every time tidal asks to slow down
+	deviceSlowDownStep = 5 * time.Second
+)
+
+var (
+	ErrNoClientId         = errors.New("a tidal client id is needed to log in")
+	ErrDeviceLoginExpired = errors.New("the tidal login expired before it was completed")
+	ErrDeviceLoginDenied  = errors.New("the tidal login was denied")
+)
+
+type DeviceLoginState string
+
+const (
+	DeviceLoginPending   DeviceLoginState = "pending"
+	DeviceLoginCompleted DeviceLoginState = "completed"
+	DeviceLoginExpired   DeviceLoginState = "expired"
+	DeviceLoginFailed    DeviceLoginState = "failed"
+)
+
+// DeviceLogin is a login with tidal's device authorization flow. It's completed by visiting the verification
+// url and entering the user code, or by visiting the complete verification url which already contains the code.
+type DeviceLogin struct {
+	UserCode                string           `json:"userCode"`
+	VerificationURL         string           `json:"verificationUrl"`
+	VerificationURLComplete string           `json:"verificationUrlComplete"`
+	ExpiresAt               time.Time        `json:"expiresAt"`
+	State                   DeviceLoginState `json:"state"`
+	Error                   string           `json:"error,omitempty"`
+
+	deviceCode   string
+	interval     time.Duration
+	clientId     string
+	clientSecret string
+}
+
+type deviceAuthorizationResponse struct {
+	DeviceCode              string `json:"deviceCode"`
+	UserCode                string `json:"userCode"`
+	VerificationURI         string `json:"verificationUri"`
+	VerificationURIComplete string `json:"verificationUriComplete"`
+	ExpiresIn               int    `json:"expiresIn"`
+	Interval                int    `json:"interval"`
+}
+
+// StartDeviceLogin asks tidal for a user code to log in with. Without a client id, the client of the current
+// tokens is used.
+func (c *Client) StartDeviceLogin(clientId string, clientSecret string) (*DeviceLogin, error) {
+	if clientId == "" {
+		clientId, clientSecret = c.tokens.credentials()
+	}
+
+	if clientId == "" {
+		return nil, ErrNoClientId
+	}
+
+	form := url.Values{
+		"client_id": {clientId},
+		"scope":     {deviceLoginScope},
+	}
+
+	req, err := http.NewRequest(http.MethodPost, resolveURL(c.authBaseURL, "/v1/oauth2/device_authorization", nil), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tidal device authorization endpoint returned status %d: %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var authorization deviceAuthorizationResponse
+	if err := json.Unmarshal(body, &authorization); err != nil {
+		return nil, err
+	}
+	if authorization.DeviceCode == "" || authorization.UserCode == "" {
+		return nil, errors.New("empty device code in response")
+	}
+
+	interval := time.Duration(authorization.Interval) * time.Second
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	return &DeviceLogin{
+		UserCode:                authorization.UserCode,
+		VerificationURL:         withScheme(authorization.VerificationURI),
+		VerificationURLComplete: withScheme(authorization.VerificationURIComplete),
+		ExpiresAt:               time.Now().Add(time.Duration(authorization.ExpiresIn) * time.Second),
+		State:                   DeviceLoginPending,
+		deviceCode:              authorization.DeviceCode,
+		interval:                interval,
+		clientId:                clientId,
+		clientSecret:            clientSecret,
+	}, nil
+}
+
+// tidal leaves out the scheme of its verification urls
+func withScheme(verificationURL string) string {
+	if verificationURL == "" || strings.Contains(verificationURL, "://") {
+		return verificationURL
+	}
+
+	return "https://" + verificationURL
+}
+
+// CompleteDeviceLogin polls tidal until the login has been completed, and then replaces the tokens of the
+// client with the ones of the login, storing them along with the client they were issued to
+func (c *Client) CompleteDeviceLogin(ctx context.Context, login *DeviceLogin) error {
+	interval := login.interval
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+
+		if time.Now().After(login.ExpiresAt) {
+			return ErrDeviceLoginExpired
+		}
+
+		tokens, err := c.requestTokens(login.clientId, login.clientSecret, url.Values{
+			"client_id":   {login.clientId},
+			"device_code": {login.deviceCode},
+			"grant_type":  {deviceCodeGrantType},
+			"scope":       {deviceLoginScope},
+		})
+
+		var tokenErr *TokenError
+		if errors.As(err, &tokenErr) {
+			switch tokenErr.Code {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += deviceSlowDownStep
+				continue
+			case "expired_token":
+				return ErrDeviceLoginExpired
+			case "access_denied":
+				return ErrDeviceLoginDenied
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		tokens.ClientId = login.clientId
+		tokens.ClientSecret = login.clientSecret
+		c.tokens.set(tokens)
+
+		return nil
+	}
+}
@@ -0,0 +1,83 @@
+package tidal
+
+import (
+	"context"
+	"errors"
+)
+
+// StartDeviceLogin starts a device login and completes it in the background, replacing the login that was
+// in progress. Its progress can be followed with DeviceLogin.
+func (s *Service) StartDeviceLogin(clientId string, clientSecret string) (DeviceLogin, error) {
+	login, err := s.client.StartDeviceLogin(clientId, clientSecret)
+	if err != nil {
+		return DeviceLogin{}, err
+	}
+
+	ctx, cancel := context.WithDeadline(context.Background(), login.ExpiresAt)
+
+	s.loginMu.Lock()
+	if s.cancelLogin != nil {
+		s.cancelLogin()
+	}
+	s.login = login
+	s.cancelLogin = cancel
+	s.loginMu.Unlock()
+
+	s.logger.Info("started tidal device login",
+		"userCode", login.UserCode,
+		"expiresAt", login.ExpiresAt)
+
+	s.logins.Add(1)
+	go func() {
+		defer s.logins.Done()
+		defer cancel()
+
+		go func() {
+			select {
+			case <-s.stop:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		err := s.client.CompleteDeviceLogin(ctx, login)
+		s.finishDeviceLogin(login, err)
+	}()
+
+	return *login, nil
+}
+
+func (s *Service) finishDeviceLogin(login *DeviceLogin, err error) {
+	s.loginMu.Lock()
+	defer s.loginMu.Unlock()
+
+	switch {
+	case err == nil:
+		login.State = DeviceLoginCompleted
+		s.logger.Info("logged in to tidal")
+	case errors.Is(err, ErrDeviceLoginExpired), errors.Is(err, context.DeadlineExceeded):
+		login.State = DeviceLoginExpired
+		login.Error = ErrDeviceLoginExpired.Error()
+	case errors.Is(err, context.Canceled) && s.login != login:
+		login.State = DeviceLoginFailed
+		login.Error = "replaced by a newer login"
+	default:
+		login.State = DeviceLoginFailed
+		login.Error = err.Error()
+
+		s.logger.Error("tidal device login failed",
+			"error", err.Error())
+	}
+}
+
+// DeviceLogin returns the latest device login, if one has been started
+func (s *Service) DeviceLogin() (DeviceLogin, bool) {
+	s.loginMu.Lock()
+	defer s.loginMu.Unlock()
+
+	if s.login == nil {
+		return DeviceLogin{}, false
+	}
+
+	return *s.login, true
+}
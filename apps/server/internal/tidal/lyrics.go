@@ -0,0 +1,139 @@
+package tidal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/lyrics"
+	"github.com/altierawr/oto/internal/types"
+)
+
+var ErrNoLyrics = errors.New("track has no lyrics")
+
+// noLyricsRecheckInterval is how long Tidal isn't asked again for the lyrics of a track it had none for
+const noLyricsRecheckInterval = 7 * 24 * time.Hour
+
+type TidalLyricsResponse struct {
+	TrackId        int     `json:"trackId"`
+	LyricsProvider *string `json:"lyricsProvider"`
+	Lyrics         *string `json:"lyrics"`
+	Subtitles      *string `json:"subtitles"`
+	IsRightToLeft  bool    `json:"isRightToLeft"`
+}
+
+// GetLyrics returns the lyrics of the track from the database, fetching them from Tidal if they aren't stored
+// yet. It returns ErrNoLyrics if neither Tidal nor a user has provided any.
+func (s *Service) GetLyrics(id int64, region Region) (*types.TidalLyrics, error) {
+	stored, err := s.db.GetTidalTrackLyrics(id)
+	if err != nil && !errors.Is(err, database.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	isStale := stored != nil && stored.Source == database.LyricsSourceNone &&
+		time.Since(time.Unix(stored.FetchedAt, 0)) > noLyricsRecheckInterval
+
+	if stored == nil || isStale {
+		stored, err = s.client.GetLyrics(id, region)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.db.UpsertTidalTrackLyrics(stored)
+		if err != nil {
+			s.logger.Error("couldn't store tidal lyrics",
+				"error", err.Error(),
+				"trackId", id)
+		}
+	}
+
+	return StoredLyrics(stored)
+}
+
+// GetLyrics fetches the lyrics of the track. A track without lyrics is returned with LyricsSourceNone so that
+// it can be stored as such.
+func (c *Client) GetLyrics(id int64, region Region) (*database.TrackLyrics, error) {
+	region = c.resolveRegion(region)
+
+	q := url.Values{}
+	q.Set("countryCode", region.CountryCode)
+	q.Set("locale", region.Locale)
+
+	res, err := c.do(http.MethodGet, fmt.Sprintf("/v1/tracks/%d/lyrics", id), q, nil)
+	if err != nil {
+		// tidal answers with not found for tracks without lyrics
+		if errors.Is(err, database.ErrRecordNotFound) {
+			return &database.TrackLyrics{
+				TrackId: id,
+				Source:  database.LyricsSourceNone,
+			}, nil
+		}
+
+		return nil, err
+	}
+
+	var lyricsResp TidalLyricsResponse
+	if err = json.Unmarshal(res.body, &lyricsResp); err != nil {
+		return nil, err
+	}
+
+	stored := &database.TrackLyrics{
+		TrackId:       id,
+		Source:        database.LyricsSourceTidal,
+		Provider:      lyricsResp.LyricsProvider,
+		Text:          nonEmpty(lyricsResp.Lyrics),
+		Subtitles:     nonEmpty(lyricsResp.Subtitles),
+		IsRightToLeft: lyricsResp.IsRightToLeft,
+	}
+
+	if stored.Text == nil && stored.Subtitles == nil {
+		stored.Source = database.LyricsSourceNone
+	}
+
+	return stored, nil
+}
+
+// StoredLyrics parses stored lyrics, preferring the synced ones. It returns ErrNoLyrics for a track that is
+// known to have none.
+func StoredLyrics(stored *database.TrackLyrics) (*types.TidalLyrics, error) {
+	if stored.Source == database.LyricsSourceNone {
+		return nil, ErrNoLyrics
+	}
+
+	result := &types.TidalLyrics{
+		TrackId:       int(stored.TrackId),
+		Source:        stored.Source,
+		Provider:      stored.Provider,
+		IsRightToLeft: stored.IsRightToLeft,
+	}
+
+	if stored.Subtitles != nil {
+		lines, err := lyrics.ParseLRC(*stored.Subtitles)
+		if err == nil {
+			result.IsSynced = true
+			result.Lines = lines
+			return result, nil
+		}
+	}
+
+	if stored.Text == nil {
+		return nil, ErrNoLyrics
+	}
+
+	result.Lines = lyrics.PlainLines(*stored.Text)
+
+	return result, nil
+}
+
+func nonEmpty(s *string) *string {
+	if s == nil || strings.TrimSpace(*s) == "" {
+		return nil
+	}
+
+	return s
+}
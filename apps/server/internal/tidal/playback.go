@@ -0,0 +1,173 @@
+package tidal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/altierawr/oto/internal/types"
+)
+
+// Quality tiers of the Tidal playback endpoint
+const (
+	QualityHiResLossless = "HI_RES_LOSSLESS"
+	QualityLossless      = "LOSSLESS"
+	QualityHigh          = "HIGH"
+	QualityLow           = "LOW"
+)
+
+// Qualities are the quality tiers from highest to lowest
+var Qualities = []string{QualityHiResLossless, QualityLossless, QualityHigh, QualityLow}
+
+const (
+	manifestMimeTypeBTS  = "application/vnd.tidal.bts"
+	manifestMimeTypeDASH = "application/dash+xml"
+)
+
+var (
+	ErrUnknownQuality      = errors.New("unknown tidal quality")
+	ErrPlaybackUnavailable = errors.New("track isn't available for playback in any quality")
+	ErrUnsupportedManifest = errors.New("unsupported tidal manifest")
+	ErrEncryptedManifest   = errors.New("tidal manifest is encrypted")
+	ErrSegmentedSource     = errors.New("source is split into segments")
+)
+
+// Source is where the audio of a track can be read from, independent of the manifest format Tidal
+// described it with
+type Source struct {
+	// Quality tier that Tidal returned, might be lower than the requested one
+	Quality    string `json:"quality"`
+	MimeType   string `json:"mimeType,omitempty"`
+	Codecs     string `json:"codecs,omitempty"`
+	BitDepth   int    `json:"bitDepth,omitempty"`
+	SampleRate int    `json:"sampleRate,omitempty"`
+	// URLs of the audio. A single URL is the whole file, several are an initialization segment followed
+	// by media segments that make up the file when concatenated in order.
+	URLs []string `json:"-"`
+}
+
+// Input returns the source as an ffmpeg input, segmented sources are read with the concat protocol
+func (s *Source) Input() string {
+	if len(s.URLs) == 1 {
+		return s.URLs[0]
+	}
+
+	return "concat:" + strings.Join(s.URLs, "|")
+}
+
+// URL returns the url of a source that is a single file
+func (s *Source) URL() (string, error) {
+	if len(s.URLs) != 1 {
+		return "", ErrSegmentedSource
+	}
+
+	return s.URLs[0], nil
+}
+
+// GetSongSource returns the source of the track in the given quality tier. If tidal answers that the track
+// can't be played in that tier, lower tiers are tried in order. Any other error, like the track not existing,
+// is returned right away.
+func (c *Client) GetSongSource(id int64, quality string, region Region) (*Source, error) {
+	start := slices.Index(Qualities, quality)
+	if start == -1 {
+		return nil, ErrUnknownQuality
+	}
+
+	var errs []error
+	for _, tier := range Qualities[start:] {
+		source, err := c.getSongSourceInQuality(id, tier, region)
+		if err == nil {
+			return source, nil
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Kind != ErrorQualityUnavailable {
+			return nil, err
+		}
+
+		errs = append(errs, fmt.Errorf("%s: %w", tier, err))
+	}
+
+	return nil, fmt.Errorf("%w: %w", ErrPlaybackUnavailable, errors.Join(errs...))
+}
+
+func (c *Client) getSongSourceInQuality(id int64, quality string, region Region) (*Source, error) {
+	var playback types.TidalPlaybackInfo
+
+	q := url.Values{}
+	q.Set("audioquality", quality)
+	q.Set("playbackmode", "STREAM")
+	q.Set("assetpresentation", "FULL")
+	q.Set("countryCode", c.resolveRegion(region).CountryCode)
+
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+
+	res, err := c.do(http.MethodGet, fmt.Sprintf("/v1/tracks/%d/playbackinfopostpaywall/v4", id), q, header)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := res.header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "application/json") {
+		return nil, ErrInvalidTidalResponseType
+	}
+
+	if err := json.Unmarshal(res.body, &playback); err != nil {
+		return nil, err
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(playback.Manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	var source *Source
+	switch playback.ManifestMimeType {
+	case manifestMimeTypeBTS, "":
+		source, err = parseBTSManifest(decoded)
+	case manifestMimeTypeDASH:
+		source, err = parseDASHManifest(decoded)
+	default:
+		err = fmt.Errorf("%w: %s", ErrUnsupportedManifest, playback.ManifestMimeType)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	source.Quality = playback.AudioQuality
+	if source.Quality == "" {
+		source.Quality = quality
+	}
+	source.BitDepth = playback.BitDepth
+	source.SampleRate = playback.SampleRate
+
+	return source, nil
+}
+
+// parseBTSManifest parses the JSON manifest that lists the urls of the whole file
+func parseBTSManifest(decoded []byte) (*Source, error) {
+	var manifest types.ManifestData
+	if err := json.Unmarshal(decoded, &manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.EncryptionType != "" && manifest.EncryptionType != "NONE" {
+		return nil, ErrEncryptedManifest
+	}
+
+	if len(manifest.Urls) == 0 {
+		return nil, fmt.Errorf("%w: manifest has no urls", ErrUnsupportedManifest)
+	}
+
+	return &Source{
+		MimeType: manifest.MimeType,
+		Codecs:   manifest.Codecs,
+		URLs:     manifest.Urls[:1],
+	}, nil
+}
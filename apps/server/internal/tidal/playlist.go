@@ -0,0 +1,386 @@
+package tidal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/types"
+	"github.com/google/uuid"
+)
+
+const (
+	playlistPageSize = 100 // Max limit = 100
+	// playlistSyncInterval is how often synced playlists are fetched again
+	playlistSyncInterval = 6 * time.Hour
+	// maxPlaylistNameLength is the longest name a local playlist can have
+	maxPlaylistNameLength = 50
+)
+
+// PlaylistUUIDRX matches the uuids of tidal playlists
+var PlaylistUUIDRX = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type TidalPlaylistResponse struct {
+	UUID                string   `json:"uuid"`
+	Created             *string  `json:"created"`
+	Description         *string  `json:"description"`
+	Popularity          *float64 `json:"popularity"`
+	Duration            *int     `json:"duration"`
+	LastItemAddedAt     *string  `json:"lastItemAddedAt"`
+	LastUpdated         *string  `json:"lastUpdated"`
+	NumberOfAudioTracks *int     `json:"numberOfAudioTracks"`
+	NumberOfTracks      *int     `json:"numberOfTracks"`
+	PromotedArtists     []struct {
+		ID      int     `json:"id"`
+		Name    string  `json:"name"`
+		Picture *string `json:"picture"`
+	} `json:"promotedArtists"`
+	PublicPlaylist *bool   `json:"publicPlaylist"`
+	Title          string  `json:"title"`
+	SquareImage    *string `json:"squareImage"`
+	Type           *string `json:"type"`
+}
+
+type TidalPlaylistItemsResponse struct {
+	TotalNumberOfItems int `json:"totalNumberOfItems"`
+	Items              []struct {
+		Type string             `json:"type"` // track, video
+		Item TidalTrackResponse `json:"item"`
+	} `json:"items"`
+}
+
+type PlaylistTracksResult struct {
+	Items              []types.TidalSong `json:"items"`
+	TotalNumberOfItems int               `json:"totalNumberOfItems"`
+	MaybeHasMorePages  bool              `json:"maybeHasMorePages"`
+}
+
+func (c *Client) GetPlaylist(playlistUUID string, region Region) (*types.TidalPlaylist, error) {
+	region = c.resolveRegion(region)
+
+	q := url.Values{}
+	q.Set("countryCode", region.CountryCode)
+	q.Set("locale", region.Locale)
+
+	body, err := c.getCatalog(ResourcePlaylist, fmt.Sprintf("/v1/playlists/%s", url.PathEscape(playlistUUID)), q)
+	if err != nil {
+		return nil, err
+	}
+
+	var playlistResp TidalPlaylistResponse
+	if err = json.Unmarshal(body, &playlistResp); err != nil {
+		return nil, err
+	}
+
+	playlist := types.TidalPlaylist{
+		UUID:                playlistResp.UUID,
+		Created:             playlistResp.Created,
+		Description:         playlistResp.Description,
+		Popularity:          playlistResp.Popularity,
+		Duration:            playlistResp.Duration,
+		LastItemAddedAt:     playlistResp.LastItemAddedAt,
+		LastUpdated:         playlistResp.LastUpdated,
+		NumberOfAudioTracks: playlistResp.NumberOfAudioTracks,
+		NumberOfTracks:      playlistResp.NumberOfTracks,
+		PromotedArtists:     []types.TidalArtist{},
+		PublicPlaylist:      playlistResp.PublicPlaylist,
+		Title:               playlistResp.Title,
+		SquareImage:         playlistResp.SquareImage,
+		Type:                playlistResp.Type,
+	}
+
+	for _, artistItem := range playlistResp.PromotedArtists {
+		playlist.PromotedArtists = append(playlist.PromotedArtists, types.TidalArtist{
+			ID:      artistItem.ID,
+			Name:    artistItem.Name,
+			Picture: artistItem.Picture,
+		})
+	}
+
+	return &playlist, nil
+}
+
+// GetPlaylistTracks fetches a page of the tracks of the playlist. Videos in the playlist are left out, so a
+// page can have fewer tracks than the page size even when there are more pages.
+func (c *Client) GetPlaylistTracks(playlistUUID string, page int, region Region) (*PlaylistTracksResult, error) {
+	region = c.resolveRegion(region)
+
+	q := url.Values{}
+	q.Set("countryCode", region.CountryCode)
+	q.Set("locale", region.Locale)
+	q.Set("limit", fmt.Sprintf("%d", playlistPageSize))
+	q.Set("offset", fmt.Sprintf("%d", page*playlistPageSize))
+
+	body, err := c.getCatalog(ResourcePlaylist, fmt.Sprintf("/v1/playlists/%s/items", url.PathEscape(playlistUUID)), q)
+	if err != nil {
+		return nil, err
+	}
+
+	var itemsResp TidalPlaylistItemsResponse
+	if err = json.Unmarshal(body, &itemsResp); err != nil {
+		return nil, err
+	}
+
+	tracks := []types.TidalSong{}
+	for _, item := range itemsResp.Items {
+		if item.Type != "track" {
+			continue
+		}
+
+		tracks = append(tracks, item.Item.toSong())
+	}
+
+	return &PlaylistTracksResult{
+		Items:              tracks,
+		TotalNumberOfItems: itemsResp.TotalNumberOfItems,
+		MaybeHasMorePages:  (page+1)*playlistPageSize < itemsResp.TotalNumberOfItems,
+	}, nil
+}
+
+// GetPlaylist fetches the playlist
+func (s *Service) GetPlaylist(playlistUUID string, region Region) (*types.TidalPlaylist, error) {
+	return s.client.GetPlaylist(playlistUUID, region)
+}
+
+// GetPlaylistTracks fetches a page of the tracks of the playlist and stores them
+func (s *Service) GetPlaylistTracks(playlistUUID string, page int, region Region) (*PlaylistTracksResult, error) {
+	result, err := s.client.GetPlaylistTracks(playlistUUID, page, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalTracks(result.Items, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal playlist tracks",
+			"error", err.Error(),
+			"uuid", playlistUUID)
+	}
+
+	return result, nil
+}
+
+func (s *Service) getAllPlaylistTracks(playlistUUID string, region Region) ([]types.TidalSong, error) {
+	tracks := []types.TidalSong{}
+
+	for page := 0; ; page++ {
+		result, err := s.GetPlaylistTracks(playlistUUID, page, region)
+		if err != nil {
+			return nil, err
+		}
+
+		tracks = append(tracks, result.Items...)
+
+		if !result.MaybeHasMorePages {
+			return tracks, nil
+		}
+	}
+}
+
+// ImportPlaylist copies the playlist into a new playlist of the user. A synced playlist is kept the same as
+// the tidal one, which means that tracks added to it locally are removed on the next sync.
+func (s *Service) ImportPlaylist(
+	userId uuid.UUID,
+	playlistUUID string,
+	sync bool,
+	region Region,
+) (*database.UserPlaylistSummary, *database.TidalPlaylistImport, error) {
+	playlist, err := s.GetPlaylist(playlistUUID, region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tracks, err := s.getAllPlaylistTracks(playlistUUID, region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summary, err := s.db.CreatePlaylist(userId, playlistName(playlist.Title))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	playlistImport := &database.TidalPlaylistImport{
+		PlaylistId: summary.ID,
+		UserId:     userId,
+		TidalUUID:  playlistUUID,
+		Sync:       sync,
+	}
+
+	err = s.addPlaylistTracks(userId, summary.ID, tracks)
+	if err == nil {
+		err = s.db.InsertTidalPlaylistImport(playlistImport)
+	}
+
+	if err != nil {
+		// a half imported playlist is of no use
+		deleteErr := s.db.DeletePlaylist(userId, summary.ID)
+		if deleteErr != nil {
+			s.logger.Error("couldn't delete partially imported playlist",
+				"error", deleteErr.Error(),
+				"playlistId", summary.ID)
+		}
+
+		return nil, nil, err
+	}
+
+	imported, err := s.db.GetUserPlaylist(userId, summary.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	summary = &database.UserPlaylistSummary{
+		ID:             imported.ID,
+		Name:           imported.Name,
+		NumberOfTracks: imported.NumberOfTracks,
+		Duration:       imported.Duration,
+		CoverURLs:      imported.CoverURLs,
+	}
+
+	return summary, playlistImport, nil
+}
+
+// addPlaylistTracks adds the tracks to the local playlist, skipping the ones that are already in it since tidal
+// playlists can have the same track more than once
+func (s *Service) addPlaylistTracks(userId uuid.UUID, playlistId int64, tracks []types.TidalSong) error {
+	for _, track := range tracks {
+		err := s.db.AddTrackToPlaylist(userId, playlistId, &track)
+		if err != nil && !errors.Is(err, database.ErrDuplicatePlaylistTrack) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) syncPlaylists() {
+	if !s.client.LoggedIn() {
+		return
+	}
+
+	imports, err := s.db.GetTidalPlaylistImportsToSync(time.Now().Add(-playlistSyncInterval))
+	if err != nil {
+		s.logger.Error("couldn't get tidal playlists to sync",
+			"error", err.Error())
+		return
+	}
+
+	for _, playlistImport := range imports {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		if err := s.limiter.Wait(context.Background()); err != nil {
+			s.logger.Error("limiter fail",
+				"error", err.Error())
+			return
+		}
+
+		err := s.syncPlaylist(playlistImport)
+		if err != nil {
+			s.logger.Error("couldn't sync tidal playlist",
+				"error", err.Error(),
+				"playlistId", playlistImport.PlaylistId,
+				"uuid", playlistImport.TidalUUID)
+
+			// playlists that failed to sync are tried again on the next run
+			continue
+		}
+
+		err = s.db.SetTidalPlaylistSynced(playlistImport.PlaylistId)
+		if err != nil {
+			s.logger.Error("couldn't set tidal playlist as synced",
+				"error", err.Error(),
+				"playlistId", playlistImport.PlaylistId)
+		}
+	}
+}
+
+// syncPlaylist makes the local playlist have the same tracks as the tidal one
+func (s *Service) syncPlaylist(playlistImport database.TidalPlaylistImport) error {
+	user, err := s.db.GetUserById(playlistImport.UserId)
+	if err != nil {
+		return err
+	}
+
+	tracks, err := s.getAllPlaylistTracks(playlistImport.TidalUUID, UserRegion(user))
+	if err != nil {
+		return err
+	}
+
+	local, err := s.db.GetUserPlaylist(playlistImport.UserId, playlistImport.PlaylistId)
+	if err != nil {
+		return err
+	}
+
+	trackIds := make(map[int]struct{}, len(tracks))
+	order := []int{}
+	for _, track := range tracks {
+		// tidal playlists can have the same track more than once, the local one has it where it first appears
+		if _, found := trackIds[track.ID]; !found {
+			order = append(order, track.ID)
+		}
+		trackIds[track.ID] = struct{}{}
+	}
+
+	localTrackIds := make(map[int]struct{}, len(local.Tracks))
+	localOrder := make([]int, 0, len(local.Tracks))
+	removed := 0
+	for _, track := range local.Tracks {
+		localTrackIds[track.ID] = struct{}{}
+		localOrder = append(localOrder, track.ID)
+
+		if _, found := trackIds[track.ID]; !found {
+			removed++
+		}
+	}
+
+	added := 0
+	for _, id := range order {
+		if _, found := localTrackIds[id]; !found {
+			added++
+		}
+	}
+
+	if slices.Equal(order, localOrder) {
+		return nil
+	}
+
+	// the tracks are written again in the tidal order, so that new ones end up where they are in tidal and
+	// moved ones are moved locally as well
+	err = s.db.SetPlaylistTracks(playlistImport.UserId, playlistImport.PlaylistId, tracks)
+	if err != nil {
+		return err
+	}
+
+	s.logger.Info("synced tidal playlist",
+		"playlistId", playlistImport.PlaylistId,
+		"uuid", playlistImport.TidalUUID,
+		"added", added,
+		"removed", removed)
+
+	return nil
+}
+
+// playlistName fits the title of a tidal playlist into the name of a local one
+func playlistName(title string) string {
+	name := strings.TrimSpace(title)
+	if name == "" {
+		return "Tidal playlist"
+	}
+
+	if utf8.RuneCountInString(name) > maxPlaylistNameLength {
+		name = strings.TrimSpace(string([]rune(name)[:maxPlaylistNameLength]))
+	}
+
+	return name
+}
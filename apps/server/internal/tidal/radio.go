@@ -0,0 +1,60 @@
+package tidal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/altierawr/oto/internal/types"
+)
+
+const radioSize = 100 // Max limit = 100
+
+type TidalTrackRadioResponse struct {
+	Items []TidalTrackResponse `json:"items"`
+}
+
+// GetTrackRadio fetches the tracks of tidal's radio mix of the track
+func (c *Client) GetTrackRadio(id int64, region Region) ([]types.TidalSong, error) {
+	region = c.resolveRegion(region)
+
+	q := url.Values{}
+	q.Set("countryCode", region.CountryCode)
+	q.Set("locale", region.Locale)
+	q.Set("limit", fmt.Sprintf("%d", radioSize))
+	q.Set("offset", "0")
+
+	body, err := c.getCatalog(ResourceRadio, fmt.Sprintf("/v1/tracks/%d/radio", id), q)
+	if err != nil {
+		return nil, err
+	}
+
+	var radioResp TidalTrackRadioResponse
+	if err = json.Unmarshal(body, &radioResp); err != nil {
+		return nil, err
+	}
+
+	tracks := []types.TidalSong{}
+	for _, item := range radioResp.Items {
+		tracks = append(tracks, item.toSong())
+	}
+
+	return tracks, nil
+}
+
+// GetTrackRadio fetches the tracks of tidal's radio mix of the track and stores them
+func (s *Service) GetTrackRadio(id int64, region Region) ([]types.TidalSong, error) {
+	tracks, err := s.client.GetTrackRadio(id, region)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.InsertTidalTracks(tracks, nil)
+	if err != nil {
+		s.logger.Error("couldn't insert tidal track radio tracks",
+			"error", err.Error(),
+			"trackId", id)
+	}
+
+	return tracks, nil
+}
@@ -0,0 +1,291 @@
+package tidal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/altierawr/oto/internal/database"
+)
+
+const (
+	maxAttempts    = 3
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 5 * time.Second
+	// maxRetryAfter is the longest wait asked for by a rate limited response that is waited out before trying
+	// again, longer ones fail the call right away
+	maxRetryAfter = 10 * time.Second
+)
+
+// subStatusAssetNotReady is the sub status of the error tidal answers with when a track can't be played in
+// the asked for quality
+const subStatusAssetNotReady = 4005
+
+// ErrUnavailable is matched by the errors of calls that failed because tidal couldn't be reached, was failing,
+// was rate limiting or wouldn't accept the tokens, as opposed to calls that tidal answered with an error
+var ErrUnavailable = errors.New("tidal is unavailable")
+
+type ErrorKind string
+
+const (
+	ErrorNotFound    ErrorKind = "not found"
+	ErrorRateLimited ErrorKind = "rate limited"
+	ErrorAuthExpired ErrorKind = "auth expired"
+	ErrorServer      ErrorKind = "server error"
+	ErrorNetwork     ErrorKind = "network failure"
+	ErrorCircuitOpen ErrorKind = "circuit open"
+	ErrorUnexpected  ErrorKind = "unexpected status"
+	// ErrorQualityUnavailable is a track that exists but can't be played in the asked for quality
+	ErrorQualityUnavailable ErrorKind = "quality unavailable"
+)
+
+// APIError is a failed call to the API. Not found errors match database.ErrRecordNotFound and the errors of an
+// unavailable tidal match ErrUnavailable.
+type APIError struct {
+	Kind       ErrorKind
+	StatusCode int
+	// SubStatus is the tidal specific error code from the response body, zero if there wasn't any
+	SubStatus int
+	// RetryAfter is how long tidal asked to wait before trying again, or how long until the circuit closes
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("tidal %s: %s", e.Kind, e.Err.Error())
+	case e.SubStatus != 0:
+		return fmt.Sprintf("tidal %s: status %d, sub status %d", e.Kind, e.StatusCode, e.SubStatus)
+	case e.StatusCode != 0:
+		return fmt.Sprintf("tidal %s: status %d", e.Kind, e.StatusCode)
+	default:
+		return fmt.Sprintf("tidal %s", e.Kind)
+	}
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case database.ErrRecordNotFound:
+		return e.Kind == ErrorNotFound
+	case ErrUnavailable:
+		switch e.Kind {
+		case ErrorRateLimited, ErrorAuthExpired, ErrorServer, ErrorNetwork, ErrorCircuitOpen:
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *APIError) retryable() bool {
+	switch e.Kind {
+	case ErrorRateLimited:
+		return e.RetryAfter <= maxRetryAfter
+	case ErrorServer, ErrorNetwork:
+		return true
+	default:
+		return false
+	}
+}
+
+// outage is whether the error counts towards opening the circuit
+func (e *APIError) outage() bool {
+	return e.Kind == ErrorServer || e.Kind == ErrorNetwork
+}
+
+// RetryAfter returns how long to wait before trying again after the error, zero if it isn't known
+func RetryAfter(err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter
+	}
+
+	return 0
+}
+
+type apiResponse struct {
+	header http.Header
+	body   []byte
+}
+
+// do calls the API with a valid access token and returns the response if it's successful. Failures that can
+// go away on their own are retried with a jittered backoff, and an expired access token is refreshed once. The
+// returned errors are *APIError, apart from ones of creating the request.
+func (c *Client) do(method string, path string, query url.Values, header http.Header) (*apiResponse, error) {
+	trial, retryAfter, allowed := c.breaker.allow()
+	if !allowed {
+		return nil, &APIError{
+			Kind:       ErrorCircuitOpen,
+			RetryAfter: retryAfter,
+		}
+	}
+
+	res, err := c.doWithRetries(method, path, query, header)
+
+	// a call only counts as failed once its retries have failed too
+	var apiErr *APIError
+	c.breaker.record(trial, errors.As(err, &apiErr) && apiErr.outage())
+
+	return res, err
+}
+
+func (c *Client) doWithRetries(method string, path string, query url.Values, header http.Header) (*apiResponse, error) {
+	refreshedToken := false
+
+	for attempt := 1; ; attempt++ {
+		res, accessToken, err := c.attempt(method, path, query, header)
+		if err == nil {
+			return res, nil
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			return nil, err
+		}
+
+		// the token can expire or be revoked before the time it was said to expire at
+		if apiErr.Kind == ErrorAuthExpired && accessToken != "" && !refreshedToken {
+			c.tokens.invalidate(accessToken)
+			refreshedToken = true
+			attempt--
+			continue
+		}
+
+		if !apiErr.retryable() || attempt >= maxAttempts {
+			return nil, apiErr
+		}
+
+		delay := retryDelay(attempt)
+		if apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+
+		c.logger.Warn("tidal call failed, retrying",
+			"error", apiErr.Error(),
+			"path", path,
+			"attempt", attempt,
+			"delay", delay)
+
+		time.Sleep(delay)
+	}
+}
+
+// attempt calls the API once and returns the access token it was called with
+func (c *Client) attempt(method string, path string, query url.Values, header http.Header) (*apiResponse, string, error) {
+	accessToken, err := c.tokens.accessToken()
+	if err != nil {
+		return nil, "", tokenFailure(err)
+	}
+
+	req, err := http.NewRequest(method, resolveURL(c.apiBaseURL, path, query), nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, accessToken, &APIError{Kind: ErrorNetwork, Err: err}
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, accessToken, &APIError{Kind: ErrorNetwork, Err: err}
+	}
+
+	if res.StatusCode == http.StatusOK {
+		return &apiResponse{header: res.Header, body: body}, accessToken, nil
+	}
+
+	apiErr := &APIError{
+		StatusCode: res.StatusCode,
+		SubStatus:  parseSubStatus(body),
+	}
+	switch {
+	case apiErr.SubStatus == subStatusAssetNotReady:
+		apiErr.Kind = ErrorQualityUnavailable
+	case res.StatusCode == http.StatusNotFound:
+		apiErr.Kind = ErrorNotFound
+	case res.StatusCode == http.StatusTooManyRequests:
+		apiErr.Kind = ErrorRateLimited
+		apiErr.RetryAfter = parseRetryAfter(res.Header.Get("Retry-After"))
+	case res.StatusCode == http.StatusUnauthorized:
+		apiErr.Kind = ErrorAuthExpired
+	case res.StatusCode >= 500:
+		apiErr.Kind = ErrorServer
+	default:
+		apiErr.Kind = ErrorUnexpected
+	}
+
+	return nil, accessToken, apiErr
+}
+
+// tokenFailure sorts out why a valid access token couldn't be had
+func tokenFailure(err error) error {
+	var tokenErr *TokenError
+	switch {
+	case errors.As(err, &tokenErr) && tokenErr.StatusCode >= 500:
+		return &APIError{Kind: ErrorServer, StatusCode: tokenErr.StatusCode, Err: err}
+	case errors.As(err, &tokenErr), errors.Is(err, ErrNoRefreshToken):
+		return &APIError{Kind: ErrorAuthExpired, Err: err}
+	default:
+		return &APIError{Kind: ErrorNetwork, Err: err}
+	}
+}
+
+// retryDelay returns a random delay between half and all of an exponentially growing maximum, so that calls
+// that failed at the same time don't retry at the same time
+func retryDelay(attempt int) time.Duration {
+	maxDelay := retryBaseDelay << (attempt - 1)
+	if maxDelay > retryMaxDelay {
+		maxDelay = retryMaxDelay
+	}
+
+	return maxDelay/2 + rand.N(maxDelay/2)
+}
+
+// parseSubStatus reads the sub status from the body of an error response, it's zero if the body doesn't have one
+func parseSubStatus(body []byte) int {
+	var res struct {
+		SubStatus int `json:"subStatus"`
+	}
+
+	if err := json.Unmarshal(body, &res); err != nil {
+		return 0
+	}
+
+	return res.SubStatus
+}
+
+// parseRetryAfter reads a Retry-After header, which is either a number of seconds or a date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+
+	return 0
+}
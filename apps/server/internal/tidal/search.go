@@ -3,12 +3,8 @@ package tidal
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 
-	"github.com/altierawr/oto/internal/database"
 	"github.com/altierawr/oto/internal/types"
 )
 
@@ -90,48 +86,22 @@ type TidalSearchResponse struct {
 	} `json:"topHits"`
 }
 
-func (s *Service) Search(query string) (*types.TidalSearch, error) {
-	err := refreshTokens()
-	if err != nil {
-		return nil, err
-	}
-
+func (c *Client) Search(query string, region Region) (*types.TidalSearch, error) {
 	if query == "" {
 		return nil, errors.New("query is missing")
 	}
 
-	tidalURL := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   "/v2/search",
-	}
-
-	q := tidalURL.Query()
+	q := url.Values{}
 	q.Set("query", query)
 	q.Set("limit", "100") // Max limit = 100
 	q.Set("offset", "0")
 	q.Set("types", "ARTISTS,ALBUMS,TRACKS,PLAYLISTS")
-	q.Set("countryCode", "US")
+	region = c.resolveRegion(region)
+	q.Set("countryCode", region.CountryCode)
+	q.Set("locale", region.Locale)
 	q.Set("deviceType", "BROWSER")
-	tidalURL.RawQuery = q.Encode()
-
-	req, _ := http.NewRequest(http.MethodGet, tidalURL.String(), nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
-
-	resp, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		return nil, err
-	}
-
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, database.ErrRecordNotFound
-	}
-
-	body, err := io.ReadAll(resp.Body)
 
+	body, err := c.getCatalog(ResourceSearch, "/v2/search", q)
 	if err != nil {
 		return nil, err
 	}
@@ -267,23 +237,5 @@ func (s *Service) Search(query string) (*types.TidalSearch, error) {
 		})
 	}
 
-	err = s.db.InsertTidalArtists(result.Artists, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal artists in search",
-			"error", err.Error())
-	}
-
-	err = s.db.InsertTidalAlbums(result.Albums, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal albums in search",
-			"error", err.Error())
-	}
-
-	err = s.db.InsertTidalTracks(result.Songs, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal tracks in search",
-			"error", err.Error())
-	}
-
 	return &result, nil
 }
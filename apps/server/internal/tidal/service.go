@@ -3,31 +3,45 @@ package tidal
 import (
 	"context"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/altierawr/oto/internal/database"
 	"golang.org/x/time/rate"
 )
 
+// Service fetches the catalog through the client and stores what was fetched in the database
 type Service struct {
 	db     *database.DB
 	logger *slog.Logger
+	client *Client
 
 	limiter *rate.Limiter
 	stop    chan bool
 	done    chan bool
+
+	loginMu     sync.Mutex
+	login       *DeviceLogin
+	cancelLogin context.CancelFunc
+	logins      sync.WaitGroup
 }
 
-func New(db *database.DB, logger *slog.Logger) *Service {
+func New(db *database.DB, logger *slog.Logger, client *Client) *Service {
 	return &Service{
 		db:      db,
 		logger:  logger,
+		client:  client,
 		limiter: rate.NewLimiter(rate.Every(1000*time.Millisecond), 1),
 		stop:    make(chan bool),
 		done:    make(chan bool),
 	}
 }
 
+// Client returns the client the service fetches the catalog with
+func (s *Service) Client() *Client {
+	return s.client
+}
+
 func (s *Service) RunBackground() {
 	defer close(s.done)
 
@@ -35,6 +49,8 @@ func (s *Service) RunBackground() {
 	defer ticker.Stop()
 
 	s.fetchMissingTidalEntries()
+	s.syncPlaylists()
+	s.purgeCache()
 
 	for {
 		select {
@@ -42,6 +58,8 @@ func (s *Service) RunBackground() {
 			return
 		case <-ticker.C:
 			s.fetchMissingTidalEntries()
+			s.syncPlaylists()
+			s.purgeCache()
 		}
 	}
 }
@@ -53,9 +71,25 @@ func (s *Service) Stop() {
 		close(s.stop)
 	}
 	<-s.done
+	s.logins.Wait()
+
+	if s.client.cache != nil {
+		s.client.cache.Wait()
+	}
+}
+
+func (s *Service) purgeCache() {
+	if s.client.cache != nil {
+		s.client.cache.Purge()
+	}
 }
 
 func (s *Service) fetchMissingTidalEntries() {
+	if !s.client.LoggedIn() {
+		s.logger.Warn("not logged in to tidal, skipping fetching missing tidal entries")
+		return
+	}
+
 	s.logger.Info("fetching missing tidal entries")
 
 	ctx := context.Background()
@@ -84,7 +118,7 @@ func (s *Service) fetchMissingTidalArtists(ctx context.Context) {
 			return
 		}
 
-		artist, err := GetArtistBasicInfo(id)
+		artist, err := s.client.GetArtistBasicInfo(id, Region{})
 		if err != nil {
 			s.logger.Error("couldn't get artist basic info",
 				"error", err.Error(),
@@ -127,7 +161,7 @@ func (s *Service) fetchMissingTidalAlbums(ctx context.Context) {
 			return
 		}
 
-		album, err := s.GetAlbum(id)
+		album, err := s.GetAlbum(id, Region{})
 		if err != nil {
 			s.logger.Error("couldn't get tidal album",
 				"error", err.Error(),
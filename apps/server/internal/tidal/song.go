@@ -1,72 +1,26 @@
 package tidal
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
-	"strings"
 
-	"github.com/altierawr/oto/internal/database"
 	"github.com/altierawr/oto/internal/types"
 )
 
-func GetSongStreamUrl(id int64) (*string, error) {
-	refreshTokens()
-
-	var playback types.PlaybackInfo
-	var manifest types.ManifestData
-
-	tidalURL := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   fmt.Sprintf("/v1/tracks/%d/playbackinfopostpaywall/v4", id),
-	}
-
-	q := tidalURL.Query()
-	q.Set("audioquality", "LOSSLESS")
-	q.Set("playbackmode", "STREAM")
-	q.Set("assetpresentation", "FULL")
-	tidalURL.RawQuery = q.Encode()
-
-	req, _ := http.NewRequest(http.MethodGet, tidalURL.String(), nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
-
-	req.Header.Set("Accept", "application/json")
-
-	res, err := http.DefaultClient.Do(req)
+// GetSongStreamUrl returns the url of the lossless file of the track, or of a lower quality if lossless isn't
+// available
+func (c *Client) GetSongStreamUrl(id int64, region Region) (*string, error) {
+	source, err := c.GetSongSource(id, QualityLossless, region)
 	if err != nil {
 		return nil, err
 	}
-	defer res.Body.Close()
 
-	contentType := res.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "application/json") {
-		return nil, ErrInvalidTidalResponseType
-	}
-
-	body, _ := io.ReadAll(res.Body)
-	if err := json.Unmarshal(body, &playback); err != nil {
-		return nil, err
-	}
-
-	decoded, err := base64.StdEncoding.DecodeString(playback.Manifest)
+	streamUrl, err := source.URL()
 	if err != nil {
 		return nil, err
 	}
 
-	if err := json.Unmarshal(decoded, &manifest); err != nil {
-		return nil, err
-	}
-
-	if len(manifest.Urls) == 0 {
-		return nil, err
-	}
-
-	streamUrl := manifest.Urls[0]
-
 	return &streamUrl, nil
 }
 
@@ -91,36 +45,14 @@ type TidalTrackResponse struct {
 	} `json:"album"`
 }
 
-func (s *Service) GetSong(id int64) (*types.TidalSong, error) {
-	err := refreshTokens()
-	if err != nil {
-		return nil, err
-	}
-
-	trackUrl := &url.URL{
-		Scheme: "https",
-		Host:   "api.tidal.com",
-		Path:   fmt.Sprintf("/v1/tracks/%d", id),
-	}
-
-	q := trackUrl.Query()
-	q.Set("countryCode", "US")
-	trackUrl.RawQuery = q.Encode()
-
-	req, _ := http.NewRequest(http.MethodGet, trackUrl.String(), nil)
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", tidalAccessToken))
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+func (c *Client) GetSong(id int64, region Region) (*types.TidalSong, error) {
+	region = c.resolveRegion(region)
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, database.ErrRecordNotFound
-	}
+	q := url.Values{}
+	q.Set("countryCode", region.CountryCode)
+	q.Set("locale", region.Locale)
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := c.getCatalog(ResourceTrack, fmt.Sprintf("/v1/tracks/%d", id), q)
 	if err != nil {
 		return nil, err
 	}
@@ -130,24 +62,30 @@ func (s *Service) GetSong(id int64) (*types.TidalSong, error) {
 		return nil, err
 	}
 
-	song := &types.TidalSong{
-		ID:              trackResp.ID,
-		Duration:        trackResp.Duration,
-		Title:           trackResp.Title,
-		ISRC:            trackResp.ISRC,
-		TrackNumber:     trackResp.TrackNumber,
-		VolumeNumber:    trackResp.VolumeNumber,
-		Explicit:        trackResp.Explicit,
-		StreamStartDate: trackResp.StreamStartDate,
+	song := trackResp.toSong()
+
+	return &song, nil
+}
+
+func (t *TidalTrackResponse) toSong() types.TidalSong {
+	song := types.TidalSong{
+		ID:              t.ID,
+		Duration:        t.Duration,
+		Title:           t.Title,
+		ISRC:            t.ISRC,
+		TrackNumber:     t.TrackNumber,
+		VolumeNumber:    t.VolumeNumber,
+		Explicit:        t.Explicit,
+		StreamStartDate: t.StreamStartDate,
 		Artists:         []types.TidalArtist{},
 		Album: &types.TidalAlbum{
-			ID:    trackResp.Album.ID,
-			Title: trackResp.Album.Title,
-			Cover: trackResp.Album.Cover,
+			ID:    t.Album.ID,
+			Title: t.Album.Title,
+			Cover: t.Album.Cover,
 		},
 	}
 
-	for _, a := range trackResp.Artists {
+	for _, a := range t.Artists {
 		song.Artists = append(song.Artists, types.TidalArtist{
 			ID:      a.ID,
 			Name:    a.Name,
@@ -155,13 +93,5 @@ func (s *Service) GetSong(id int64) (*types.TidalSong, error) {
 		})
 	}
 
-	err = s.db.InsertTidalTrack(song, nil)
-	if err != nil {
-		s.logger.Error("couldn't insert tidal track in tidal.GetSong",
-			"error", err.Error(),
-			"id", song.ID,
-			"title", song.Title)
-	}
-
-	return song, nil
+	return song
 }
@@ -0,0 +1,415 @@
+package tidal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/altierawr/oto/internal/database"
+)
+
+// tokenRefreshMargin is how long before it expires an access token is refreshed
+const tokenRefreshMargin = time.Minute
+
+var ErrNoRefreshToken = errors.New("no tidal refresh token, log in to tidal first")
+
+// TokenStore persists the tokens of a client so that refreshed and rotated tokens survive restarts
+type TokenStore interface {
+	// Load returns the stored tokens, or database.ErrRecordNotFound if none have been stored yet
+	Load() (*database.TidalTokens, error)
+	Save(tokens *database.TidalTokens) error
+}
+
+type dbTokenStore struct {
+	db      *database.DB
+	account string
+}
+
+// NewDBTokenStore returns a store that keeps the tokens of the account in the database
+func NewDBTokenStore(db *database.DB, account string) TokenStore {
+	return &dbTokenStore{
+		db:      db,
+		account: account,
+	}
+}
+
+func (s *dbTokenStore) Load() (*database.TidalTokens, error) {
+	return s.db.GetTidalTokens(s.account)
+}
+
+func (s *dbTokenStore) Save(tokens *database.TidalTokens) error {
+	return s.db.UpsertTidalTokens(s.account, tokens)
+}
+
+// TokenStatus describes the health of the tokens of a client
+type TokenStatus struct {
+	HasAccessToken  bool       `json:"hasAccessToken"`
+	HasRefreshToken bool       `json:"hasRefreshToken"`
+	ExpiresAt       *time.Time `json:"expiresAt"`
+	// Healthy is whether the access token is valid or can be refreshed, as far as is known
+	Healthy            bool       `json:"healthy"`
+	LastRefreshAt      *time.Time `json:"lastRefreshAt"`
+	LastRefreshError   string     `json:"lastRefreshError,omitempty"`
+	LastRefreshErrorAt *time.Time `json:"lastRefreshErrorAt"`
+}
+
+// TokenError is an error answered by the oauth token endpoint
+type TokenError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("tidal token endpoint returned status %d: %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+
+	return fmt.Sprintf("tidal token endpoint returned status %d: %s", e.StatusCode, e.Code)
+}
+
+type TidalTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// refreshCall is a refresh in progress that concurrent callers wait for instead of starting their own
+type refreshCall struct {
+	done chan struct{}
+	err  error
+}
+
+// tokenSource hands out a valid access token, refreshing it at most once at a time
+type tokenSource struct {
+	client *Client
+	store  TokenStore
+	logger *slog.Logger
+
+	mu                 sync.Mutex
+	tokens             database.TidalTokens
+	refreshing         *refreshCall
+	lastRefreshAt      time.Time
+	lastRefreshError   string
+	lastRefreshErrorAt time.Time
+}
+
+// newTokenSource starts from the stored tokens, which are newer than the configured ones once a refresh has
+// rotated them or a login has replaced them. The configured tokens are only used when nothing has been stored yet.
+func newTokenSource(client *Client, store TokenStore, logger *slog.Logger, configured database.TidalTokens) *tokenSource {
+	ts := &tokenSource{
+		client: client,
+		store:  store,
+		logger: logger,
+		tokens: configured,
+	}
+
+	if store == nil {
+		return ts
+	}
+
+	stored, err := store.Load()
+	switch {
+	case err == nil:
+		// tokens stored before the client was stored with them were issued to the configured client
+		if stored.ClientId == "" {
+			stored.ClientId = configured.ClientId
+			stored.ClientSecret = configured.ClientSecret
+		}
+		ts.tokens = *stored
+	case errors.Is(err, database.ErrRecordNotFound):
+	default:
+		logger.Error("couldn't load stored tidal tokens, using the configured ones",
+			"error", err.Error())
+	}
+
+	return ts
+}
+
+func (ts *tokenSource) isValid() bool {
+	return ts.tokens.AccessToken != "" && time.Until(ts.tokens.ExpiresAt) > tokenRefreshMargin
+}
+
+// accessToken returns a valid access token, refreshing it first if it's about to expire
+func (ts *tokenSource) accessToken() (string, error) {
+	ts.mu.Lock()
+	if ts.isValid() {
+		token := ts.tokens.AccessToken
+		ts.mu.Unlock()
+		return token, nil
+	}
+	ts.mu.Unlock()
+
+	err := ts.refresh(false)
+	if err != nil {
+		return "", err
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return ts.tokens.AccessToken, nil
+}
+
+// refresh exchanges the refresh token for new tokens. Callers that arrive while a refresh is in progress wait
+// for its result instead of refreshing again, and unless forced, a token that another caller has refreshed in
+// the meantime is kept.
+func (ts *tokenSource) refresh(force bool) error {
+	ts.mu.Lock()
+	if call := ts.refreshing; call != nil {
+		ts.mu.Unlock()
+		<-call.done
+		return call.err
+	}
+
+	if !force && ts.isValid() {
+		ts.mu.Unlock()
+		return nil
+	}
+
+	call := &refreshCall{done: make(chan struct{})}
+	ts.refreshing = call
+	current := ts.tokens
+	refreshToken := current.RefreshToken
+	ts.mu.Unlock()
+
+	var tokens *database.TidalTokens
+	var err error
+	if refreshToken == "" {
+		err = ErrNoRefreshToken
+	} else {
+		tokens, err = ts.client.requestTokens(current.ClientId, current.ClientSecret, url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {refreshToken},
+		})
+	}
+
+	if err == nil {
+		// the refresh token is only part of the response when tidal rotates it
+		if tokens.RefreshToken == "" {
+			tokens.RefreshToken = refreshToken
+		}
+		tokens.ClientId = current.ClientId
+		tokens.ClientSecret = current.ClientSecret
+	}
+
+	if err != nil {
+		ts.logger.Error("couldn't refresh tidal tokens",
+			"error", err.Error())
+	} else if tokens.RefreshToken != refreshToken {
+		ts.logger.Info("tidal rotated the refresh token")
+	}
+
+	ts.mu.Lock()
+	switch {
+	case err != nil && ts.tokens.RefreshToken == refreshToken:
+		// the refresh token stops working once another process, like the tidal-login command, has logged in
+		// again, the tokens it stored are used then
+		if stored := ts.replacedInStore(refreshToken); stored != nil {
+			ts.logger.Info("tidal tokens were replaced in the store, using the stored ones")
+			ts.tokens = *stored
+			err = nil
+		}
+	case err == nil:
+		// a login during the refresh has replaced the tokens that were refreshed, and stored its own. The
+		// check, the store and the swap happen under the lock so that a login can't come in between them.
+		if ts.tokens.RefreshToken != refreshToken {
+			ts.logger.Info("tidal tokens were replaced during the refresh, discarding the refreshed ones")
+		} else if stored := ts.replacedInStore(refreshToken); stored != nil {
+			// another process, like the tidal-login command, has stored tokens of a newer login
+			ts.logger.Info("tidal tokens were replaced in the store during the refresh, using the stored ones")
+			ts.tokens = *stored
+		} else {
+			// the old refresh token might not work anymore once it has been rotated, so the new one is stored
+			// before anyone can use it
+			ts.save(tokens)
+			ts.tokens = *tokens
+		}
+	}
+
+	if err != nil {
+		ts.lastRefreshError = err.Error()
+		ts.lastRefreshErrorAt = time.Now()
+	} else {
+		ts.lastRefreshAt = time.Now()
+		ts.lastRefreshError = ""
+		ts.lastRefreshErrorAt = time.Time{}
+	}
+	ts.refreshing = nil
+	ts.mu.Unlock()
+
+	call.err = err
+	close(call.done)
+
+	return err
+}
+
+// invalidate makes the access token be refreshed before it's used again, unless it has been replaced already
+func (ts *tokenSource) invalidate(accessToken string) {
+	ts.mu.Lock()
+	if ts.tokens.AccessToken == accessToken {
+		ts.tokens.ExpiresAt = time.Time{}
+	}
+	ts.mu.Unlock()
+}
+
+// set replaces the tokens with the ones of a login
+func (ts *tokenSource) set(tokens *database.TidalTokens) {
+	ts.mu.Lock()
+	ts.save(tokens)
+	ts.tokens = *tokens
+	ts.lastRefreshAt = time.Now()
+	ts.lastRefreshError = ""
+	ts.lastRefreshErrorAt = time.Time{}
+	ts.mu.Unlock()
+}
+
+// credentials returns the client that the tokens were issued to
+func (ts *tokenSource) credentials() (string, string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	return ts.tokens.ClientId, ts.tokens.ClientSecret
+}
+
+// replacedInStore returns the stored tokens if they aren't the ones of the refresh token anymore, because another
+// process has stored the tokens of a login since. It has to be called with the lock held.
+func (ts *tokenSource) replacedInStore(refreshToken string) *database.TidalTokens {
+	if ts.store == nil {
+		return nil
+	}
+
+	stored, err := ts.store.Load()
+	if err != nil {
+		if !errors.Is(err, database.ErrRecordNotFound) {
+			ts.logger.Error("couldn't load stored tidal tokens",
+				"error", err.Error())
+		}
+
+		return nil
+	}
+
+	if stored.RefreshToken == "" || stored.RefreshToken == refreshToken {
+		return nil
+	}
+
+	if stored.ClientId == "" {
+		stored.ClientId = ts.tokens.ClientId
+		stored.ClientSecret = ts.tokens.ClientSecret
+	}
+
+	return stored
+}
+
+// save stores the tokens, it has to be called with the lock held so that the stored tokens are always the
+// current ones
+func (ts *tokenSource) save(tokens *database.TidalTokens) {
+	if ts.store == nil {
+		return
+	}
+
+	err := ts.store.Save(tokens)
+	if err != nil {
+		ts.logger.Error("couldn't store tidal tokens",
+			"error", err.Error())
+	}
+}
+
+func (ts *tokenSource) status() TokenStatus {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	status := TokenStatus{
+		HasAccessToken:   ts.tokens.AccessToken != "",
+		HasRefreshToken:  ts.tokens.RefreshToken != "",
+		LastRefreshError: ts.lastRefreshError,
+	}
+
+	if !ts.tokens.ExpiresAt.IsZero() {
+		expiresAt := ts.tokens.ExpiresAt
+		status.ExpiresAt = &expiresAt
+	}
+
+	if !ts.lastRefreshAt.IsZero() {
+		lastRefreshAt := ts.lastRefreshAt
+		status.LastRefreshAt = &lastRefreshAt
+	}
+
+	if !ts.lastRefreshErrorAt.IsZero() {
+		lastRefreshErrorAt := ts.lastRefreshErrorAt
+		status.LastRefreshErrorAt = &lastRefreshErrorAt
+	}
+
+	status.Healthy = ts.isValid() || (status.HasRefreshToken && ts.lastRefreshError == "")
+
+	return status
+}
+
+// TokenStatus returns the health of the tokens of the client
+func (c *Client) TokenStatus() TokenStatus {
+	return c.tokens.status()
+}
+
+// LoggedIn returns whether the client has tokens, which doesn't mean that they still work
+func (c *Client) LoggedIn() bool {
+	status := c.tokens.status()
+	return status.HasAccessToken || status.HasRefreshToken
+}
+
+// RefreshTokens refreshes the access token right away, even if it's still valid
+func (c *Client) RefreshTokens() error {
+	return c.tokens.refresh(true)
+}
+
+// requestTokens posts the grant to the oauth token endpoint as the client and returns the tokens it answers
+// with. Errors answered by the endpoint are returned as a *TokenError.
+func (c *Client) requestTokens(clientId string, clientSecret string, form url.Values) (*database.TidalTokens, error) {
+	req, err := http.NewRequest(http.MethodPost, resolveURL(c.authBaseURL, "/v1/oauth2/token", nil), strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+
+	auth := base64.StdEncoding.EncodeToString([]byte(clientId + ":" + clientSecret))
+	req.Header.Set("Authorization", "Basic "+auth)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		tokenErr := &TokenError{StatusCode: res.StatusCode}
+		if json.Unmarshal(body, tokenErr) != nil || tokenErr.Code == "" {
+			tokenErr.Code = strings.TrimSpace(string(body))
+		}
+
+		return nil, tokenErr
+	}
+
+	var token TidalTokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("empty access token in response")
+	}
+
+	return &database.TidalTokens{
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(token.ExpiresIn) * time.Second),
+	}, nil
+}
@@ -0,0 +1,258 @@
+package transcoder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrCancelled = errors.New("transcode was cancelled")
+
+// Fake writes synthetic one second segments instead of transcoding anything, so that streaming can be
+// exercised without ffmpeg or a reachable input. The contents of the files aren't playable audio.
+type Fake struct {
+	segments int
+	interval time.Duration
+}
+
+type fakeProcess struct {
+	job      Job
+	progress chan Progress
+	cancel   chan struct{}
+	once     sync.Once
+	done     chan struct{}
+	err      error
+}
+
+// NewFake returns a fake that writes the given number of segments, one every interval
+func NewFake(segments int, interval time.Duration) *Fake {
+	return &Fake{
+		segments: segments,
+		interval: interval,
+	}
+}
+
+func (f *Fake) Start(job Job) (Process, error) {
+	if job.Output != OutputHLS && job.Output != OutputFile {
+		return nil, fmt.Errorf("unknown output type %d", job.Output)
+	}
+
+	for _, variant := range job.Variants {
+		err := os.MkdirAll(filepath.Join(job.OutputDir, variant.Name), os.ModePerm)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p := &fakeProcess{
+		job:      job,
+		progress: make(chan Progress, 1),
+		cancel:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	// a seek skips the segments before the offset
+	segments := f.segments - int(job.SeekOffset)
+	if segments < 1 {
+		segments = 1
+	}
+
+	go func() {
+		defer close(p.done)
+		defer close(p.progress)
+
+		p.err = p.run(segments, f.interval)
+	}()
+
+	return p, nil
+}
+
+func (p *fakeProcess) run(segments int, interval time.Duration) error {
+	err := p.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	speed := 0.0
+	if interval > 0 {
+		speed = time.Second.Seconds() / interval.Seconds()
+	}
+
+	last := Progress{}
+	for i := range segments {
+		select {
+		case <-p.cancel:
+			return ErrCancelled
+		case <-time.After(interval):
+		}
+
+		err = p.writeSegment(p.job.StartNumber + i)
+		if err != nil {
+			return err
+		}
+
+		last = Progress{
+			OutTime: time.Duration(i+1) * time.Second,
+			Speed:   speed,
+		}
+		if p.job.Output == OutputHLS {
+			last.SegmentsWritten = i + 1
+		}
+
+		p.progress <- last
+	}
+
+	if p.job.Output == OutputHLS {
+		err = p.appendToPlaylists("#EXT-X-ENDLIST\n")
+		if err != nil {
+			return err
+		}
+	}
+
+	p.progress <- last
+
+	return nil
+}
+
+func (p *fakeProcess) renditionDirs() []string {
+	if len(p.job.Variants) == 0 {
+		return []string{p.job.OutputDir}
+	}
+
+	dirs := []string{}
+	for _, variant := range p.job.Variants {
+		dirs = append(dirs, filepath.Join(p.job.OutputDir, variant.Name))
+	}
+
+	return dirs
+}
+
+func (p *fakeProcess) writeHeader() error {
+	if p.job.Output == OutputFile {
+		return os.WriteFile(p.job.FilePath(), nil, 0o644)
+	}
+
+	if len(p.job.Variants) > 0 {
+		master := []string{"#EXTM3U", "#EXT-X-VERSION:7"}
+		for _, variant := range p.job.Variants {
+			master = append(master,
+				fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"", variant.Bitrate*1000, variant.Codec),
+				filepath.ToSlash(filepath.Join(variant.Name, "index.m3u8")),
+			)
+		}
+
+		err := os.WriteFile(filepath.Join(p.job.OutputDir, "master.m3u8"), []byte(strings.Join(master, "\n")+"\n"), 0o644)
+		if err != nil {
+			return err
+		}
+	}
+
+	profile := p.job.Profile
+	if len(p.job.Variants) > 0 {
+		profile = p.job.Variants[0]
+	}
+
+	header := fmt.Sprintf("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:1\n#EXT-X-MEDIA-SEQUENCE:%d\n"+
+		"#EXT-X-PLAYLIST-TYPE:EVENT\n", p.job.StartNumber)
+	if profile.HasInitSegment() {
+		header += fmt.Sprintf("#EXT-X-MAP:URI=%q\n", profile.InitSegmentName(p.job.StartNumber))
+	}
+
+	for _, dir := range p.renditionDirs() {
+		if profile.HasInitSegment() {
+			initPath := filepath.Join(dir, profile.InitSegmentName(p.job.StartNumber))
+			err := os.WriteFile(initPath, []byte("fake init segment\n"), 0o644)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := os.WriteFile(filepath.Join(dir, "index.m3u8"), []byte(header), 0o644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *fakeProcess) writeSegment(nr int) error {
+	data := fmt.Appendf(nil, "fake segment %d\n", nr)
+
+	if p.job.Output == OutputFile {
+		file, err := os.OpenFile(p.job.FilePath(), os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+
+		_, err = file.Write(data)
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		return file.Close()
+	}
+
+	profile := p.job.Profile
+	if len(p.job.Variants) > 0 {
+		profile = p.job.Variants[0]
+	}
+
+	// the segment is written before it's listed, like ffmpeg does
+	for _, dir := range p.renditionDirs() {
+		err := os.WriteFile(filepath.Join(dir, profile.SegmentName(nr)), data, 0o644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return p.appendToPlaylists(fmt.Sprintf("#EXTINF:1.000000,\n%s\n", profile.SegmentName(nr)))
+}
+
+func (p *fakeProcess) appendToPlaylists(lines string) error {
+	for _, dir := range p.renditionDirs() {
+		file, err := os.OpenFile(filepath.Join(dir, "index.m3u8"), os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+
+		_, err = file.WriteString(lines)
+		if err != nil {
+			file.Close()
+			return err
+		}
+
+		err = file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *fakeProcess) Progress() <-chan Progress {
+	return p.progress
+}
+
+func (p *fakeProcess) Wait() error {
+	<-p.done
+	return p.err
+}
+
+func (p *fakeProcess) Cancel() error {
+	p.once.Do(func() {
+		close(p.cancel)
+	})
+
+	return nil
+}
+
+func (p *fakeProcess) OutputDir() string {
+	return p.job.OutputDir
+}
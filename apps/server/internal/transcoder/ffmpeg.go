@@ -0,0 +1,234 @@
+package transcoder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/altierawr/oto/internal/ffmpeg"
+	"github.com/altierawr/oto/internal/profiles"
+)
+
+// FFmpeg transcodes jobs with an ffmpeg binary
+type FFmpeg struct {
+	binary string
+}
+
+type ffmpegProcess struct {
+	cmd       *exec.Cmd
+	outputDir string
+	progress  chan Progress
+	done      chan struct{}
+	err       error
+}
+
+func NewFFmpeg(binary string) *FFmpeg {
+	return &FFmpeg{
+		binary: binary,
+	}
+}
+
+func (f *FFmpeg) Start(job Job) (Process, error) {
+	var args []string
+	switch job.Output {
+	case OutputHLS:
+		// segments of adaptive streams are written to one directory per rendition
+		for _, variant := range job.Variants {
+			err := os.MkdirAll(filepath.Join(job.OutputDir, variant.Name), os.ModePerm)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		args = buildHLSArgs(job)
+	case OutputFile:
+		args = buildFileArgs(job)
+	default:
+		return nil, fmt.Errorf("unknown output type %d", job.Output)
+	}
+
+	cmd := exec.Command(f.binary, append(slices.Clone(ffmpeg.ProgressArgs), args...)...)
+
+	stderr := ffmpeg.NewLogTail(20)
+	cmd.Stderr = stderr
+
+	progressOutput, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	p := &ffmpegProcess{
+		cmd:       cmd,
+		outputDir: job.OutputDir,
+		progress:  make(chan Progress, 1),
+		done:      make(chan struct{}),
+	}
+
+	playlistPath := job.PlaylistPath()
+
+	// segments are counted from the playlist since ffmpeg only adds them once they're complete
+	withSegments := func(progress Progress) Progress {
+		if job.Output == OutputHLS {
+			segments, err := ffmpeg.PlaylistSegments(playlistPath)
+			if err == nil {
+				progress.SegmentsWritten = segments
+			}
+		}
+
+		return progress
+	}
+
+	go func() {
+		defer close(p.done)
+		defer close(p.progress)
+
+		last := Progress{}
+		readErr := ffmpeg.ReadProgress(progressOutput, func(progress ffmpeg.Progress) {
+			last = withSegments(Progress{
+				OutTime: progress.OutTime,
+				Speed:   progress.Speed,
+			})
+
+			p.progress <- last
+		})
+
+		waitErr := cmd.Wait()
+		if waitErr == nil && readErr != nil {
+			waitErr = fmt.Errorf("couldn't read ffmpeg progress: %w", readErr)
+		}
+
+		if waitErr != nil {
+			p.err = &Error{
+				Err:     waitErr,
+				Output:  stderr.String(),
+				Message: stderr.Last(),
+			}
+		}
+
+		p.progress <- withSegments(last)
+	}()
+
+	return p, nil
+}
+
+func (p *ffmpegProcess) Progress() <-chan Progress {
+	return p.progress
+}
+
+func (p *ffmpegProcess) Wait() error {
+	<-p.done
+	return p.err
+}
+
+func (p *ffmpegProcess) Cancel() error {
+	err := p.cmd.Process.Kill()
+	if err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+
+	return nil
+}
+
+func (p *ffmpegProcess) OutputDir() string {
+	return p.outputDir
+}
+
+func inputArgs(job Job) []string {
+	args := []string{}
+
+	if job.SeekOffset > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%ss", strconv.FormatFloat(job.SeekOffset, 'f', -1, 64)))
+	}
+
+	args = append(args, "-i", job.Input)
+
+	if job.Gain != 0 {
+		args = append(args, "-af", fmt.Sprintf("volume=%.1fdB", job.Gain))
+	}
+
+	return args
+}
+
+func buildFileArgs(job Job) []string {
+	args := append([]string{"-y"}, inputArgs(job)...)
+	args = append(args, "-map", "0:a:0")
+	args = append(args, job.Profile.Args()...)
+	args = append(args, "-f", job.Profile.FileFormat, job.FilePath())
+
+	return args
+}
+
+// startArgs returns the args that make an HLS job continue the segments of a stream at StartNumber
+func startArgs(job Job, profile *profiles.Profile) []string {
+	if job.StartNumber == 0 {
+		return nil
+	}
+
+	args := []string{
+		"-start_number", strconv.Itoa(job.StartNumber),
+		"-output_ts_offset", strconv.FormatFloat(job.StartTime, 'f', -1, 64),
+	}
+
+	if profile.HasInitSegment() {
+		args = append(args, "-hls_fmp4_init_filename", profile.InitSegmentName(job.StartNumber))
+	}
+
+	return args
+}
+
+func buildHLSArgs(job Job) []string {
+	args := inputArgs(job)
+	tempDir := job.OutputDir
+
+	if len(job.Variants) == 0 {
+		args = append(args, job.Profile.Args()...)
+		args = append(args, startArgs(job, job.Profile)...)
+		args = append(args,
+			"-f", "hls",
+			"-hls_time", strconv.Itoa(SegmentDuration),
+			"-hls_playlist_type", "event",
+			"-hls_segment_type", job.Profile.SegmentType,
+			"-hls_segment_filename", path.Join(tempDir, job.Profile.SegmentPattern()),
+			filepath.Join(tempDir, "index.m3u8"),
+		)
+
+		return args
+	}
+
+	streamMap := []string{}
+	for i, variant := range job.Variants {
+		args = append(args, "-map", "0:a")
+		streamMap = append(streamMap, fmt.Sprintf("a:%d,name:%s", i, variant.Name))
+	}
+
+	for i, variant := range job.Variants {
+		args = append(args, variant.StreamArgs(i)...)
+	}
+
+	args = append(args, startArgs(job, job.Variants[0])...)
+
+	// with %v in the directory, ffmpeg writes the master playlist one level above the rendition directories
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(SegmentDuration),
+		"-hls_playlist_type", "event",
+		"-hls_segment_type", job.Variants[0].SegmentType,
+		"-hls_segment_filename", path.Join(tempDir, "%v", job.Variants[0].SegmentPattern()),
+		"-master_pl_name", "master.m3u8",
+		"-var_stream_map", strings.Join(streamMap, " "),
+		filepath.Join(tempDir, "%v", "index.m3u8"),
+	)
+
+	return args
+}
@@ -0,0 +1,121 @@
+package transcoder
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/altierawr/oto/internal/profiles"
+)
+
+var ErrUnknownTranscoder = errors.New("unknown transcoder")
+
+// SegmentDuration is the target duration of HLS segments in seconds. Segments end on the first audio frame
+// boundary after it, so they are usually a bit longer.
+const SegmentDuration = 1
+
+type OutputType int
+
+const (
+	// OutputHLS writes an HLS media playlist with segments, or one per rendition with a master playlist
+	// for adaptive jobs
+	OutputHLS OutputType = iota
+	// OutputFile writes a single audio file in the file format of the profile
+	OutputFile
+)
+
+// Job describes what to transcode and where to write it
+type Job struct {
+	Output OutputType
+	// Input is a url or path that ffmpeg can read
+	Input string
+	// OutputDir is the existing directory the output is written to
+	OutputDir  string
+	SeekOffset float64
+	Profile    *profiles.Profile
+	// Renditions of an adaptive HLS job, nil for single rendition jobs
+	Variants []*profiles.Profile
+	// Gain in dB applied to the audio, 0 to leave it as is
+	Gain float64
+	// StartNumber is the number of the first segment of an HLS job that continues a stream
+	StartNumber int
+	// StartTime is the time in seconds that the first segment of an HLS job that continues a stream starts
+	// at. The output timestamps are shifted by it to line up with the segments before it.
+	StartTime float64
+}
+
+// PlaylistPath returns the media playlist that tracks the progress of an HLS job. Every rendition of an
+// adaptive job has the same segments, so the first one is used.
+func (j *Job) PlaylistPath() string {
+	if len(j.Variants) > 0 {
+		return filepath.Join(j.OutputDir, j.Variants[0].Name, "index.m3u8")
+	}
+
+	return filepath.Join(j.OutputDir, "index.m3u8")
+}
+
+// FilePath returns the path of the output of a file job
+func (j *Job) FilePath() string {
+	return filepath.Join(j.OutputDir, j.Profile.FileName())
+}
+
+type Progress struct {
+	// Media time transcoded so far, counted from the seek offset
+	OutTime time.Duration
+	// Speed relative to realtime, 0 if unknown
+	Speed float64
+	// Number of segments completely written so far, for HLS jobs
+	SegmentsWritten int
+}
+
+// Transcoder starts transcoding jobs
+type Transcoder interface {
+	Start(job Job) (Process, error)
+}
+
+// Process is a running transcoding job
+type Process interface {
+	// Progress receives updates while the job runs and a last one once it has exited, after which it's
+	// closed. It has to be drained for the job to finish.
+	Progress() <-chan Progress
+	// Wait blocks until the job has exited and returns why it failed, if it did
+	Wait() error
+	// Cancel stops the job, it is safe to call more than once and after the job has exited
+	Cancel() error
+	// OutputDir returns the directory the output is written to
+	OutputDir() string
+}
+
+// Error is returned by Wait when a job has failed
+type Error struct {
+	Err error
+	// Output is the end of the log of the transcoder, empty if there was none
+	Output string
+	// Message is the last line of Output
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// New returns the transcoder with the name, ffmpeg or fake
+func New(name string) (Transcoder, error) {
+	switch name {
+	case "", "ffmpeg":
+		return NewFFmpeg("ffmpeg"), nil
+	case "fake":
+		return NewFake(180, 50*time.Millisecond), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTranscoder, name)
+	}
+}
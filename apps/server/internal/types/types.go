@@ -1,22 +1,24 @@
 package types
 
 type TidalAlbum struct {
-	ID              int           `db:"id" json:"id"`
-	Cover           *string       `db:"cover" json:"cover,omitempty"`
-	Explicit        bool          `db:"explicit" json:"explicit,omitempty"`
-	Duration        *int          `db:"duration" json:"duration,omitempty"`
-	NumberOfTracks  *int          `db:"number_of_tracks" json:"numberOfTracks,omitempty"`
-	NumberOfVolumes *int          `db:"number_of_volumes" json:"numberOfVolumes,omitempty"`
-	ReleaseDate     *string       `db:"release_date" json:"releaseDate,omitempty"`
-	Title           string        `db:"title" json:"title"`
-	Type            *string       `db:"type" json:"type,omitempty"` // SINGLE, EP, ALBUM
-	UPC             *string       `db:"upc" json:"upc,omitempty"`
-	VibrantColor    *string       `db:"vibrant_color" json:"vibrantColor,omitempty"`
-	VideoCover      *string       `db:"video_cover" json:"videoCover,omitempty"`
-	Songs           []TidalSong   `json:"songs,omitempty"`
-	Artists         []TidalArtist `json:"artists,omitempty"`
-	UpdatedAt       *int64        `db:"updated_at"`
-	ArtistId        *int          `db:"artist_id"`
+	ID                 int           `db:"id" json:"id"`
+	Cover              *string       `db:"cover" json:"cover,omitempty"`
+	Explicit           bool          `db:"explicit" json:"explicit,omitempty"`
+	Duration           *int          `db:"duration" json:"duration,omitempty"`
+	NumberOfTracks     *int          `db:"number_of_tracks" json:"numberOfTracks,omitempty"`
+	NumberOfVolumes    *int          `db:"number_of_volumes" json:"numberOfVolumes,omitempty"`
+	ReleaseDate        *string       `db:"release_date" json:"releaseDate,omitempty"`
+	Title              string        `db:"title" json:"title"`
+	Type               *string       `db:"type" json:"type,omitempty"` // SINGLE, EP, ALBUM
+	UPC                *string       `db:"upc" json:"upc,omitempty"`
+	VibrantColor       *string       `db:"vibrant_color" json:"vibrantColor,omitempty"`
+	VideoCover         *string       `db:"video_cover" json:"videoCover,omitempty"`
+	IntegratedLoudness *float64      `db:"loudness_integrated" json:"integratedLoudness,omitempty"` // LUFS
+	TruePeak           *float64      `db:"loudness_true_peak" json:"truePeak,omitempty"`            // dBTP
+	Songs              []TidalSong   `json:"songs,omitempty"`
+	Artists            []TidalArtist `json:"artists,omitempty"`
+	UpdatedAt          *int64        `db:"updated_at"`
+	ArtistId           *int          `db:"artist_id"`
 }
 
 type TidalArtist struct {
@@ -28,20 +30,34 @@ type TidalArtist struct {
 }
 
 type TidalSong struct {
-	ID              int           `db:"id" json:"id"`
-	Bpm             *int          `db:"bpm" json:"bpm,omitempty"`
-	Duration        int           `db:"duration" json:"duration"`
-	Explicit        bool          `db:"explicit" json:"explicit"`
-	ISRC            *string       `db:"isrc" json:"isrc,omitempty"`
-	StreamStartDate *string       `db:"stream_start_date" json:"streamStartDate,omitempty"`
-	Title           string        `db:"title" json:"title"`
-	TrackNumber     *int          `db:"track_number" json:"trackNumber,omitempty"`
-	VolumeNumber    *int          `db:"volume_number" json:"volumeNumber,omitempty"`
-	Artists         []TidalArtist `json:"artists"`
-	Album           *TidalAlbum   `json:"album"`
-	UpdatedAt       *int64        `db:"updated_at"`
-	ArtistId        *int          `db:"artist_id"`
-	AlbumId         *int          `db:"album_id"`
+	ID                 int           `db:"id" json:"id"`
+	Bpm                *int          `db:"bpm" json:"bpm,omitempty"`
+	Duration           int           `db:"duration" json:"duration"`
+	Explicit           bool          `db:"explicit" json:"explicit"`
+	ISRC               *string       `db:"isrc" json:"isrc,omitempty"`
+	StreamStartDate    *string       `db:"stream_start_date" json:"streamStartDate,omitempty"`
+	Title              string        `db:"title" json:"title"`
+	TrackNumber        *int          `db:"track_number" json:"trackNumber,omitempty"`
+	VolumeNumber       *int          `db:"volume_number" json:"volumeNumber,omitempty"`
+	IntegratedLoudness *float64      `db:"loudness_integrated" json:"integratedLoudness,omitempty"` // LUFS
+	TruePeak           *float64      `db:"loudness_true_peak" json:"truePeak,omitempty"`            // dBTP
+	Artists            []TidalArtist `json:"artists"`
+	Album              *TidalAlbum   `json:"album"`
+	Credits            []TidalCredit `json:"credits,omitempty"`
+	UpdatedAt          *int64        `db:"updated_at"`
+	ArtistId           *int          `db:"artist_id"`
+	AlbumId            *int          `db:"album_id"`
+}
+
+// TidalCredit is a role on a track, such as Producer or Composer, and the people credited with it
+type TidalCredit struct {
+	Type         string             `json:"type"`
+	Contributors []TidalContributor `json:"contributors"`
+}
+
+type TidalContributor struct {
+	ID   *int   `json:"id,omitempty"` // not every contributor has one
+	Name string `json:"name"`
 }
 
 type TidalPlaylist struct {
@@ -66,15 +82,18 @@ type TidalTopHit struct {
 	Value any    `json:"value"`
 }
 
-type PlaybackInfo struct {
-	Manifest string `json:"manifest,omitempty"`
-}
-
+// ManifestData is the JSON manifest Tidal returns with the application/vnd.tidal.bts mime type
 type ManifestData struct {
-	Urls []string `json:"urls,omitempty"`
+	MimeType       string   `json:"mimeType,omitempty"`
+	Codecs         string   `json:"codecs,omitempty"`
+	EncryptionType string   `json:"encryptionType,omitempty"`
+	Urls           []string `json:"urls,omitempty"`
 }
 
 type TidalPlaybackInfo struct {
+	AudioQuality     string `json:"audioQuality"`
+	BitDepth         int    `json:"bitDepth,omitempty"`
+	SampleRate       int    `json:"sampleRate,omitempty"`
 	ManifestMimeType string `json:"manifestMimeType"`
 	Manifest         string `json:"manifest"`
 }
@@ -100,3 +119,21 @@ type TidalArtistPage struct {
 	AppearsOn                  []TidalAlbum  `json:"appearsOn,omitempty"`
 	SimilarArtists             []TidalArtist `json:"similarArtists,omitempty"`
 }
+
+// TidalLyrics are the lyrics of a track, either from Tidal or uploaded by a user for a track that Tidal has
+// none for
+type TidalLyrics struct {
+	TrackId  int     `json:"trackId"`
+	Source   string  `json:"source"` // tidal, user
+	Provider *string `json:"provider,omitempty"`
+	// Whether the lines have times to follow along with playback
+	IsSynced      bool         `json:"isSynced"`
+	IsRightToLeft bool         `json:"isRightToLeft"`
+	Lines         []LyricsLine `json:"lines"`
+}
+
+type LyricsLine struct {
+	// Time in seconds from the start of the track at which the line is sung, nil for unsynced lyrics
+	Time *float64 `json:"time,omitempty"`
+	Text string   `json:"text"`
+}
@@ -0,0 +1,260 @@
+package waveform
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/altierawr/oto/internal/database"
+	"github.com/altierawr/oto/internal/ffmpeg"
+	"github.com/altierawr/oto/internal/tidal"
+)
+
+const (
+	// MaxResolution is the number of buckets that waveforms are stored with, requests for fewer are
+	// downsampled from them
+	MaxResolution = 2000
+	// DefaultResolution is the number of buckets returned when no resolution is requested
+	DefaultResolution = 200
+
+	defaultQueueSize  = 1000
+	extractionTimeout = 10 * time.Minute
+	// failedRetryDelay is how long a track whose extraction failed isn't tried again
+	failedRetryDelay = time.Hour
+)
+
+// transcoderUserId is the user the extraction jobs are counted under in the transcoder pool
+const transcoderUserId = "waveform"
+
+// FailedError is returned for a track whose waveform couldn't be extracted, until it's tried again at RetryAt
+type FailedError struct {
+	// NotFound is whether tidal doesn't have the track
+	NotFound bool
+	RetryAt  time.Time
+	Reason   string
+}
+
+func (e *FailedError) Error() string {
+	return fmt.Sprintf("couldn't extract waveform: %s", e.Reason)
+}
+
+// Service extracts the waveforms of tracks in the background, one track at a time, and stores them so that
+// every track is only decoded once
+type Service struct {
+	db          *database.DB
+	logger      *slog.Logger
+	transcoders *ffmpeg.Pool
+	tidal       *tidal.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// pendingMu guards pending, which has the tracks that are queued or being extracted, and failed
+	pendingMu sync.Mutex
+	pending   map[int64]bool
+	failed    map[int64]*FailedError
+	queue     chan int64
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func New(db *database.DB, logger *slog.Logger, transcoders *ffmpeg.Pool, tidalClient *tidal.Client) *Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Service{
+		db:          db,
+		logger:      logger,
+		transcoders: transcoders,
+		tidal:       tidalClient,
+		ctx:         ctx,
+		cancel:      cancel,
+		pending:     map[int64]bool{},
+		failed:      map[int64]*FailedError{},
+		queue:       make(chan int64, defaultQueueSize),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Get returns the waveform of the track in the given number of buckets. If it hasn't been extracted yet, the
+// track is queued for extraction and database.ErrRecordNotFound is returned. A track whose extraction failed
+// returns a *FailedError until it can be tried again.
+func (s *Service) Get(trackId int64, resolution int) (*ffmpeg.Waveform, error) {
+	stored, err := s.db.GetTidalTrackWaveform(trackId)
+	if err != nil {
+		if errors.Is(err, database.ErrRecordNotFound) {
+			if failed := s.Enqueue(trackId); failed != nil {
+				return nil, failed
+			}
+		}
+
+		return nil, err
+	}
+
+	waveform := &ffmpeg.Waveform{
+		Duration: stored.Duration,
+		Peaks:    stored.Peaks,
+		RMS:      stored.RMS,
+	}
+
+	return waveform.Downsample(resolution), nil
+}
+
+// Enqueue queues the track for extraction unless it's queued or being extracted already. A track whose
+// extraction failed isn't queued until it can be tried again, the failure is returned instead.
+func (s *Service) Enqueue(trackId int64) *FailedError {
+	s.pendingMu.Lock()
+	defer s.pendingMu.Unlock()
+
+	if s.pending[trackId] {
+		return nil
+	}
+
+	if failed, found := s.failed[trackId]; found {
+		if time.Now().Before(failed.RetryAt) {
+			return failed
+		}
+
+		delete(s.failed, trackId)
+	}
+
+	select {
+	case s.queue <- trackId:
+		s.pending[trackId] = true
+	default:
+		s.logger.Warn("waveform queue is full, dropping track",
+			"trackId", trackId)
+	}
+
+	return nil
+}
+
+func (s *Service) Run() {
+	defer close(s.done)
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case trackId := <-s.queue:
+			failed := s.extract(trackId)
+
+			// the failure is recorded together with the track leaving pending, so that it isn't queued again
+			// in between
+			s.pendingMu.Lock()
+			delete(s.pending, trackId)
+			if failed != nil {
+				s.recordFailureLocked(trackId, failed)
+			}
+			s.pendingMu.Unlock()
+		}
+	}
+}
+
+func (s *Service) Stop() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+
+	s.cancel()
+
+	<-s.done
+}
+
+// recordFailureLocked keeps the failure until the track can be tried again, forgetting the failures that have
+// expired. It has to be called with pendingMu held.
+func (s *Service) recordFailureLocked(trackId int64, failed *FailedError) {
+	now := time.Now()
+	for id, f := range s.failed {
+		if now.After(f.RetryAt) {
+			delete(s.failed, id)
+		}
+	}
+
+	s.failed[trackId] = failed
+}
+
+// extract extracts and stores the waveform of the track. It returns the failure if the track can't be extracted,
+// failures that can go away on their own, like tidal being unavailable or no transcoder being free, aren't
+// returned so that the track is tried again on the next request.
+func (s *Service) extract(trackId int64) *FailedError {
+	// a waveform that was stored while the track was queued doesn't have to be extracted again
+	_, err := s.db.GetTidalTrackWaveform(trackId)
+	if err == nil {
+		return nil
+	}
+
+	slot, err := s.transcoders.Acquire(s.ctx, transcoderUserId)
+	if err != nil {
+		if !errors.Is(err, context.Canceled) {
+			s.logger.Warn("no transcoder free for waveform extraction, skipping track",
+				"error", err.Error(),
+				"trackId", trackId)
+		}
+
+		return nil
+	}
+	defer slot.Release()
+
+	source, err := s.tidal.GetSongSource(trackId, tidal.QualityLossless, tidal.Region{})
+	if err != nil {
+		s.logger.Error("couldn't get track source for waveform extraction",
+			"error", err.Error(),
+			"trackId", trackId)
+
+		if errors.Is(err, tidal.ErrUnavailable) {
+			return nil
+		}
+
+		return &FailedError{
+			NotFound: errors.Is(err, database.ErrRecordNotFound),
+			RetryAt:  time.Now().Add(failedRetryDelay),
+			Reason:   err.Error(),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(s.ctx, extractionTimeout)
+	defer cancel()
+
+	start := time.Now()
+	waveform, err := ffmpeg.ExtractWaveform(ctx, source.Input(), MaxResolution)
+	if err != nil {
+		// the extraction was stopped by the shutdown
+		if s.ctx.Err() != nil {
+			return nil
+		}
+
+		s.logger.Error("couldn't extract track waveform",
+			"error", err.Error(),
+			"trackId", trackId)
+
+		return &FailedError{
+			RetryAt: time.Now().Add(failedRetryDelay),
+			Reason:  err.Error(),
+		}
+	}
+
+	err = s.db.InsertTidalTrackWaveform(&database.TrackWaveform{
+		TrackId:  trackId,
+		Duration: waveform.Duration,
+		Peaks:    waveform.Peaks,
+		RMS:      waveform.RMS,
+	})
+	if err != nil {
+		s.logger.Error("couldn't store track waveform",
+			"error", err.Error(),
+			"trackId", trackId)
+		return nil
+	}
+
+	s.logger.Info("extracted track waveform",
+		"trackId", trackId,
+		"duration", waveform.Duration,
+		"took", time.Since(start).String())
+
+	return nil
+}
@@ -1588,7 +1588,8 @@ export class MusicPlayer {
 
       console.log("new stream id", json.streamId);
       this.playlist[playlistIndex].streamId = json.streamId;
-      this.playlist[playlistIndex].seekOffset = position;
+      // seeks that are served from a cached full transcode start at a later segment of the stream
+      this.playlist[playlistIndex].seekOffset = json.seekOffset ?? position;
       this.playlist[playlistIndex].lastSegmentIndex = null;
       this.playlist[playlistIndex].initSegment = undefined;
       this.playlist[playlistIndex].segments = [];
@@ -1597,11 +1598,12 @@ export class MusicPlayer {
       await this.#clearSourceBuffer();
       await this.#maybeFetchNextSegment({
         force: true,
-        position: position,
+        playlistIndex,
+        segmentIndex: json.segment ?? 0,
       });
       await this.#maybeLoadNextSegment({
         force: true,
-        segmentIndex: 0,
+        segmentIndex: json.segment ?? 0,
       });
 
       const newPos = position + (pe.timestampOffset || 0);
@@ -2106,7 +2108,8 @@ export class MusicPlayer {
 
     if (!pe.streamId) {
       console.log("stream id not found, fetching stream");
-      const streamResp = await request(`/tracks/${this.playlist[playlistIndex].song.id}/stream`, {
+      // the session queue mirrors the playlist, so the server can prefetch the entry after this one
+      const streamResp = await request(`/tracks/${this.playlist[playlistIndex].song.id}/stream?queueIndex=${playlistIndex}`, {
         cache: "no-store",
       });
       if (streamResp.status !== 201) {
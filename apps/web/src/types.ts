@@ -100,6 +100,8 @@ export type PaginatedResponse<T> = {
 
 export type StreamResponse = {
   streamId: string;
+  seekOffset: number;
+  segment: number;
 };
 
 export type SeekResponse = {